@@ -0,0 +1,96 @@
+package genericexec
+
+// trackRun records that run is queued or executing until untrackRun is called for it. Every run is untracked before
+// its result is delivered, and delivery never waits for the result to be received, so runs whose results are never
+// read don't stay tracked.
+func (ctx *GenericExecManager) trackRun(run *taskRun) {
+	ctx.activeMutex.Lock()
+	defer ctx.activeMutex.Unlock()
+	if ctx.activeRuns == nil {
+		ctx.activeRuns = make(map[string]map[*taskRun]bool)
+	}
+	command := run.execTaskConfig.Command
+	if ctx.activeRuns[command] == nil {
+		ctx.activeRuns[command] = make(map[*taskRun]bool)
+	}
+	ctx.activeRuns[command][run] = true
+}
+
+func (ctx *GenericExecManager) untrackRun(run *taskRun) {
+	ctx.activeMutex.Lock()
+	defer ctx.activeMutex.Unlock()
+	command := run.execTaskConfig.Command
+	delete(ctx.activeRuns[command], run)
+	if len(ctx.activeRuns[command]) == 0 {
+		delete(ctx.activeRuns, command)
+	}
+}
+
+// IsCommandBusy reports whether any task with the given Command is currently executing or waiting in its queue.
+// This is true for a reentrant command while any run of it is executing.
+func (ctx *GenericExecManager) IsCommandBusy(command string) bool {
+	ctx.activeMutex.Lock()
+	defer ctx.activeMutex.Unlock()
+	return len(ctx.activeRuns[command]) > 0
+}
+
+// QueueLength returns how many runs of non-reentrant tasks with the given Command are waiting to start, not
+// counting one that is executing. It is always 0 for reentrant commands, which are never queued.
+func (ctx *GenericExecManager) QueueLength(command string) int {
+	ctx.configMutex.RLock()
+	defer ctx.configMutex.RUnlock()
+	queue := ctx.mutexQueues[command]
+	if queue == nil {
+		return 0
+	}
+	return len(queue.runs)
+}
+
+func (ctx *GenericExecManager) markRunStarted(run *taskRun) {
+	ctx.activeMutex.Lock()
+	defer ctx.activeMutex.Unlock()
+	run.started = true
+}
+
+// CancelCommand cancels every run of a task with the given Command that is executing or waiting to start, as though
+// the context each was run with had been cancelled, and returns how many there were. Executing processes are sent
+// their CancelSignal, or killed. Runs that are still queued are not dropped: each is handed its result right away,
+// with FailureKind FailureKindCancelled, without being started. Runs started after CancelCommand returns are not
+// affected.
+func (ctx *GenericExecManager) CancelCommand(command string) int {
+	ctx.activeMutex.Lock()
+	runs := make([]*taskRun, 0, len(ctx.activeRuns[command]))
+	for run := range ctx.activeRuns[command] {
+		runs = append(runs, run)
+	}
+	ctx.activeMutex.Unlock()
+
+	for _, run := range runs {
+		run.cancel()
+	}
+	if len(runs) > 0 {
+		ctx.logf(LogLevelWarn, "Cancelled %d runs of command %s.", len(runs), command)
+	}
+	return len(runs)
+}
+
+// CancelAll is like CancelCommand for every Command at once: it cancels every run that is executing or waiting to
+// start, and returns how many there were. The manager keeps accepting tasks; see Shutdown to stop that.
+func (ctx *GenericExecManager) CancelAll() int {
+	ctx.activeMutex.Lock()
+	var runs []*taskRun
+	for _, commandRuns := range ctx.activeRuns {
+		for run := range commandRuns {
+			runs = append(runs, run)
+		}
+	}
+	ctx.activeMutex.Unlock()
+
+	for _, run := range runs {
+		run.cancel()
+	}
+	if len(runs) > 0 {
+		ctx.logf(LogLevelWarn, "Cancelled all %d runs.", len(runs))
+	}
+	return len(runs)
+}
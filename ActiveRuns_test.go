@@ -0,0 +1,197 @@
+package genericexec
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_IsCommandBusy(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"500ms"},
+			Reentrant: false,
+		},
+		"slow-reentrant": {
+			Name:      "slow-reentrant",
+			Command:   "reentrant-sleep",
+			Args:      []string{"500ms"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	if sut.IsCommandBusy("sleep") || sut.IsCommandBusy("reentrant-sleep") {
+		t.Error("Expected no command to be busy before any task runs")
+	}
+
+	firstChan := sut.RunTask("slow", url.Values{})
+	secondChan := sut.RunTask("slow", url.Values{})
+	reentrantChan := sut.RunTask("slow-reentrant", url.Values{})
+	time.Sleep(100 * time.Millisecond)
+
+	if !sut.IsCommandBusy("sleep") {
+		t.Error("Expected the non-reentrant command to be busy while its task runs")
+	}
+	if queued := sut.QueueLength("sleep"); queued != 1 {
+		t.Errorf("Expected 1 run waiting behind the running one, got %d", queued)
+	}
+	if !sut.IsCommandBusy("reentrant-sleep") {
+		t.Error("Expected the reentrant command to be busy while its task runs")
+	}
+	if queued := sut.QueueLength("reentrant-sleep"); queued != 0 {
+		t.Errorf("Expected a queue length of 0 for a reentrant command, got %d", queued)
+	}
+
+	<-firstChan
+	<-secondChan
+	<-reentrantChan
+	if sut.IsCommandBusy("sleep") || sut.IsCommandBusy("reentrant-sleep") {
+		t.Error("Expected no command to be busy once all results are delivered")
+	}
+	if sut.IsCommandBusy("not-configured") || sut.QueueLength("not-configured") != 0 {
+		t.Error("Expected an unknown command not to be busy")
+	}
+}
+
+func TestGenericExecManager_AbandonedResultsAreUntracked(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"reentrant": {
+			Name:      "reentrant",
+			Command:   "test",
+			Reentrant: true,
+		},
+		"queued": {
+			Name:      "queued",
+			Command:   "fail",
+			Reentrant: false,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	// Nobody ever reads these results.
+	for i := 0; i < 40; i++ {
+		sut.RunTask("reentrant", url.Values{})
+		sut.RunTask("queued", url.Values{})
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for sut.IsCommandBusy("test") || sut.IsCommandBusy("fail") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected abandoned runs to complete")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	sut.activeMutex.Lock()
+	defer sut.activeMutex.Unlock()
+	if len(sut.activeRuns) != 0 {
+		t.Errorf("Expected no tracked runs once all runs completed, got %v", sut.activeRuns)
+	}
+}
+
+func TestGenericExecManager_CancelCommand(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"5s"},
+			Reentrant: false,
+		},
+		"slow-reentrant": {
+			Name:      "slow-reentrant",
+			Command:   "reentrant-sleep",
+			Args:      []string{"5s"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	var resultChans []<-chan GenericExecResult
+	for i := 0; i < 3; i++ {
+		resultChans = append(resultChans, sut.RunTask("slow", url.Values{}))
+	}
+	otherChan := sut.RunTask("slow-reentrant", url.Values{})
+	time.Sleep(100 * time.Millisecond)
+
+	startedAt := time.Now()
+	if cancelled := sut.CancelCommand("sleep"); cancelled != 3 {
+		t.Errorf("Expected 3 runs to be cancelled, got %d", cancelled)
+	}
+	for i, resultChan := range resultChans {
+		result := <-resultChan
+		if result.FailureKind != FailureKindCancelled || result.Succeeded {
+			t.Errorf("Expected run %d to fail as cancelled, got %+v", i, result)
+		}
+		if i > 0 && result.ExitCode != -1 {
+			t.Errorf("Expected queued run %d not to have started, got exit code %d", i, result.ExitCode)
+		}
+	}
+	if elapsed := time.Since(startedAt); elapsed > 2*time.Second {
+		t.Errorf("Expected cancelled runs to finish promptly, took %v", elapsed)
+	}
+	if sut.IsCommandBusy("sleep") {
+		t.Error("Expected the cancelled command not to be busy")
+	}
+
+	if !sut.IsCommandBusy("reentrant-sleep") {
+		t.Error("Expected another command's run to be unaffected")
+	}
+	if cancelled := sut.CancelCommand("not-running"); cancelled != 0 {
+		t.Errorf("Expected nothing to cancel for an idle command, got %d", cancelled)
+	}
+	sut.CancelCommand("reentrant-sleep")
+	if result := <-otherChan; result.FailureKind != FailureKindCancelled {
+		t.Errorf("Expected the other command's run to be cancelled too, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_CancelAll(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:    "slow",
+			Command: "sleep",
+			Args:    []string{"5s"},
+		},
+		"slow-reentrant": {
+			Name:      "slow-reentrant",
+			Command:   "reentrant-sleep",
+			Args:      []string{"5s"},
+			Reentrant: true,
+		},
+		"other": {
+			Name:    "other",
+			Command: "timestamps",
+			Args:    []string{"5s"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	var resultChans []<-chan GenericExecResult
+	for _, taskName := range []string{"slow", "slow", "slow-reentrant", "slow-reentrant", "other"} {
+		resultChans = append(resultChans, sut.RunTask(taskName, url.Values{}))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	startedAt := time.Now()
+	if cancelled := sut.CancelAll(); cancelled != 5 {
+		t.Errorf("Expected 5 runs to be cancelled, got %d", cancelled)
+	}
+	for i, resultChan := range resultChans {
+		if result := <-resultChan; result.FailureKind != FailureKindCancelled || result.Succeeded {
+			t.Errorf("Expected run %d to fail as cancelled, got %+v", i, result)
+		}
+	}
+	if elapsed := time.Since(startedAt); elapsed > 2*time.Second {
+		t.Errorf("Expected cancelled runs to finish promptly, took %v", elapsed)
+	}
+	for _, command := range []string{"sleep", "reentrant-sleep", "timestamps"} {
+		if sut.IsCommandBusy(command) {
+			t.Errorf("Expected command %s not to be busy after CancelAll", command)
+		}
+	}
+	if cancelled := sut.CancelAll(); cancelled != 0 {
+		t.Errorf("Expected nothing to cancel once idle, got %d", cancelled)
+	}
+}
@@ -0,0 +1,53 @@
+package genericexec
+
+import (
+	"fmt"
+)
+
+// syncTaskAliases must be called with configMutex held for writing.
+func (ctx *GenericExecManager) syncTaskAliases() {
+	ctx.taskAliases = make(map[string]string)
+	for taskName, execConfig := range ctx.execTaskConfigsByName {
+		for _, alias := range execConfig.Aliases {
+			ctx.taskAliases[alias] = taskName
+		}
+	}
+}
+
+// taskConfig returns the configuration of the named task, or of the task taskName is one of the Aliases of.
+// It must be called with configMutex held.
+func (ctx *GenericExecManager) taskConfig(taskName string) (GenericExecConfig, bool) {
+	if execConfig, found := ctx.execTaskConfigsByName[taskName]; found {
+		return execConfig, true
+	}
+	if canonicalName, isAlias := ctx.taskAliases[taskName]; isAlias {
+		return ctx.execTaskConfigsByName[canonicalName], true
+	}
+	return GenericExecConfig{}, false
+}
+
+// canonicalTaskName returns the name taskName is configured under, which differs if it is one of a task's Aliases.
+// It must be called with configMutex held.
+func (ctx *GenericExecManager) canonicalTaskName(taskName string) string {
+	if canonicalName, isAlias := ctx.taskAliases[taskName]; isAlias {
+		return canonicalName
+	}
+	return taskName
+}
+
+// validateAliases checks that no alias is also the name of a task or an alias of another task.
+func validateAliases(configs map[string]GenericExecConfig, taskNames []string) error {
+	aliasedTasks := make(map[string]string)
+	for _, taskName := range taskNames {
+		for _, alias := range configs[taskName].Aliases {
+			if _, isTask := configs[alias]; isTask {
+				return fmt.Errorf("task \"%s\": alias \"%s\" is also the name of a task", taskName, alias)
+			}
+			if otherTask, isAlias := aliasedTasks[alias]; isAlias {
+				return fmt.Errorf("task \"%s\": alias \"%s\" is also an alias of task \"%s\"", taskName, alias, otherTask)
+			}
+			aliasedTasks[alias] = taskName
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package genericexec
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_Aliases(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"echo": {
+			Name:           "echo",
+			Command:        "echo",
+			Args:           []string{"{{request \"greeting\"}}"},
+			SuccessMessage: "said {{StdOut}}",
+			Aliases:        []string{"legacy-echo", "say"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	if !sut.IsTaskConfigured("legacy-echo") || !sut.IsTaskConfigured("say") {
+		t.Errorf("Expected aliases to be configured tasks")
+	}
+	if sut.IsTaskConfigured("nope") {
+		t.Errorf("Expected an unknown name not to be a configured task")
+	}
+
+	values := url.Values{"greeting": []string{"hello"}}
+	canonical := <-sut.RunTask("echo", values)
+	aliased := <-sut.RunTask("legacy-echo", values)
+	if !aliased.Succeeded || aliased.Name != canonical.Name || aliased.StdOut != canonical.StdOut || aliased.Message != canonical.Message {
+		t.Errorf("Expected running by alias to behave like running by name, got %+v and %+v", aliased, canonical)
+	}
+
+	// Aliases follow ReplaceConfigs.
+	replaced := map[string]GenericExecConfig{"echo": taskConfigs["echo"]}
+	echo := replaced["echo"]
+	echo.Aliases = []string{"shout"}
+	replaced["echo"] = echo
+	if err := sut.ReplaceConfigs(replaced); err != nil {
+		t.Fatalf("Expected the replacement configs to be valid, got %v", err)
+	}
+	if sut.IsTaskConfigured("legacy-echo") || !sut.IsTaskConfigured("shout") {
+		t.Errorf("Expected the replacement configs' aliases to take effect")
+	}
+}
+
+func TestValidateConfigs_Aliases(t *testing.T) {
+	testCases := map[string]map[string]GenericExecConfig{
+		"alias \"other\" is also the name of a task": {
+			"test":  {Name: "test", Command: "test", Aliases: []string{"other"}},
+			"other": {Name: "other", Command: "test"},
+		},
+		"alias \"both\" is also an alias of task \"a\"": {
+			"a": {Name: "a", Command: "test", Aliases: []string{"both"}},
+			"b": {Name: "b", Command: "test", Aliases: []string{"both"}},
+		},
+	}
+	for expect, configs := range testCases {
+		if err := ValidateConfigs(configs); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected validation to fail with \"%s\", got %v", expect, err)
+		}
+	}
+}
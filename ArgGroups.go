@@ -0,0 +1,85 @@
+package genericexec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ArgGroup is a set of args that a task passes to its command together or not at all. See GenericExecConfig.ArgGroups.
+type ArgGroup struct {
+	// When is rendered like an arg template; the group is included unless it renders as "", "false" or "0", ignoring
+	// surrounding whitespace, as in {{request "tls"}}.
+	When string
+	// Args are the group's arg templates, rendered like the task's Args.
+	Args []string
+	// Position is how many of the task's Args come before the group's: 0, the default, puts the group first.
+	Position int
+	// ArgPatterns are regular expressions that the group's Args must match once rendered, like the task's
+	// ArgPatterns: ArgPatterns[0] is for Args[0], and so on.
+	ArgPatterns []string
+}
+
+// expandArgGroups returns args, the arg templates of a task, with those of each of groups whose When renders truthy
+// inserted at its Position. Groups at the same Position keep their order. argIndexes[i] is where args[i] ended up,
+// and groupIndexes[i] where the first of groups[i].Args did, or -1 if the group was left out.
+func expandArgGroups(args []string, groups []ArgGroup, argValues TemplateGetter) (expanded []string, argIndexes []int, groupIndexes []int, err error) {
+	argIndexes = make([]int, len(args))
+	for i := range argIndexes {
+		argIndexes[i] = i
+	}
+	if len(groups) == 0 {
+		return args, argIndexes, nil, nil
+	}
+	groupIndexes = make([]int, len(groups))
+	included := make([][]int, len(args)+1)
+	for ix, group := range groups {
+		groupIndexes[ix] = -1
+		rendered, err := RenderArgTemplates([]string{group.When}, argValues)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		switch strings.TrimSpace(rendered[0]) {
+		case "", "false", "0":
+			continue
+		}
+		// Args can differ by operating system, so a Position past the end means the end.
+		position := group.Position
+		if position > len(args) {
+			position = len(args)
+		}
+		included[position] = append(included[position], ix)
+	}
+
+	expanded = make([]string, 0, len(args))
+	for position, groupsHere := range included {
+		for _, ix := range groupsHere {
+			groupIndexes[ix] = len(expanded)
+			expanded = append(expanded, groups[ix].Args...)
+		}
+		if position < len(args) {
+			argIndexes[position] = len(expanded)
+			expanded = append(expanded, args[position])
+		}
+	}
+	return expanded, argIndexes, groupIndexes, nil
+}
+
+// validateArgGroups checks that each of a task's ArgGroups has a Position within its Args, and valid ArgPatterns
+// for its own Args.
+func validateArgGroups(execConfig GenericExecConfig) error {
+	for ix, group := range execConfig.ArgGroups {
+		if group.Position < 0 || group.Position > len(execConfig.Args) {
+			return fmt.Errorf("ArgGroups[%d] has Position %d with %d Args", ix, group.Position, len(execConfig.Args))
+		}
+		if len(group.ArgPatterns) > len(group.Args) {
+			return fmt.Errorf("ArgGroups[%d] has %d ArgPatterns for %d Args", ix, len(group.ArgPatterns), len(group.Args))
+		}
+		for _, pattern := range group.ArgPatterns {
+			if _, err := regexp.Compile(anchorArgPattern(pattern)); err != nil {
+				return fmt.Errorf("ArgGroups[%d]: %v", ix, err)
+			}
+		}
+	}
+	return nil
+}
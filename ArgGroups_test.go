@@ -0,0 +1,110 @@
+package genericexec
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_ArgGroups(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"connect": {
+			Name:      "connect",
+			Command:   "args",
+			Args:      []string{"connect", "{{request \"host\"}}"},
+			Reentrant: true,
+			ArgGroups: []ArgGroup{
+				{
+					When:     "{{request \"tls\"}}",
+					Args:     []string{"--tls", "--cert", "{{request \"cert\"}}", "--key", "{{request \"key\"}}"},
+					Position: 1,
+				},
+				{
+					When:     "{{if request \"verbose\"}}yes{{end}}",
+					Args:     []string{"-v"},
+					Position: 2,
+				},
+			},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	testCases := []struct {
+		values url.Values
+		expect string
+	}{
+		{url.Values{"host": {"db"}}, `["connect" "db"]`},
+		{url.Values{"host": {"db"}, "tls": {"false"}, "cert": {"c.pem"}}, `["connect" "db"]`},
+		{url.Values{"host": {"db"}, "tls": {"true"}, "cert": {"c.pem"}, "key": {"k.pem"}}, `["connect" "--tls" "--cert" "c.pem" "--key" "k.pem" "db"]`},
+		{url.Values{"host": {"db"}, "verbose": {"1"}}, `["connect" "db" "-v"]`},
+	}
+	for _, testCase := range testCases {
+		if result := <-sut.RunTask("connect", testCase.values); result.StdOut != testCase.expect {
+			t.Errorf("Expected args %s for %v, got %s", testCase.expect, testCase.values, result.StdOut)
+		}
+	}
+}
+
+func TestGenericExecManager_ArgGroups_ArgPatterns(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"connect": {
+			Name:        "connect",
+			Command:     "args",
+			Args:        []string{"connect", "{{request \"host\"}}"},
+			ArgPatterns: []string{"", `[\w.]+`},
+			Reentrant:   true,
+			ArgGroups: []ArgGroup{
+				{
+					When:        "{{request \"tls\"}}",
+					Args:        []string{"--cert", "{{request \"cert\"}}"},
+					ArgPatterns: []string{"", `[\w.]+`},
+					Position:    1,
+				},
+			},
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Fatalf("Expected ArgGroups' ArgPatterns to be valid, got %v", err)
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("connect", url.Values{"host": {"db"}, "tls": {"1"}, "cert": {"c.pem"}})
+	if !result.Succeeded || result.StdOut != `["connect" "--cert" "c.pem" "db"]` {
+		t.Errorf("Expected the group's args to pass its patterns, got %+v", result)
+	}
+	result = <-sut.RunTask("connect", url.Values{"host": {"db"}, "tls": {"1"}, "cert": {"../etc/shadow"}})
+	if result.Succeeded || !strings.Contains(result.StdErr, "ArgGroups[0]: argument 1") {
+		t.Errorf("Expected the group's arg to be rejected, got %+v", result)
+	}
+	result = <-sut.RunTask("connect", url.Values{"host": {"db"}, "cert": {"../etc/shadow"}})
+	if !result.Succeeded || result.StdOut != `["connect" "db"]` {
+		t.Errorf("Expected a group that was left out not to be checked, got %+v", result)
+	}
+}
+
+func TestValidateConfigs_ArgGroups(t *testing.T) {
+	err := ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", Args: []string{"a"}, ArgGroups: []ArgGroup{{When: "1", Position: 2}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "Position 2") {
+		t.Errorf("Expected a Position past the end of Args to fail validation, got %v", err)
+	}
+	err = ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", ArgGroups: []ArgGroup{{When: "1", Args: []string{"a"}, ArgPatterns: []string{"a", "b"}}}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "2 ArgPatterns for 1 Args") {
+		t.Errorf("Expected more ArgPatterns than Args in a group to fail validation, got %v", err)
+	}
+	err = ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", ArgGroups: []ArgGroup{{When: "1", Args: []string{"a"}, ArgPatterns: []string{"("}}}},
+	})
+	if err == nil {
+		t.Errorf("Expected an invalid group ArgPattern to fail validation")
+	}
+	err = ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", ArgGroups: []ArgGroup{{When: "{{if}}"}}},
+	})
+	if err == nil {
+		t.Errorf("Expected an invalid When template to fail validation")
+	}
+}
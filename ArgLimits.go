@@ -0,0 +1,74 @@
+package genericexec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// PlatformMaxArgBytes is a conservative limit on the combined size of a command, its arguments and its environment
+// for the current OS, suitable for GenericExecManager.MaxArgBytes.
+var PlatformMaxArgBytes = platformMaxArgBytes(runtime.GOOS)
+
+func platformMaxArgBytes(goos string) int {
+	switch goos {
+	case "windows":
+		// CreateProcess limits the command line to 32767 UTF-16 characters; the environment is separate.
+		return 32767
+	case "darwin", "freebsd", "netbsd", "openbsd", "dragonfly":
+		return 256 * 1024
+	default:
+		// Linux's ARG_MAX is a quarter of the stack size limit, which is 2MiB with the usual 8MiB stack.
+		return 2 * 1024 * 1024
+	}
+}
+
+// platformMaxArgStrlen is the most bytes, counting its terminator, that any one argument or environment variable can
+// have on goos, or 0 if only their total is limited. Linux's MAX_ARG_STRLEN is 32 pages.
+func platformMaxArgStrlen(goos string) int {
+	if goos == "linux" {
+		return 32 * 4096
+	}
+	return 0
+}
+
+// checkArgListSize returns an error if cmd, as it is about to be started, exceeds MaxArgBytes, or has an argument or
+// environment variable too long for the OS.
+func (ctx *GenericExecManager) checkArgListSize(cmd *exec.Cmd) error {
+	if ctx.MaxArgBytes <= 0 {
+		return nil
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	if size := argListSize(cmd.Path, cmd.Args, env); size > ctx.MaxArgBytes {
+		return fmt.Errorf("argument list too long: %d bytes of command, arguments and environment exceeds the limit of %d bytes", size, ctx.MaxArgBytes)
+	}
+	if maxStrlen := platformMaxArgStrlen(runtime.GOOS); maxStrlen > 0 {
+		for ix, arg := range cmd.Args {
+			if len(arg) >= maxStrlen {
+				return fmt.Errorf("argument list too long: argument %d is %d bytes, over the limit of %d bytes for any one argument", ix, len(arg), maxStrlen-1)
+			}
+		}
+		for _, variable := range env {
+			if len(variable) >= maxStrlen {
+				return fmt.Errorf("argument list too long: an environment variable is %d bytes, over the limit of %d bytes for any one variable", len(variable), maxStrlen-1)
+			}
+		}
+	}
+	return nil
+}
+
+// argListSize approximates what the OS counts against its limit on the size of a new process's arguments and
+// environment: every string plus its terminator.
+func argListSize(name string, args []string, env []string) int {
+	size := len(name) + 1
+	for _, list := range [][]string{args, env} {
+		for _, str := range list {
+			size += len(str) + 1
+		}
+	}
+	return size
+}
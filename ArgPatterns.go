@@ -0,0 +1,65 @@
+package genericexec
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// anchorArgPattern makes pattern match only a whole argument.
+func anchorArgPattern(pattern string) string {
+	return "^(?:" + pattern + ")$"
+}
+
+// checkArgPatterns returns an error describing the first of args that doesn't match its pattern in patterns, with
+// secrets redacted from it. An invalid pattern fails every argument it applies to, since the argument can't be
+// shown to be safe.
+func checkArgPatterns(patterns []string, args []string, secrets []string) error {
+	for i, pattern := range patterns {
+		if pattern == "" || i >= len(args) {
+			continue
+		}
+		compiled, err := regexp.Compile(anchorArgPattern(pattern))
+		if err != nil {
+			return fmt.Errorf("argument %d can't be checked: %v", i, err)
+		}
+		if !compiled.MatchString(args[i]) {
+			return fmt.Errorf("argument %d, %q, does not match the pattern %q", i, redact(args[i], secrets), pattern)
+		}
+	}
+	return nil
+}
+
+// hasArgPatterns reports whether execConfig has ArgPatterns, or any of its ArgGroups do.
+func hasArgPatterns(execConfig *GenericExecConfig) bool {
+	if len(execConfig.ArgPatterns) > 0 {
+		return true
+	}
+	for _, group := range execConfig.ArgGroups {
+		if len(group.ArgPatterns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRunArgPatterns checks renderedArgs, the args of a run as rendered, against its task's ArgPatterns and those
+// of the ArgGroups it included. argIndexes and groupIndexes are as returned by expandArgGroups.
+func checkRunArgPatterns(execConfig *GenericExecConfig, renderedArgs []string, argIndexes []int, groupIndexes []int, secrets []string) error {
+	taskArgs := make([]string, len(argIndexes))
+	for i, argIndex := range argIndexes {
+		taskArgs[i] = renderedArgs[argIndex]
+	}
+	if err := checkArgPatterns(execConfig.ArgPatterns, taskArgs, secrets); err != nil {
+		return err
+	}
+	for ix, group := range execConfig.ArgGroups {
+		if len(group.ArgPatterns) == 0 || groupIndexes[ix] < 0 {
+			continue
+		}
+		groupArgs := renderedArgs[groupIndexes[ix] : groupIndexes[ix]+len(group.Args)]
+		if err := checkArgPatterns(group.ArgPatterns, groupArgs, secrets); err != nil {
+			return fmt.Errorf("ArgGroups[%d]: %v", ix, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package genericexec
+
+import (
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_ArgPatterns(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:        "test",
+			Command:     "args",
+			Args:        []string{"--verbose", "{{request \"path\"}}", "{{request \"token\"}}"},
+			ArgPatterns: []string{"", `[\w/]+`, `[0-9a-f]+`},
+			SecretKeys:  []string{"token"},
+			Reentrant:   true,
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Fatalf("Expected ArgPatterns to be valid, got %v", err)
+	}
+	sut, logBuf, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"path": []string{"var/log"}, "token": []string{"c0ffee"}})
+	if !result.Succeeded || result.StdOut != `["--verbose" "var/log" "c0ffee"]` {
+		t.Errorf("Expected matching arguments to be accepted, got %+v", result)
+	}
+
+	for _, path := range []string{"../../etc/passwd", "logs; rm -rf /", "var/log\n"} {
+		result = <-sut.RunTask("test", url.Values{"path": []string{path}, "token": []string{"c0ffee"}})
+		if result.Succeeded || !strings.Contains(result.StdErr, "argument 1") || result.StdOut != "" {
+			t.Errorf("Expected the path %q to be rejected before the command ran, got %+v", path, result)
+		}
+	}
+
+	result = <-sut.RunTask("test", url.Values{"path": []string{"var/log"}, "token": []string{"not-hex-secret"}})
+	if result.Succeeded || !strings.Contains(result.StdErr, "argument 2, \"[REDACTED]\"") {
+		t.Errorf("Expected an invalid secret argument to be rejected without showing it, got %+v", result)
+	}
+	if strings.Contains(logBuf.String(), "not-hex-secret") {
+		t.Errorf("Expected the secret to stay out of the log, got \"%s\"", logBuf.String())
+	}
+
+	taskConfigs["test"] = GenericExecConfig{Name: "test", Command: "args", Args: []string{"a"}, ArgPatterns: []string{"a", "b"}}
+	if err := ValidateConfigs(taskConfigs); err == nil {
+		t.Error("Expected more ArgPatterns than Args to be invalid")
+	}
+	taskConfigs["test"] = GenericExecConfig{Name: "test", Command: "args", Args: []string{"a"}, ArgPatterns: []string{"("}}
+	if err := ValidateConfigs(taskConfigs); err == nil {
+		t.Error("Expected an invalid ArgPattern to be invalid")
+	}
+}
+
+func TestGenericExecManager_ArgPatterns_ArgGroups(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:        "test",
+			Command:     "args",
+			Args:        []string{"{{request \"path\"}}{{setValue \"renders\" (printf \"%v.\" (value \"renders\"))}}", "{{arg 1}}"},
+			ArgPatterns: []string{`[\w/]+`, `[\w/]+`},
+			ArgGroups:   []ArgGroup{{When: "1", Args: []string{"-v"}}},
+			Reentrant:   true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"path": []string{"var/log"}})
+	if !result.Succeeded || result.StdOut != `["-v" "var/log" "var/log"]` {
+		t.Errorf("Expected the args to be checked as rendered among the ArgGroups, got %+v", result)
+	}
+	if renders, _ := result.Values.Get("renders"); renders != "." {
+		t.Errorf("Expected the args to be rendered once, got %v renders", renders)
+	}
+
+	result = <-sut.RunTask("test", url.Values{"path": []string{"../etc"}})
+	if result.Succeeded || !strings.Contains(result.StdErr, "argument 0") {
+		t.Errorf("Expected the path to be rejected, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_ArgPatterns_CopyingCmdFactory(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:        "test",
+			Command:     "args",
+			Args:        []string{"{{request \"path\"}}{{setValue \"renders\" (printf \"%v.\" (value \"renders\"))}}"},
+			ArgPatterns: []string{`[\w/]+`},
+			Reentrant:   true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	cmdFactory := sut.CmdFactory
+	sut.CmdFactory = func(name string, argValues TemplateGetter, arg ...string) (*exec.Cmd, error) {
+		// A CmdFactory may well render a copy of the args it was given.
+		return cmdFactory(name, argValues, append([]string(nil), arg...)...)
+	}
+
+	result := <-sut.RunTask("test", url.Values{"path": []string{"var/log"}})
+	if !result.Succeeded || result.StdOut != `["var/log"]` {
+		t.Errorf("Expected the args to pass their patterns, got %+v", result)
+	}
+	if renders, _ := result.Values.Get("renders"); renders != "." {
+		t.Errorf("Expected the args to be rendered once, got %v renders", renders)
+	}
+}
@@ -0,0 +1,95 @@
+package genericexec
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+var errCPUSamplingUnsupported = errors.New("MaxCPURatio is only supported on Linux")
+
+// defaultCPURatioInterval is how long a task's CPU use is measured over at a time when its MaxCPURatio is set
+// without a CPURatioInterval.
+const defaultCPURatioInterval = 10 * time.Second
+
+// cpuGuard samples the CPU time of a task's process every interval, and calls stop the first time the CPU time it
+// used over one interval is more than maxRatio times the interval.
+type cpuGuard struct {
+	maxRatio float64
+	interval time.Duration
+	stop     func()
+	mutex    sync.Mutex
+	tripped  bool
+}
+
+func newCPUGuard(execConfig *GenericExecConfig, stop func()) *cpuGuard {
+	interval := execConfig.CPURatioInterval
+	if interval <= 0 {
+		interval = defaultCPURatioInterval
+	}
+	return &cpuGuard{maxRatio: execConfig.MaxCPURatio, interval: interval, stop: stop}
+}
+
+// watching returns a ProcessStarter that starts processes with start and samples each one's CPU time until it has
+// been waited for.
+func (guard *cpuGuard) watching(start ProcessStarter) ProcessStarter {
+	if start == nil {
+		start = startCmd
+	}
+	return func(cmd *exec.Cmd) (func() error, error) {
+		wait, err := start(cmd)
+		if err != nil {
+			return wait, err
+		}
+		stopSampling := guard.sample(cmd.Process.Pid)
+		return func() error {
+			defer stopSampling()
+			return wait()
+		}, nil
+	}
+}
+
+// sample checks the CPU time of process pid every interval until the returned function is called.
+func (guard *cpuGuard) sample(pid int) (stop func()) {
+	ticker := time.NewTicker(guard.interval)
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lastSampledAt := time.Now()
+		lastCPUTime, _ := processCPUTime(pid)
+		for {
+			select {
+			case <-ticker.C:
+			case <-stopped:
+				return
+			}
+			sampledAt := time.Now()
+			cpuTime, err := processCPUTime(pid)
+			if err != nil {
+				// The process is gone.
+				return
+			}
+			if float64(cpuTime-lastCPUTime) > guard.maxRatio*float64(sampledAt.Sub(lastSampledAt)) {
+				guard.mutex.Lock()
+				guard.tripped = true
+				guard.mutex.Unlock()
+				guard.stop()
+				return
+			}
+			lastSampledAt, lastCPUTime = sampledAt, cpuTime
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(stopped)
+		<-done
+	}
+}
+
+func (guard *cpuGuard) hasTripped() bool {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	return guard.tripped
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package genericexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const cpuSamplingSupported = true
+
+// clockTicksPerSecond is the unit of CPU times in /proc. It is 100 on every Linux architecture Go supports.
+const clockTicksPerSecond = 100
+
+// processCPUTime returns the user and system CPU time process pid has used so far, not counting its children.
+func processCPUTime(pid int) (time.Duration, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// The command name, in parentheses, can contain spaces, so count fields from after it. utime and stime are the
+	// 14th and 15th fields, the state being the 3rd.
+	closeParen := bytes.LastIndexByte(stat, ')')
+	if closeParen < 0 {
+		return 0, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	fields := bytes.Fields(stat[closeParen+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	var ticks int64
+	for _, field := range fields[11:13] {
+		value, err := strconv.ParseInt(string(field), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected format of /proc/%d/stat: %v", pid, err)
+		}
+		ticks += value
+	}
+	return time.Duration(ticks) * time.Second / clockTicksPerSecond, nil
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package genericexec
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_MaxCPURatio(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"runaway": {
+			Name:             "runaway",
+			Command:          "spin",
+			MaxCPURatio:      0.5,
+			CPURatioInterval: 300 * time.Millisecond,
+			Reentrant:        true,
+		},
+		"waiting": {
+			Name:             "waiting",
+			Command:          "sleep",
+			Args:             []string{"1s"},
+			MaxCPURatio:      0.5,
+			CPURatioInterval: 300 * time.Millisecond,
+			Reentrant:        true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runawayChan := sut.RunTask("runaway", url.Values{})
+	waitingChan := sut.RunTask("waiting", url.Values{})
+	select {
+	case result := <-runawayChan:
+		if result.FailureKind != FailureKindCPULimit || result.Succeeded {
+			t.Errorf("Expected the busy-looping task to be stopped for its CPU use, got %+v", result)
+		}
+		if result.UserTime+result.SystemTime < 100*time.Millisecond {
+			t.Errorf("Expected the busy-looping task's CPU time to be reported, got %v user and %v system", result.UserTime, result.SystemTime)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the busy-looping task to be stopped")
+	}
+
+	result := <-waitingChan
+	if result.FailureKind != FailureKindNone || !result.Succeeded {
+		t.Errorf("Expected the sleeping task to be left to finish, got %+v", result)
+	}
+	if cpuTime := result.UserTime + result.SystemTime; cpuTime >= result.ExecTime/2 {
+		t.Errorf("Expected the sleeping task to use little CPU time, got %v in %v", cpuTime, result.ExecTime)
+	}
+}
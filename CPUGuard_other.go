@@ -0,0 +1,13 @@
+//go:build !linux
+
+package genericexec
+
+import (
+	"time"
+)
+
+const cpuSamplingSupported = false
+
+func processCPUTime(pid int) (time.Duration, error) {
+	return 0, errCPUSamplingUnsupported
+}
@@ -0,0 +1,78 @@
+package genericexec
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// CheckCommands checks that the commands of every task in configs exist: that each Command, as it would be chosen
+// for this operating system, and each PreCommand, PostCommand, OnSuccessCommand and OnFailureCommand, is found on
+// the PATH if it is a bare name, or is an executable file if it is a path. It complements ValidateConfigs, to catch
+// deployment mistakes such as a binary that wasn't installed at startup, rather than on a task's first run. The
+// error names every task with a missing command. NewGenericExecManager doesn't call it; call it on the configs
+// before constructing the manager, or before ReplaceConfigs, to fail early.
+//
+// Commands containing "{{", which only a custom CmdFactory could render, can't be checked. They are skipped, and the
+// names of the tasks with any are returned in skipped, for the caller to note. Don't check tasks that a
+// CommandWrapper runs somewhere else, such as in a container, as their commands needn't exist where the manager
+// runs.
+func CheckCommands(configs map[string]GenericExecConfig) (skipped []string, err error) {
+	taskNames := make([]string, 0, len(configs))
+	for taskName := range configs {
+		taskNames = append(taskNames, taskName)
+	}
+	sort.Strings(taskNames)
+
+	var missing []error
+	for _, taskName := range taskNames {
+		execConfig := configs[taskName]
+		command, _ := commandForOS(execConfig, runtime.GOOS)
+		var skippedAny bool
+		for _, command := range []string{command, execConfig.PreCommand, execConfig.PostCommand, execConfig.OnSuccessCommand, execConfig.OnFailureCommand} {
+			if command == "" {
+				continue
+			}
+			if strings.Contains(command, "{{") {
+				skippedAny = true
+				continue
+			}
+			if _, err := exec.LookPath(command); err != nil {
+				missing = append(missing, fmt.Errorf("task \"%s\": %v", taskName, err))
+			}
+		}
+		if skippedAny {
+			skipped = append(skipped, taskName)
+		}
+	}
+	return skipped, errors.Join(missing...)
+}
+
+// NewCheckedGenericExecManager is NewGenericExecManager for callers that want a deployment mistake to fail startup:
+// it returns an error instead of a manager if execTaskConfigsByName fails ValidateConfigs or CheckCommands. The
+// manager it returns has RequireCommands set, so that ReplaceConfigs goes on checking commands.
+func NewCheckedGenericExecManager(execTaskConfigsByName map[string]GenericExecConfig, log *log.Logger, notifyCallback func(message string)) (*GenericExecManager, error) {
+	if err := ValidateConfigs(execTaskConfigsByName); err != nil {
+		return nil, err
+	}
+	execManager := NewGenericExecManager(execTaskConfigsByName, log, notifyCallback)
+	if err := execManager.checkCommands(execTaskConfigsByName); err != nil {
+		execManager.Shutdown()
+		return nil, err
+	}
+	execManager.RequireCommands = true
+	return execManager, nil
+}
+
+// checkCommands runs CheckCommands on configs for the manager, logging the tasks it skipped.
+func (ctx *GenericExecManager) checkCommands(configs map[string]GenericExecConfig) error {
+	skipped, err := CheckCommands(configs)
+	if len(skipped) > 0 {
+		ctx.logf(LogLevelWarn, "Could not check the commands of tasks %s, which are templates.", strings.Join(skipped, ", "))
+	}
+	return err
+}
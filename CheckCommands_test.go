@@ -0,0 +1,96 @@
+package genericexec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckCommands(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("No sh to find")
+	}
+	notExecutable := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configs := map[string]GenericExecConfig{
+		"by-name":            {Name: "by-name", Command: "sh"},
+		"by-path":            {Name: "by-path", Command: shell, PostCommand: "sh"},
+		"missing":            {Name: "missing", Command: "no-such-command-anywhere"},
+		"missing-pre":        {Name: "missing-pre", Command: "sh", PreCommand: "/no/such/pre-command"},
+		"not-executable":     {Name: "not-executable", Command: notExecutable},
+		"rendered-elsewhere": {Name: "rendered-elsewhere", Command: "{{request \"tool\"}}"},
+	}
+
+	skipped, err := CheckCommands(configs)
+	if len(skipped) != 1 || skipped[0] != "rendered-elsewhere" {
+		t.Errorf("Expected the task with a templated command to be reported as skipped, got %q", skipped)
+	}
+	if err == nil {
+		t.Fatal("Expected missing commands to be reported")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected one line for each of the 3 missing commands, got %q", lines)
+	}
+	for i, expect := range []string{"task \"missing\": ", "task \"missing-pre\": ", "task \"not-executable\": "} {
+		if !strings.HasPrefix(lines[i], expect) {
+			t.Errorf("Expected line %d to start with %s, got %s", i, expect, lines[i])
+		}
+	}
+	if !strings.Contains(lines[0], "no-such-command-anywhere") || !strings.Contains(lines[1], "/no/such/pre-command") {
+		t.Errorf("Expected the missing commands to be named, got %q", lines)
+	}
+
+	delete(configs, "missing")
+	delete(configs, "missing-pre")
+	delete(configs, "not-executable")
+	if _, err := CheckCommands(configs); err != nil {
+		t.Errorf("Expected commands that exist, and templated commands, to pass, got %v", err)
+	}
+}
+
+func TestNewCheckedGenericExecManager(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("No sh to find")
+	}
+	testLog, testLogBuf := newTestLogger()
+	missing := map[string]GenericExecConfig{"missing": {Name: "missing", Command: "no-such-command-anywhere"}}
+	if sut, err := NewCheckedGenericExecManager(missing, testLog, func(string) {}); sut != nil || err == nil || !strings.Contains(err.Error(), "no-such-command-anywhere") {
+		t.Errorf("Expected the missing command to fail construction, got %v, %v", sut, err)
+	}
+	invalid := map[string]GenericExecConfig{"invalid": {Name: "invalid"}}
+	if _, err := NewCheckedGenericExecManager(invalid, testLog, func(string) {}); err == nil {
+		t.Error("Expected invalid configs to fail construction")
+	}
+
+	configs := map[string]GenericExecConfig{
+		"shell":    {Name: "shell", Command: "sh"},
+		"rendered": {Name: "rendered", Command: "{{request \"tool\"}}"},
+	}
+	sut, err := NewCheckedGenericExecManager(configs, testLog, func(string) {})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !sut.RequireCommands {
+		t.Error("Expected RequireCommands to be set")
+	}
+	if !strings.Contains(testLogBuf.String(), "Could not check the commands of tasks rendered, which are templates.") {
+		t.Errorf("Expected the skipped task to be logged, got %s", testLogBuf.String())
+	}
+
+	if err := sut.ReplaceConfigs(missing); err == nil || !strings.Contains(err.Error(), "no-such-command-anywhere") {
+		t.Errorf("Expected ReplaceConfigs to reject the missing command, got %v", err)
+	}
+	if !sut.IsTaskConfigured("shell") || sut.IsTaskConfigured("missing") {
+		t.Error("Expected the rejected configs not to replace the old ones")
+	}
+	sut.RequireCommands = false
+	if err := sut.ReplaceConfigs(missing); err != nil {
+		t.Errorf("Expected ReplaceConfigs not to check commands without RequireCommands, got %v", err)
+	}
+}
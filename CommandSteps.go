@@ -0,0 +1,65 @@
+package genericexec
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// CommandStepResult is the outcome of a task's PreCommand, PostCommand, OnSuccessCommand or OnFailureCommand.
+type CommandStepResult struct {
+	ExitCode int
+	StdOut   string
+	StdErr   string
+}
+
+// prepareStep makes the Cmd for one of a task's PreCommand, PostCommand or hooks, with the same environment as the
+// task's Command.
+func (ctx *GenericExecManager) prepareStep(command string, args []string, execConfig *GenericExecConfig, argValues TemplateGetter, requestEnv map[string]string) (*exec.Cmd, error) {
+	cmd, err := ctx.CmdFactory(command, argValues, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.wrapCmd(cmd, argValues); err != nil {
+		return nil, err
+	}
+	if customizesEnv(execConfig, requestEnv) {
+		cmd.Env = resolveEnv(cmd.Env, execConfig, requestEnv)
+	}
+	if err := ctx.checkArgListSize(cmd); err != nil {
+		return nil, err
+	}
+	cmd.WaitDelay = execConfig.WaitDelay
+	return cmd, nil
+}
+
+// runStep runs one of a task's PreCommand, PostCommand or hooks. The error is non-nil unless the step exited 0.
+func (ctx *GenericExecManager) runStep(runContext context.Context, cmd *exec.Cmd, execConfig *GenericExecConfig) (*CommandStepResult, error) {
+	outBuffer := &bytes.Buffer{}
+	errBuffer := &bytes.Buffer{}
+	cmd.Stdout = outBuffer
+	cmd.Stderr = errBuffer
+	_, err := runCmd(runContext, cmd, nil, execConfig.CancelSignal, execConfig.WaitDelay)
+	step := &CommandStepResult{
+		ExitCode: exitCodeOf(err),
+		StdOut:   strings.TrimSpace(outBuffer.String()),
+		StdErr:   strings.TrimSpace(errBuffer.String()),
+	}
+	return step, err
+}
+
+// exitCodeOf returns the exit code of a process that ran with result err, or 1 if it didn't get as far as exiting.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	// It takes two(!) type assertions to get at the exit code.
+	if exitErr, isExitErr := err.(*exec.ExitError); isExitErr {
+		if waitStatus, isWaitStatus := exitErr.Sys().(syscall.WaitStatus); isWaitStatus {
+			return waitStatus.ExitStatus()
+		}
+	}
+	return 1
+}
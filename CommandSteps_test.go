@@ -0,0 +1,189 @@
+package genericexec
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_PreAndPostCommands(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"deploy": {
+			Name:        "deploy",
+			Command:     "echo",
+			Args:        []string{"deploying", "{{request \"version\"}}"},
+			PreCommand:  "echo",
+			PreArgs:     []string{"locking"},
+			PostCommand: "fail",
+			PostArgs:    []string{"unlocking"},
+		},
+		"blocked": {
+			Name:        "blocked",
+			Command:     "echo",
+			Args:        []string{"deploying"},
+			PreCommand:  "exit",
+			PreArgs:     []string{"3", "locked by someone else"},
+			PostCommand: "echo",
+			PostArgs:    []string{"unlocking"},
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("deploy", url.Values{"version": []string{"v2"}})
+	if !result.Succeeded || result.StdOut != "deploying v2" {
+		t.Errorf("Expected the command to succeed with its own output, got %+v", result)
+	}
+	if result.PreCommand == nil || result.PreCommand.StdOut != "locking" {
+		t.Errorf("Expected the PreCommand's output on the result, got %+v", result.PreCommand)
+	}
+	if result.PostCommand == nil || result.PostCommand.ExitCode != 2 || result.PostCommand.StdErr != "unlocking" {
+		t.Errorf("Expected the failed PostCommand's outcome on the result, got %+v", result.PostCommand)
+	}
+
+	result = <-sut.RunTask("blocked", url.Values{})
+	if result.Succeeded || result.ExitCode != 3 || result.FailureKind != FailureKindPreCommand {
+		t.Errorf("Expected a failed PreCommand to fail the run with its exit code, got %+v", result)
+	}
+	if result.StdOut != "" || result.PostCommand != nil {
+		t.Errorf("Expected neither the command nor the PostCommand to run after the PreCommand failed, got %+v", result)
+	}
+	if result.PreCommand == nil || result.PreCommand.StdOut != "locked by someone else" {
+		t.Errorf("Expected the PreCommand's output on the result, got %+v", result.PreCommand)
+	}
+	if !strings.Contains(testLogBuf.String(), "Command \"echo deploying\" was not run because its PreCommand exited 3!") {
+		t.Errorf("Expected the log to say why the command was not run, got \"%s\"", testLogBuf.String())
+	}
+}
+
+func TestGenericExecManager_PreAndPostCommandOrder(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:        "test",
+			Command:     "sleep",
+			Args:        []string{"200ms", "main"},
+			PreCommand:  "sleep",
+			PreArgs:     []string{"200ms", "pre"},
+			PostCommand: "sleep",
+			PostArgs:    []string{"200ms", "post"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	first := sut.RunTask("test", url.Values{})
+	second := sut.RunTask("test", url.Values{})
+	firstResult, secondResult := <-first, <-second
+	for _, result := range []GenericExecResult{firstResult, secondResult} {
+		if result.PreCommand.StdOut != "pre" || result.StdOut != "main" || result.PostCommand.StdOut != "post" {
+			t.Errorf("Expected pre, main and post output, got %+v", result)
+		}
+	}
+	// The second run queued behind all three of the first run's steps.
+	if secondResult.QueueWait < 500*time.Millisecond {
+		t.Errorf("Expected the second run to wait for the whole first run, but it waited %v", secondResult.QueueWait)
+	}
+}
+
+func TestGenericExecManager_PostCommandAfterTimeout(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "unlocked")
+	taskConfigs := map[string]GenericExecConfig{
+		"job": {
+			Name:        "job",
+			Command:     "sleep",
+			Args:        []string{"5s"},
+			PostCommand: "touch",
+			PostArgs:    []string{marker},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runContext, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	result := <-sut.RunTaskContext(runContext, "job", url.Values{})
+	if result.FailureKind != FailureKindTimeout {
+		t.Fatalf("Expected the run to time out, got %+v", result)
+	}
+	if result.PostCommand == nil || result.PostCommand.ExitCode != 0 {
+		t.Errorf("Expected PostCommand to run after the timeout, got %+v", result.PostCommand)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("Expected PostCommand to have created its marker file: %v", err)
+	}
+}
+
+func TestGenericExecManager_OnSuccessAndOnFailureCommands(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"job": {
+			Name:             "job",
+			Command:          "exit",
+			Args:             []string{"{{request \"code\"}}", "progress"},
+			OnSuccessCommand: "echo",
+			OnSuccessArgs:    []string{"done after", "{{StdOut}}"},
+			OnFailureCommand: "echo",
+			OnFailureArgs:    []string{"cleaning up", "{{request \"job\"}}", "after exit", "{{ExitCode}}"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("job", url.Values{"code": []string{"0"}, "job": []string{"j1"}})
+	if !result.Succeeded || result.OnFailureCommand != nil {
+		t.Errorf("Expected a successful run not to run OnFailureCommand, got %+v", result)
+	}
+	if result.OnSuccessCommand == nil || result.OnSuccessCommand.StdOut != "done after progress" {
+		t.Errorf("Expected OnSuccessCommand to run with the run's output, got %+v", result.OnSuccessCommand)
+	}
+
+	result = <-sut.RunTask("job", url.Values{"code": []string{"4"}, "job": []string{"j2"}})
+	if result.Succeeded || result.OnSuccessCommand != nil {
+		t.Errorf("Expected a failed run not to run OnSuccessCommand, got %+v", result)
+	}
+	if result.OnFailureCommand == nil || result.OnFailureCommand.StdOut != "cleaning up j2 after exit 4" {
+		t.Errorf("Expected OnFailureCommand to run with the request and the exit code, got %+v", result.OnFailureCommand)
+	}
+	if result.ExitCode != 4 {
+		t.Errorf("Expected the hook not to change the run's exit code, got %d", result.ExitCode)
+	}
+}
+
+func TestGenericExecManager_OnFailureCommandAfterTimeout(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "cleaned-up")
+	taskConfigs := map[string]GenericExecConfig{
+		"job": {
+			Name:             "job",
+			Command:          "sleep",
+			Args:             []string{"5s"},
+			OnFailureCommand: "touch",
+			OnFailureArgs:    []string{marker},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runContext, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	result := <-sut.RunTaskContext(runContext, "job", url.Values{})
+	if result.FailureKind != FailureKindTimeout {
+		t.Fatalf("Expected the run to time out, got %+v", result)
+	}
+	if result.OnFailureCommand == nil || result.OnFailureCommand.ExitCode != 0 {
+		t.Errorf("Expected OnFailureCommand to run after the timeout, got %+v", result.OnFailureCommand)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("Expected OnFailureCommand to have created its marker file: %v", err)
+	}
+}
+
+func TestValidateConfigs_HookArgs(t *testing.T) {
+	if err := ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", OnFailureCommand: "test", OnFailureArgs: []string{"{{StdErr}}"}},
+	}); err != nil {
+		t.Errorf("Expected hook args to be able to use the run's output, got %v", err)
+	}
+	if err := ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", Args: []string{"{{StdErr}}"}},
+	}); err == nil {
+		t.Errorf("Expected Args not to be able to use the run's output")
+	}
+}
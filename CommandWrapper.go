@@ -0,0 +1,26 @@
+package genericexec
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// wrapCmd changes cmd to run the manager's CommandWrapper, rendered with argValues, with cmd's command and arguments
+// after it.
+func (ctx *GenericExecManager) wrapCmd(cmd *exec.Cmd, argValues TemplateGetter) error {
+	if len(ctx.CommandWrapper) == 0 {
+		return nil
+	}
+	wrapper, err := RenderArgTemplates(ctx.CommandWrapper, argValues)
+	if err != nil {
+		return fmt.Errorf("could not render the CommandWrapper: %v", err)
+	}
+	path, err := exec.LookPath(wrapper[0])
+	if len(ctx.AllowedCommands) > 0 && !isCommandAllowed(path, ctx.AllowedCommands) {
+		return fmt.Errorf("command wrapper \"%s\" is not allowed to run because it is not in AllowedCommands", path)
+	}
+	// The wrapped command doesn't need to be found itself, in case the wrapper runs it somewhere else.
+	cmd.Args = append(append(wrapper, cmd.Path), cmd.Args[1:]...)
+	cmd.Path, cmd.Err = path, err
+	return nil
+}
@@ -0,0 +1,44 @@
+package genericexec
+
+import (
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_CommandWrapper(t *testing.T) {
+	envPath, err := exec.LookPath("env")
+	if err != nil {
+		t.Skip("No env to wrap commands with")
+	}
+	envPath, _ = filepath.Abs(envPath)
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:        "test",
+			Command:     "printenv",
+			Args:        []string{"WRAPPED_BY", "{{arg 0}}"},
+			PostCommand: "printenv",
+			PostArgs:    []string{"WRAPPED_BY"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"who": []string{"tests"}})
+	if result.StdOut != "WRAPPED_BY=\nWRAPPED_BY=" {
+		t.Errorf("Expected no wrapper by default, got \"%s\"", result.StdOut)
+	}
+
+	sut.CommandWrapper = []string{"env", "WRAPPED_BY={{request \"who\"}}"}
+	result = <-sut.RunTask("test", url.Values{"who": []string{"tests"}})
+	if result.StdOut != "WRAPPED_BY=tests\nWRAPPED_BY=tests" || result.PostCommand.StdOut != "WRAPPED_BY=tests" {
+		t.Errorf("Expected the command and PostCommand to be run by the wrapper, got %+v", result)
+	}
+
+	sut.AllowedCommands = []string{envPath + "-not"}
+	result = <-sut.RunTask("test", url.Values{})
+	if result.Succeeded || !strings.Contains(result.StdErr, "command wrapper") {
+		t.Errorf("Expected a wrapper that isn't allowed to fail the task, got %+v", result)
+	}
+}
@@ -0,0 +1,87 @@
+package genericexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadConfigsJSON decodes a JSON object of task configurations keyed by task name, such as
+// {"backup": {"Command": "/usr/local/bin/backup", "Args": ["{{request \"target\"}}"]}}, and validates them with
+// ValidateConfigs. Field names are those of GenericExecConfig, matched case-insensitively. A task's Name defaults
+// to its key. Durations such as WaitDelay are given either as strings time.ParseDuration accepts, such as "5s", or
+// as numbers of nanoseconds. Parser, SuccessFunc, NotificationKey and StartProcess are functions, so they can't be
+// set from JSON.
+func LoadConfigsJSON(r io.Reader) (map[string]GenericExecConfig, error) {
+	var documents map[string]map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&documents); err != nil {
+		return nil, fmt.Errorf("could not decode task configurations: %w", err)
+	}
+	for taskName, document := range documents {
+		if err := parseDurationFields(document); err != nil {
+			return nil, fmt.Errorf("could not decode task configurations: task \"%s\": %w", taskName, err)
+		}
+	}
+	// Decoding again from the rewritten documents gets the field matching, and the rejection of unknown fields, of
+	// encoding/json.
+	encoded, err := json.Marshal(documents)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode task configurations: %w", err)
+	}
+	var configs map[string]GenericExecConfig
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&configs); err != nil {
+		return nil, fmt.Errorf("could not decode task configurations: %w", err)
+	}
+
+	for taskName, config := range configs {
+		if config.Name == "" {
+			config.Name = taskName
+			configs[taskName] = config
+		}
+	}
+	if err := ValidateConfigs(configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// durationFields are the names of GenericExecConfig's time.Duration fields.
+var durationFields = func() []string {
+	var names []string
+	configType := reflect.TypeOf(GenericExecConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		if configType.Field(i).Type == reflect.TypeOf(time.Duration(0)) {
+			names = append(names, configType.Field(i).Name)
+		}
+	}
+	return names
+}()
+
+// parseDurationFields replaces the duration strings in one task's configuration document with nanoseconds, which
+// is how time.Duration decodes.
+func parseDurationFields(document map[string]json.RawMessage) error {
+	for key, value := range document {
+		var durationString string
+		if json.Unmarshal(value, &durationString) != nil {
+			continue
+		}
+		for _, field := range durationFields {
+			if !strings.EqualFold(key, field) {
+				continue
+			}
+			duration, err := time.ParseDuration(durationString)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			document[key] = json.RawMessage(strconv.FormatInt(int64(duration), 10))
+		}
+	}
+	return nil
+}
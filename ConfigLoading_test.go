@@ -0,0 +1,81 @@
+package genericexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigsJSON_RoundTrip(t *testing.T) {
+	configs := map[string]GenericExecConfig{
+		"backup": {
+			Name:           "backup",
+			Command:        "/usr/local/bin/backup",
+			Args:           []string{"--target", "{{request \"target\"}}"},
+			SuccessMessage: "Backed up {{request \"target\"}}",
+			ErrorMessage:   "Backup failed: {{StdErr}}",
+			WaitDelay:      5 * time.Second,
+			Env:            map[string]string{"BACKUP_QUIET": "1"},
+		},
+		"restart": {
+			Name:      "restart",
+			Command:   "systemctl",
+			Args:      []string{"restart", "{{request \"unit\"}}"},
+			Reentrant: true,
+		},
+	}
+	encoded, err := json.Marshal(configs)
+	if err != nil {
+		t.Fatalf("Unexpected error encoding configs: %v", err)
+	}
+
+	loaded, err := LoadConfigsJSON(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("Unexpected error loading configs: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, configs) {
+		t.Errorf("Expected loaded configs to match the originals, got %+v", loaded)
+	}
+}
+
+func TestLoadConfigsJSON_DefaultsNameToKey(t *testing.T) {
+	loaded, err := LoadConfigsJSON(strings.NewReader(`{"uptime": {"command": "uptime"}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error loading configs: %v", err)
+	}
+	if loaded["uptime"].Name != "uptime" || loaded["uptime"].Command != "uptime" {
+		t.Errorf("Expected the task's Name to default to its key, got %+v", loaded["uptime"])
+	}
+}
+
+func TestLoadConfigsJSON_DurationStrings(t *testing.T) {
+	document := `{"backup": {"Command": "backup", "Name": "5s", "waitDelay": "5s", "HookTimeout": "1m30s", "RestartBackoff": 250}}`
+	loaded, err := LoadConfigsJSON(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("Unexpected error loading configs: %v", err)
+	}
+	backup := loaded["backup"]
+	if backup.WaitDelay != 5*time.Second || backup.HookTimeout != 90*time.Second || backup.RestartBackoff != 250 || backup.Name != "5s" {
+		t.Errorf("Expected durations given as strings and as nanoseconds, got %+v", backup)
+	}
+}
+
+func TestLoadConfigsJSON_Invalid(t *testing.T) {
+	cases := map[string]string{
+		`{"a": {"Command": "true"}, "b": {"Args": ["x"]}}`:        `task "b": no command configured`,
+		`{"a": {"Command": "true", "Args": ["{{request \"x\""]}}`: `task "a":`,
+		`{"a": {"Command": "true", "ErrorMessage": "{{Nope}}"}}`:  `task "a":`,
+		`{"a": {"Command": "true", "Comand": "typo"}}`:            `unknown field`,
+		`["not", "an", "object"]`:                                 `could not decode`,
+		`{"a": {"Command": "true", "WaitDelay": "soon"}}`:         `task "a": WaitDelay: time: invalid duration`,
+	}
+	for document, expect := range cases {
+		_, err := LoadConfigsJSON(strings.NewReader(document))
+		if err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected an error containing %s loading %s, got %v", expect, document, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package genericexec
+
+import (
+	"context"
+)
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of parent carrying id. Tasks run with RunTaskContext or RunTaskWithOptions using
+// the returned context report id in their result's CorrelationID, and templates can render it with
+// "correlation_id", so that tasks can be traced back to the requests that triggered them.
+func WithCorrelationID(parent context.Context, id string) context.Context {
+	return context.WithValue(parent, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the ID attached to runContext by WithCorrelationID, or the empty string.
+func CorrelationID(runContext context.Context) string {
+	id, _ := runContext.Value(correlationIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,40 @@
+package genericexec
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_CorrelationID(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "echo",
+			Args:           []string{"{{correlation_id}}"},
+			SuccessMessage: "Request {{correlation_id}} echoed {{StdOut}}",
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+	runContext := WithCorrelationID(context.Background(), "req-42")
+
+	result := <-sut.RunTaskContext(runContext, "test", url.Values{})
+	if result.CorrelationID != "req-42" {
+		t.Errorf("Expected CorrelationID \"req-42\", got \"%s\"", result.CorrelationID)
+	}
+	if result.StdOut != "req-42" {
+		t.Errorf("Expected correlation_id to render in args, got StdOut \"%s\"", result.StdOut)
+	}
+	if result.Message != "Request req-42 echoed req-42" {
+		t.Errorf("Expected correlation_id to render in the message, got \"%s\"", result.Message)
+	}
+	if !strings.Contains(testLogBuf.String(), "Request req-42 echoed req-42") {
+		t.Errorf("Expected the message with its correlation ID to be logged, got \"%s\"", testLogBuf.String())
+	}
+
+	result = <-sut.RunTask("test", url.Values{})
+	if result.CorrelationID != "" || result.Message != "Request  echoed " {
+		t.Errorf("Expected no correlation ID without one on the context, got %+v", result)
+	}
+}
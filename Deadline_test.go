@@ -0,0 +1,48 @@
+package genericexec
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_DeadlineKey(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:        "test",
+			Command:     "sleep",
+			Args:        []string{"{{request \"duration\"}}", "done"},
+			DeadlineKey: "deadline",
+			Reentrant:   true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	future := time.Now().Add(time.Minute).Format(time.RFC3339)
+	result := <-sut.RunTask("test", url.Values{"duration": []string{"10ms"}, "deadline": []string{future}})
+	if !result.Succeeded || result.StdOut != "done" {
+		t.Errorf("Expected a run with a future deadline to complete, got %+v", result)
+	}
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	startedAt := time.Now()
+	result = <-sut.RunTask("test", url.Values{"duration": []string{"5s"}, "deadline": []string{past}})
+	if result.Succeeded || result.FailureKind != FailureKindTimeout {
+		t.Errorf("Expected a run with a past deadline to time out, got %+v", result)
+	}
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Errorf("Expected a run with a past deadline to fail fast, but it took %v", elapsed)
+	}
+
+	// RFC3339 has one-second precision, so this deadline is between 1s and 2s away.
+	soon := time.Now().Add(2 * time.Second).Format(time.RFC3339)
+	result = <-sut.RunTask("test", url.Values{"duration": []string{"10s"}, "deadline": []string{soon}})
+	if result.FailureKind != FailureKindTimeout || result.ExecTime > 3*time.Second {
+		t.Errorf("Expected a run still going at its deadline to be stopped, got %+v", result)
+	}
+
+	result = <-sut.RunTask("test", url.Values{"duration": []string{"10ms"}, "deadline": []string{"tomorrow"}})
+	if result.Succeeded || result.StdErr == "" {
+		t.Errorf("Expected an invalid deadline to fail the run, got %+v", result)
+	}
+}
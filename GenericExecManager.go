@@ -2,23 +2,220 @@ package genericexec
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"io/fs"
 	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/acarl005/stripansi"
 )
 
 type GenericExecManager struct {
 	log                   *log.Logger
+	configMutex           sync.RWMutex
 	execTaskConfigsByName map[string]GenericExecConfig
-	mutexQueues           map[string]chan mutexQueueMessage
+	taskAliases           map[string]string
+	mutexQueues           map[string]*commandQueue
+	isShutDown            bool
 	notifyCallback        func(message string)
 
+	activeMutex sync.Mutex
+	activeRuns  map[string]map[*taskRun]bool
+
+	stats runStats
+
+	lastResultsMutex sync.Mutex
+	lastResults      map[string]retainedResult
+	resultHistory    []retainedResult
+	historyNext      int
+
+	pauseMutex     sync.Mutex
+	pausedCommands map[string]chan struct{}
+
+	commandLocksMutex sync.Mutex
+	commandLocks      map[string]chan struct{}
+
+	processSlotsMutex sync.Mutex
+	processSlots      chan struct{}
+
+	taskSlotsMutex sync.Mutex
+	taskSlots      map[string]chan struct{}
+
+	observeMutex sync.Mutex
+	observeQueue observeQueue
+
+	outputMutex        sync.Mutex
+	outputBytesInUse   int64
+	outputBytesHighest int64
+
+	notifyMutex          sync.Mutex
+	pendingNotifications []string
+	notifyTimer          *time.Timer
+	lastNotifications    map[string]sentNotification
+
 	CmdFactory func(name string, argValues TemplateGetter, arg ...string) (*exec.Cmd, error)
+
+	// NotificationBatchWindow, when nonzero, collects notifications for up to this long after the first one
+	// arrives and delivers them to the notify callback in a single call, separated by newlines.
+	// NotificationBatchMax, when nonzero, delivers a batch early once it holds that many notifications.
+	NotificationBatchWindow time.Duration
+	NotificationBatchMax    int
+
+	// MaxArgBytes, when positive, makes tasks fail with a clear "argument list too long" error instead of a cryptic
+	// one from the OS when the command, its rendered arguments, and the environment exceed this many bytes, as the
+	// process would be started, after any CommandWrapper and Env. On Linux, so does any one argument or environment
+	// variable longer than the OS allows, 128KiB. Set it to PlatformMaxArgBytes for a limit appropriate to the
+	// current OS.
+	MaxArgBytes int
+
+	// AllowedCommands, when not empty, is the absolute paths of the only executables tasks may run. A task whose
+	// command resolves to anything else fails without being run.
+	AllowedCommands []string
+
+	// RequireCommands makes ReplaceConfigs reject configurations in which CheckCommands finds a missing command, as
+	// it rejects invalid ones. The tasks CheckCommands had to skip are logged. NewCheckedGenericExecManager sets it.
+	RequireCommands bool
+
+	// CommandWrapper, when not empty, is a command and arguments that every task's Command, PreCommand and
+	// PostCommand are run through, such as nice, timeout or sudo -u someuser: the process started is the wrapper,
+	// with the task's command and arguments appended to its own. Its arguments are templates rendered like Args.
+	// Tasks are still grouped by their own Command for reentrancy and IsCommandBusy, and with AllowedCommands,
+	// both the wrapper and the wrapped command must be allowed. Argv0 has no effect on wrapped commands.
+	CommandWrapper []string
+
+	// PrefixLogLines prefixes every line the manager logs about a task run with the task's name in brackets, so that
+	// multi-line messages from concurrent runs can be told apart. PrefixLogLinesWithPid adds the process ID too.
+	PrefixLogLines        bool
+	PrefixLogLinesWithPid bool
+
+	// LogEnvOnFailure adds the environment variables a failed task's configuration and request set, with their
+	// values, to the log. Variables the process inherited are not logged. As with everything else about them,
+	// the environment of Sensitive tasks is never logged.
+	LogEnvOnFailure bool
+
+	// LogJSON logs each completed task run as one line of JSON, a CompletionLogEntry, instead of the usual
+	// human-readable message, for log pipelines to consume. PrefixLogLines and LogEnvOnFailure don't apply to it.
+	// Other messages the manager logs are unaffected.
+	LogJSON bool
+
+	// LeveledLogger, if set, receives everything the manager logs, with a severity, instead of the *log.Logger
+	// given to NewGenericExecManager. ExitCodeLogLevel, if set, chooses the severity of the message logged when a
+	// task completes from its exit code, so that expected soft failures can be logged as warnings; by default,
+	// runs that succeed log at LogLevelInfo and runs that fail at LogLevelError.
+	LeveledLogger    LeveledLogger
+	ExitCodeLogLevel func(exitCode int) LogLevel
+
+	// Heartbeat, if set, is called every HeartbeatInterval while a task's process is running, with how long it has
+	// been running. Unless ObserverQueueSize is set, it is never called after the run completes.
+	Heartbeat         func(taskName string, elapsed time.Duration)
+	HeartbeatInterval time.Duration
+
+	// ResultTransformer, if set, is applied to the result of every task run just before it is delivered, including
+	// results for tasks that could not be started. Whatever it returns is what OnResult and the caller see.
+	// Notifications, logging and the counts in Stats have already happened by then, based on the untransformed
+	// result, so a transformer that changes Succeeded doesn't change what Stats report.
+	ResultTransformer func(result GenericExecResult) GenericExecResult
+
+	// TemplateRenderTimeout, when positive, bounds how long rendering any one of a task's templates can take. A task
+	// whose argument templates take longer fails without being run, and a message template that takes longer is
+	// reported like any other message template error. This protects callers from templates that are accidentally,
+	// or deliberately, very slow, such as nested ranges over large request values. A template that times out
+	// can't be stopped, so it goes on using CPU in the background until it finishes.
+	TemplateRenderTimeout time.Duration
+
+	// TemplateEnvAllowlist, when not nil, is the only environment variables that templates can read with "env".
+	// Other variables render as the empty string. It applies to the templates of the manager's task runs, which a
+	// CmdFactory renders with the TemplateGetter it is passed. Templates rendered with any other getter, as by calling
+	// RenderArgTemplates with one of your own, have no manager to ask, so they can read every variable.
+	TemplateEnvAllowlist []string
+
+	// SecretProvider, if set, supplies the secrets that templates fetch with "secret", as in {{secret "db-password"}},
+	// when they are rendered. Fetched secrets are redacted like SecretKeys values. A secret that can't be fetched
+	// fails the run, or for a message template, is reported like any other message template error.
+	SecretProvider SecretProvider
+
+	// Clock, if set, is what templates read the current time from with "now", instead of time.Now, so that tests
+	// can fix it.
+	Clock func() time.Time
+
+	// EnqueueTimeout, when positive, bounds how long RunTask waits for room in a non-reentrant command's queue when
+	// it is full. A task that can't be queued in time is not run; its result has FailureKind
+	// FailureKindEnqueueTimeout. A run whose context is done while it waits fails as cancelled or timed out
+	// instead. By default RunTask waits as long as it takes, unless the command is paused: then there is no telling
+	// when room will come, so a task that finds the queue full is rejected the same way right away.
+	EnqueueTimeout time.Duration
+
+	// MaxProcesses, when positive, caps how many task runs, of any task, have processes running at once, counting a
+	// run's PreCommand, PostCommand and hooks as part of it. It is a safety valve against fork storms, on top of any
+	// per-task limits. A run that would exceed it waits, for at most EnqueueTimeout if that is set; a run that
+	// can't start in time fails with FailureKind FailureKindProcessLimit. Stats reports the current count.
+	MaxProcesses int
+
+	// OutputMemoryBudget, when positive, bounds the total bytes of output the manager buffers for all running tasks
+	// together. Once the budget is used up, further output from any task is discarded, as with MaxOutputBytes, until
+	// running tasks complete and free their share. Output is counted against the budget only while its task runs;
+	// the result handed to the caller is the caller's to keep.
+	OutputMemoryBudget int64
+
+	// ObserverQueueSize, when positive, keeps slow observer callbacks from holding up tasks: OnResult, OnMatch,
+	// Heartbeat and RunOptions.OnOutputLine are called from a separate goroutine through a queue of up to this many
+	// pending calls, one at a time and in order. Calls that find the queue full are dropped and counted in Stats,
+	// and those about a run's output, to OnOutputLine and OnMatch, also in DroppedOutputCallbacks on its result, so
+	// that a consumer of the stream can tell it has gaps. Queued calls can happen after the run they are about has
+	// completed. By default, these callbacks are called synchronously, and a slow one delays the task. OutputWriter,
+	// ResultTransformer and the notify callback are always synchronous.
+	ObserverQueueSize int
+
+	// OnMatch, if set, is called with the task name, the pattern and the line each time a line of a task's output
+	// matches one of its WatchPatterns, while the task runs. A line matching several patterns is reported once
+	// for each.
+	OnMatch func(taskName string, pattern string, line string)
+
+	// TrackLastResult makes the manager remember the most recent result of each task, by name, for LastResult.
+	TrackLastResult bool
+
+	// ResultHistorySize, when positive, is how many of the most recent results of any task the manager keeps for
+	// RecentResults. To bound the memory they take, the output kept in each is cut off at ResultHistoryOutputBytes
+	// per stream, 4096 bytes by default.
+	ResultHistorySize        int
+	ResultHistoryOutputBytes int
+
+	// CompressRetainedOutput gzips the output of results kept for LastResult and RecentResults, which decompress it
+	// again. It saves memory for tasks with large, compressible output, at the cost of CPU time each time a result
+	// is kept or read. Results delivered to callers are never compressed.
+	CompressRetainedOutput bool
+
+	// OnResult, if set, is called with the result of every task run just before the result is delivered,
+	// including results for tasks that could not be started.
+	OnResult func(result GenericExecResult)
+
+	// BeforeRun, if set, is called on the caller's goroutine each time a task run is requested, before any of its
+	// templates are rendered, with the task name as requested and the run's RunValues, for example to stash values
+	// that the task's templates read with "value" or that OnResult reads from the result's Values.
+	BeforeRun func(taskName string, values *RunValues)
+}
+
+// OSCommand is a task's command and arguments for one operating system. See GenericExecConfig.OSCommands.
+type OSCommand struct {
+	Command string
+	Args    []string
 }
 
 type GenericExecManagerInterface interface {
@@ -32,161 +229,1615 @@ type GenericExecConfig struct {
 	SuccessMessage string
 	ErrorMessage   string
 	Reentrant      bool
+
+	// Aliases are other names the task can be run by, such as names callers used for it in the past. A task run by
+	// an alias behaves exactly as if run by its own name, including the Name in its result. An alias can't also be
+	// the name of a task, or an alias of another.
+	Aliases []string
+
+	// WindowsCommand and WindowsArgs, if WindowsCommand is set, are run on Windows in place of Command and Args.
+	// OSCommands does the same for any operating system, by its runtime.GOOS value, and takes precedence. Once
+	// chosen, the command replaces Command everywhere, including in grouping non-reentrant tasks by Command and in
+	// IsCommandBusy.
+	WindowsCommand string
+	WindowsArgs    []string
+	OSCommands     map[string]OSCommand
+
+	// MessagesByExitCode gives message templates for specific exit codes. When a run's command exits with one of
+	// them, its template is used instead of SuccessMessage or ErrorMessage, whether or not the run succeeded.
+	MessagesByExitCode map[int]string
+
+	// RawMessageOutput passes StdOut and StdErr to the SuccessMessage and ErrorMessage templates without
+	// trimming leading and trailing whitespace. The StdOut and StdErr fields of the result are still trimmed.
+	RawMessageOutput bool
+
+	// CollapseCarriageReturns cleans up the StdOut and StdErr of tools that draw progress bars or counters by writing
+	// a carriage return to go back over a line: of each line, only what was written after its last carriage return
+	// is kept, as a terminal would end up showing it. Line endings of "\r\n" become "\n". StdOutBytes and StdErrBytes,
+	// streamed output and StdOutHash are left as the task wrote them. By default, output is kept as is.
+	CollapseCarriageReturns bool
+
+	// MessageOutput chooses what the Output function renders in the SuccessMessage and ErrorMessage templates:
+	// "stdout", the default, "stderr", or "combined" for stdout followed by stderr on the next line. Because they
+	// are read from separate pipes, combined output is not interleaved in the order it was written.
+	MessageOutput string
+
+	// SuppressRepeatNotifications, for tasks run periodically, sends a run's notification only if it differs from
+	// the last one the task sent: "message" compares the rendered notification, and "output" compares the run's
+	// exit code and output instead, so that a notification that renders the time or duration still counts as
+	// unchanged. NotificationKey, if set, returns what to compare in their place. RepeatNotificationInterval, if
+	// nonzero, sends an unchanged notification anyway once that long has passed since the last one was sent, as a
+	// heartbeat. A suppressed notification is still the result's Message, with NotificationSuppressed set.
+	SuppressRepeatNotifications string
+	NotificationKey             func(result GenericExecResult, message string) string `json:"-"`
+	RepeatNotificationInterval  time.Duration
+
+	// WaitDelay bounds how long to wait, once the task's process has exited or been killed, for its output to be
+	// closed. Without it, a grandchild process that inherited stdout or stderr can keep the task from completing
+	// until the grandchild exits. A process that exited 0 still succeeds when WaitDelay cuts its output short. See
+	// exec.Cmd.WaitDelay.
+	// When CancelSignal is set, WaitDelay is also how long the process has to exit after receiving it before it
+	// is killed, 10s if WaitDelay is not set.
+	WaitDelay time.Duration
+
+	// HashOutput sets StdOutHash on the result to the hex-encoded SHA-256 of everything written to stdout,
+	// before any trimming.
+	HashOutput bool
+
+	// MaxOutputBytes, when positive, is the most of each of stdout and stderr that is kept in memory for the result.
+	// Output past the limit is discarded and the result's OutputTruncated field is set; the process is not stopped.
+	MaxOutputBytes int
+
+	// TailLines, when positive, keeps only the last this many lines of each of stdout and stderr for the result,
+	// discarding earlier lines as the task writes more, and sets the result's OutputTruncated field if any were
+	// discarded. Combined with MaxOutputBytes, the most recent output within the byte limit is kept.
+	TailLines int
+
+	// TruncateFrom chooses which end of output past MaxOutputBytes is discarded: "tail", the default, keeps the
+	// first MaxOutputBytes, and "head" keeps the last, as TailLines always does. TruncationMarker, if set, is added
+	// to truncated output where the discarded output was, with ${bytes} and ${lines} replaced by how many bytes
+	// were discarded and how many newlines they contained, as in "\n...(truncated ${bytes} bytes)", in StdOut and
+	// StdErr of the result only. The marker doesn't count toward MaxOutputBytes.
+	TruncateFrom     string
+	TruncationMarker string
+
+	// FailOnOutputBytes, when positive, stops the task as if its run were cancelled once it has written more than this
+	// many bytes to stdout and stderr combined, and reports it as failed with FailureKind FailureKindOutputLimit,
+	// however it exits. Use it as a guardrail for commands that should never produce much output.
+	FailOnOutputBytes int
+
+	// MaxCPURatio, when positive, stops a task that looks stuck in a busy loop, as if its run were cancelled, and
+	// reports it as failed with FailureKind FailureKindCPULimit, however it exits. The CPU time the task's process
+	// uses is measured over each CPURatioInterval, 10s by default, and the task is stopped the first time it is more
+	// than MaxCPURatio times the interval. A ratio above 1 allows for processes using several cores. Only the process
+	// itself is measured, not its children, such as the commands a shell runs. This is only supported on Linux.
+	MaxCPURatio      float64
+	CPURatioInterval time.Duration
+
+	// CancelSignal is sent to the task's process when a run started with RunTaskContext is cancelled. A process
+	// that hasn't exited WaitDelay, or 10s, after that is killed, so that one ignoring the signal can't hold up its
+	// queue forever. The default, zero, kills the process outright.
+	CancelSignal syscall.Signal
+
+	// OmitEmptyArgs drops any Args that render to the empty string, such as a flag whose template only produces
+	// output when a request value is present, instead of passing them to the command as empty arguments.
+	OmitEmptyArgs bool
+
+	// ArgPatterns are regular expressions that Args must match once rendered, in the same order: ArgPatterns[0] is
+	// for Args[0], and so on. An empty pattern, or one missing from the end, accepts anything. Each pattern must
+	// match the whole argument. A task with an argument that doesn't match fails without being run, for example to
+	// keep paths taken from requests from containing "..": `[^.]*(\.[^.]+)*`.
+	ArgPatterns []string
+
+	// ArgGroups are sets of args, such as "--tls", "--cert", "{{request \"cert\"}}", that are passed to the command
+	// together, or not at all, depending on the group's When template. They are inserted among the rendered Args
+	// without affecting which of the Args ArgPatterns apply to, and each group has ArgPatterns of its own.
+	ArgGroups []ArgGroup
+
+	// PlaceholderSyntax renders Args, Argv0, Stdin, PreArgs and PostArgs with simple placeholders instead of as
+	// templates: ${name} is replaced by the request value name, $$ by a literal $, and any other text, including a
+	// $ not followed by { or $, is used as is. SuccessMessage and ErrorMessage are still templates.
+	PlaceholderSyntax bool
+
+	// MaxConcurrent, when positive, is the most runs of this task, by name, that execute at once. Further runs wait
+	// for one to finish. It is independent of other tasks with the same Command. Non-reentrant tasks never run more
+	// than one at a time anyway.
+	MaxConcurrent int
+
+	// Restart is whether SuperviseTask starts the task again after it exits: "never", the default, "on-failure" for
+	// when a run does not succeed, or "always". It has no effect on tasks run any other way.
+	Restart string
+	// RestartBackoff is how long SuperviseTask waits before the first restart. Each restart after that waits twice as
+	// long as the one before, up to RestartBackoffMax, until a run lasts at least RestartBackoffMax, which starts the
+	// backoff over. RestartBackoff defaults to a second and RestartBackoffMax to a minute.
+	RestartBackoff    time.Duration
+	RestartBackoffMax time.Duration
+
+	// Stdin, if set, is rendered like an arg template and written to the task's process on its standard input.
+	Stdin string
+
+	// Argv0, if set, is rendered like an arg template and passed to the process as its argv[0] instead of the
+	// command's path, for multi-call binaries like busybox and for tidier process listings.
+	Argv0 string
+
+	// SuccessStderrPattern and FailureStderrPattern are regular expressions that, when they match the task's
+	// trimmed StdErr, decide whether a run succeeded regardless of its exit code. FailureStderrPattern takes
+	// precedence when both match. Success decides between SuccessMessage and ErrorMessage and sets the result's
+	// Succeeded field; the exit code is always reported as is.
+	SuccessStderrPattern string
+	FailureStderrPattern string
+
+	// WatchPatterns are regular expressions matched against each line the task writes to stdout or stderr as it
+	// runs. Each match is reported to the manager's OnMatch, for example to learn that a long-running process is
+	// ready without waiting for it to exit.
+	WatchPatterns []string
+
+	// LineDelimiter, if set, is the single byte that ends each line of the task's output for WatchPatterns
+	// and RunOptions.OnOutputLine in place of a newline, such as "\x00" for find -print0 or "\r" for progress
+	// output that redraws a line. It does not affect StdOut, StdErr or TailLines.
+	LineDelimiter string
+
+	// Sensitive keeps the task's command line and output out of the log, even when it fails. Output is still
+	// returned in the result and available to message templates, but since those can quote it, notifications are
+	// left out of the log too.
+	Sensitive bool
+
+	// Defaults are request values for keys a request doesn't have a value for, or has only an empty value for,
+	// such as a default region. Templates see them just like values from the request.
+	Defaults map[string]string
+
+	// SecretKeys names request values that are secrets, such as passwords passed on stdin. Wherever they appear in
+	// the log, notifications and the result's StdInSent, their values are replaced with [REDACTED]. The task's
+	// output in the result is left as it is.
+	SecretKeys []string
+
+	// Env sets environment variables for the task's process, in addition to those it inherits.
+	// Values from a request's EnvTemplateGetter take precedence over these, and these take precedence over
+	// inherited variables of the same name.
+	Env map[string]string
+	// UnsetEnv names inherited environment variables to remove from the task's process, such as credentials the
+	// manager itself holds. Env and the request can still set them.
+	UnsetEnv []string
+
+	// PreCommand and PostCommand, if set, are run with their own PreArgs and PostArgs immediately before and after
+	// Command, in the same queue slot, so that setup and teardown are never interleaved with other runs of Command.
+	// When PreCommand fails, Command and PostCommand are not run and the run fails with FailureKind
+	// FailureKindPreCommand and PreCommand's exit code. PostCommand is run whether or not Command succeeds, even
+	// after it timed out or was cancelled, bounded by HookTimeout rather than the run's context, and its outcome
+	// does not affect the run's. Their output is reported separately, in PreCommand and PostCommand on the
+	// result, and never in StdOut and StdErr.
+	PreCommand  string
+	PreArgs     []string
+	PostCommand string
+	PostArgs    []string
+
+	// OnSuccessCommand, if set, is run with OnSuccessArgs after Command if the run succeeded, and OnFailureCommand
+	// with OnFailureArgs if it failed, in the same queue slot, after PostCommand. Only runs in which Command was
+	// started get either. Besides everything available to Args, their args templates can use StdOut, StdErr and
+	// ExitCode of the run, as in {{StdOut}}. Like PostCommand, their outcome doesn't affect the run's, and is
+	// reported in OnSuccessCommand or OnFailureCommand on the result. They still run after a run that timed out or
+	// was cancelled, so like PostCommand they aren't stopped by the run's context; HookTimeout, 30s by default,
+	// bounds them instead.
+	OnSuccessCommand string
+	OnSuccessArgs    []string
+	OnFailureCommand string
+	OnFailureArgs    []string
+	HookTimeout      time.Duration
+
+	// Namespaces, on Linux, starts the task's process in new namespaces of the listed kinds, isolating it from
+	// the host: any of "mount", "network", "pid", "uts", "ipc" and "user". Creating most kinds of namespace
+	// requires privileges, such as running as root or CAP_SYS_ADMIN; when the process can't be started in them, the
+	// task fails and says why in its StdErr. On other platforms, tasks with Namespaces always fail.
+	Namespaces []string
+
+	// DeadlineKey, if set, is the name of a request value that can give an absolute deadline for a run, in RFC3339
+	// format. The run is cancelled as if by RunTaskContext when the deadline passes, and fails with FailureKind
+	// FailureKindTimeout without being started if it has already passed by the time the run would start.
+	// A value that isn't a valid RFC3339 time fails the run.
+	DeadlineKey string
+
+	// Labels tag the task for routing and filtering downstream, for example by team or severity. Each run's result
+	// has its own copy of them, and templates can render them with "label", as in {{label "team"}}.
+	Labels map[string]string
+
+	// Parser, if set, is called with the StdOut and StdErr of each successful run of the task, or with SuccessFunc,
+	// of each run that completed, before SuccessFunc is called. Its return values become the Parsed and ParseError
+	// fields of the result; a parse error does not change the exit code.
+	Parser func(stdout, stderr string) (interface{}, error) `json:"-"`
+
+	// SuccessFunc, if set, decides whether each run of the task that completed succeeded, in place of the exit code
+	// and SuccessStderrPattern and FailureStderrPattern. The result it is given is complete but for the message,
+	// with Succeeded set by those built-in rules. Runs with a FailureKind always fail, without calling it.
+	SuccessFunc func(result GenericExecResult) bool `json:"-"`
+
+	// StartProcess, if set, is used to start the task's process in place of exec.Cmd.Start, so that it can be run
+	// some other way, such as attached to a pseudo-terminal with the ptyexec package. PreCommand and PostCommand
+	// are always started the usual way.
+	StartProcess ProcessStarter `json:"-"`
 }
 
 type GenericExecResult struct {
 	Name     string
 	ExitCode int
-	StdOut   string
-	StdErr   string
-	Message  string
+	// Succeeded is whether the run counts as a success: normally, whether it exited 0.
+	// See GenericExecConfig.SuccessStderrPattern.
+	Succeeded bool
+	StdOut    string
+	StdErr    string
+	Message   string
+	// Values is the run's RunValues: what RunOptions.Values, BeforeRun and the task's templates stored in it.
+	Values *RunValues
+
+	// NotificationSuppressed is whether Message was not sent because it repeated the task's last notification.
+	// See GenericExecConfig.SuppressRepeatNotifications.
+	NotificationSuppressed bool
+
+	// StdOutBytes and StdErrBytes are exactly what the task wrote, for output that isn't text or must not be trimmed.
+	StdOutBytes []byte
+	StdErrBytes []byte
+
+	// Parsed and ParseError are the results of the task's Parser, if it has one and the task succeeded.
+	Parsed     interface{}
+	ParseError error
+
+	// QueueWait is how long a non-reentrant task waited in its queue for other runs to finish before starting, or a
+	// reentrant task with MaxConcurrent waited for a slot. It is otherwise zero for reentrant tasks. ExecTime is
+	// how long the task's process took once started.
+	QueueWait time.Duration
+	ExecTime  time.Duration
+	// UserTime and SystemTime are the CPU time the task's process used, as reported by the operating system once it
+	// exited. Compared with ExecTime, they tell work that is CPU-bound from work that mostly waits.
+	UserTime   time.Duration
+	SystemTime time.Duration
+
+	// StdInSent is what the task's Stdin template rendered and was written to the process, with SecretKeys values
+	// redacted and cut off at MaxOutputBytes like each output stream.
+	StdInSent string
+
+	// ResolvedEnv is the whole environment the task's process was started with, as NAME=value, with SecretKeys
+	// values redacted. It is only set when the task's Env or UnsetEnv or the request's environment changed what
+	// the process inherits; otherwise the process saw the manager's own environment.
+	ResolvedEnv []string
+
+	// StdOutHash is set when the task is configured with HashOutput.
+	StdOutHash string
+
+	// OutputTruncated is set when some of the task's output was discarded because of the task's MaxOutputBytes or
+	// TailLines, or the manager's OutputMemoryBudget. Output streamed with RunOptions is never truncated.
+	OutputTruncated bool
+
+	// StreamError is the error that stopped output being written to RunOptions.OutputWriter, if one did.
+	StreamError error
+
+	// StdinError is the error that stopped RunOptions.Stdin being read, if one did. The run fails if there is one.
+	StdinError error
+
+	// DroppedOutputCallbacks counts the calls to RunOptions.OnOutputLine and OnMatch about this run's output that
+	// were dropped because the manager's ObserverQueueSize queue was full.
+	DroppedOutputCallbacks int64
+
+	// PreCommand and PostCommand are the outcomes of the task's PreCommand and PostCommand, when they were run.
+	PreCommand  *CommandStepResult
+	PostCommand *CommandStepResult
+
+	// OnSuccessCommand and OnFailureCommand are the outcomes of the task's OnSuccessCommand or OnFailureCommand,
+	// when one was run.
+	OnSuccessCommand *CommandStepResult
+	OnFailureCommand *CommandStepResult
+
+	// Labels is a copy of the task's Labels.
+	Labels map[string]string
+
+	// CorrelationID is the ID attached to the context the task was run with, if any. See WithCorrelationID.
+	CorrelationID string
+
+	// FailureKind says why the task failed when the reason is more specific than its exit code.
+	FailureKind FailureKind
+
+	// sensitive is the Sensitive setting of the configuration the run used, for errors made from the result.
+	sensitive bool
+
+	// taskName is the name the task is configured under, which LastResult and SuppressRepeatNotifications keep
+	// track of tasks by. Name may be shared between tasks, or empty.
+	taskName string
 }
 
-type mutexQueueMessage struct {
+// FailureKind classifies the reasons a task can fail other than its command exiting nonzero.
+type FailureKind string
+
+const (
+	FailureKindNone FailureKind = ""
+	// FailureKindShuttingDown means the task was not started because the manager had been shut down.
+	FailureKindShuttingDown FailureKind = "rejected: shutting down"
+	// FailureKindEnqueueTimeout means the task was not started because its queue stayed full for EnqueueTimeout, or
+	// was full while its command was paused.
+	FailureKindEnqueueTimeout FailureKind = "rejected: queue full"
+	// FailureKindPreCommand means the task's command was not run because its PreCommand failed.
+	FailureKindPreCommand FailureKind = "aborted: pre-command failed"
+	// FailureKindTimeout means the task was stopped, or not started, because the deadline of the context it was run
+	// with passed.
+	FailureKindTimeout FailureKind = "timed out"
+	// FailureKindCancelled means the task was stopped, or not started, because the context it was run with was
+	// cancelled.
+	FailureKindCancelled FailureKind = "cancelled"
+	// FailureKindOutputLimit means the task was stopped because its output exceeded FailOnOutputBytes.
+	FailureKindOutputLimit FailureKind = "stopped: too much output"
+	// FailureKindProcessLimit means the task was not started because the manager's MaxProcesses stayed reached for
+	// EnqueueTimeout.
+	FailureKindProcessLimit FailureKind = "rejected: too many processes"
+	// FailureKindCPULimit means the task was stopped because it used more CPU time than its MaxCPURatio allows.
+	FailureKindCPULimit FailureKind = "stopped: too much CPU"
+	// FailureKindUnrouted means a ManagerRouter had no manager to run the task with.
+	FailureKindUnrouted FailureKind = "rejected: no manager"
+	// FailureKindNotFound, FailureKindPermission and FailureKindStart mean the task's process could not be started:
+	// because its command doesn't exist, because it isn't executable or can't be accessed, or for any other reason,
+	// such as the executable being written to at the time. The process never ran, so a StdErr is made up saying why.
+	FailureKindNotFound   FailureKind = "not started: command not found"
+	FailureKindPermission FailureKind = "not started: permission denied"
+	FailureKindStart      FailureKind = "not started"
+)
+
+// taskRun is everything needed to execute one invocation of a task, whether it runs right away or waits in a queue.
+type taskRun struct {
+	runContext context.Context
+	// cancel cancels runContext, stopping the run or keeping it from starting. It is also called once the run is done.
+	cancel         context.CancelFunc
 	cmd            *exec.Cmd
 	execTaskConfig *GenericExecConfig
+	// taskName is the name the task is configured under, whatever its configuration's Name or the alias it was run by.
+	taskName       string
 	requestValues  TemplateGetter
-	resultChan     chan GenericExecResult
+	results        resultSink
+	enqueuedAt     time.Time
+	onOutputLine   func(stream OutputStream, line string)
+	outputWriter   io.Writer
+	outputFlush    time.Duration
+	flushEachWrite bool
+	labels         map[string]string
+	values         *RunValues
+	// started is set once the run leaves its queue, under activeMutex.
+	started bool
+	// claimed is set by whichever of the queue's consumer and watchQueuedRun takes a queued run from the other, and
+	// dequeued is closed once the consumer has it.
+	claimed  atomic.Bool
+	dequeued chan struct{}
+	preCmd   *exec.Cmd
+	postCmd  *exec.Cmd
+	// addedEnv is the variables set for the process by its configuration and request, rather than inherited.
+	addedEnv []string
+	// requestEnv is the variables the request set for the process, for its OnSuccessCommand or OnFailureCommand.
+	requestEnv map[string]string
+	// resolvedEnv is the process's whole environment, if its configuration or request changed what it inherits.
+	resolvedEnv []string
+	// secrets is the values of the task's SecretKeys for this run, and the secrets its templates fetched.
+	secrets   []string
+	stdinSent string
+	// stdin is RunOptions.Stdin, if the run has it.
+	stdin *stdinStream
+}
+
+// RunOptions are per-run settings that don't belong in a GenericExecConfig.
+type RunOptions struct {
+	// ExtraFiles are inherited by the task's process as file descriptors 3, 4, and so on, in order. This is not
+	// supported on Windows. See exec.Cmd.ExtraFiles.
+	ExtraFiles []*os.File
+
+	// OnOutputLine, if set, is called with each line the task writes to stdout or stderr, without its trailing
+	// newline or GenericExecConfig.LineDelimiter, as the task runs. It is not called concurrently for one run. The
+	// output is still captured in the result as usual.
+	OnOutputLine func(stream OutputStream, line string)
+
+	// OutputWriter, if set, receives everything the task writes to stdout and stderr as the task runs. If writing to
+	// it fails, for example because it is a network connection that has gone away, nothing more is written to it but
+	// the task runs to completion as usual and the error is reported in the result's StreamError.
+	OutputWriter io.Writer
+	// OutputFlushInterval, when positive, flushes OutputWriter that often while the task writes to it, and once more
+	// when the task exits, so that someone following it, such as with tail -f on a file, sees output promptly.
+	// FlushOutputEachWrite flushes it after every write instead, typically a line at a time, for the freshest output
+	// at the most cost. A writer with a Flush method, such as a *bufio.Writer, is flushed with it, and one with a Sync
+	// method, such as an *os.File, with that; others are unaffected. By default, OutputWriter is never flushed and
+	// its own buffering, or the operating system's, applies.
+	OutputFlushInterval  time.Duration
+	FlushOutputEachWrite bool
+
+	// TemplateData, if set, is the value of dot in the task's templates, alongside the functions like "request",
+	// so that templates can use its fields as in {{.Region}}.
+	TemplateData interface{}
+
+	// Values are the initial contents of the run's RunValues. The map is copied, so the caller's map is unaffected
+	// by values set during the run.
+	Values map[string]interface{}
+
+	// Stdin, if set, is copied to the task's process on its standard input in place of the task's Stdin template,
+	// as the process reads it, so even a large input is never held in memory. Copying stops when the process exits,
+	// so a reader that never reaches EOF doesn't hold up the result. The task's result reports an error
+	// reading it in StdinError. If it is an io.Closer, it is closed once the run is over, whether or not the task was
+	// started.
+	Stdin io.Reader
+
+	// prevStdOut is what "prev_stdout" renders, for RunTaskPipeline.
+	prevStdOut string
+	// inline submits the run for RunTaskSync.
+	inline bool
+	// resultTo is the caller's channel for RunTaskTo.
+	resultTo chan<- GenericExecResult
+	// ifConfigured makes submitting a task that isn't configured, as happens to a scheduled or supervised task that
+	// ReplaceConfigs removed, return no channel instead of panicking.
+	ifConfigured bool
 }
 
 type TemplateGetter interface {
 	Get(string) string
 }
 
+// EnvTemplateGetter is implemented by TemplateGetters that also supply environment variables for the task's process,
+// such as per-request credentials. See GenericExecConfig.Env for precedence.
+type EnvTemplateGetter interface {
+	TemplateGetter
+	Env() map[string]string
+}
+
 func NewGenericExecManager(execTaskConfigsByName map[string]GenericExecConfig, log *log.Logger, notifyCallback func(message string)) *GenericExecManager {
 	execManager := GenericExecManager{
 		log:                   log,
-		execTaskConfigsByName: execTaskConfigsByName,
+		execTaskConfigsByName: copyConfigs(execTaskConfigsByName),
 		notifyCallback:        notifyCallback,
 	}
 	execManager.CmdFactory = execManager.productionCmdFactory
 
+	execManager.mutexQueues = make(map[string]*commandQueue, len(execTaskConfigsByName))
+	execManager.syncMutexQueues()
+	execManager.syncTaskAliases()
+
+	return &execManager
+}
+
+// copyConfigs returns a copy of configs, so that the manager's configuration doesn't change, or race with runs,
+// when the caller goes on to edit its map, as for passing it to ReplaceConfigs again.
+func copyConfigs(configs map[string]GenericExecConfig) map[string]GenericExecConfig {
+	copied := make(map[string]GenericExecConfig, len(configs))
+	for taskName, execConfig := range configs {
+		copied[taskName] = execConfig
+	}
+	return copied
+}
+
+// syncMutexQueues must be called with configMutex held for writing.
+func (ctx *GenericExecManager) syncMutexQueues() {
 	// Find non-reentrant commands and add queues for them.
 	// Queues are per command, not task name, so if two tasks were configured that run the same
 	// command and both are marked not reentrant, only one will run at a time.
-	execManager.mutexQueues = make(map[string]chan mutexQueueMessage, len(execTaskConfigsByName))
-	for _, execConfig := range execTaskConfigsByName {
-		if _, queueCreated := execManager.mutexQueues[execConfig.Command]; !queueCreated && !execConfig.Reentrant {
-			execManager.mutexQueues[execConfig.Command] = make(chan mutexQueueMessage, 50)
-			go execManager.mutexQueueConsumer(execManager.mutexQueues[execConfig.Command])
+	neededQueues := make(map[string]bool, len(ctx.execTaskConfigsByName))
+	for _, execConfig := range ctx.execTaskConfigsByName {
+		if execConfig.Reentrant {
+			continue
+		}
+		execConfig.Command, _ = commandForOS(execConfig, runtime.GOOS)
+		neededQueues[execConfig.Command] = true
+		if _, queueCreated := ctx.mutexQueues[execConfig.Command]; !queueCreated {
+			queue := &commandQueue{runs: make(chan *taskRun, 50)}
+			ctx.mutexQueues[execConfig.Command] = queue
+			go ctx.mutexQueueConsumer(execConfig.Command, queue.runs)
 		}
 	}
 
-	return &execManager
+	// Closing a queue lets its consumer finish whatever was already enqueued, then exit.
+	for command, queue := range ctx.mutexQueues {
+		if !neededQueues[command] {
+			queue.closeWhenSent()
+			delete(ctx.mutexQueues, command)
+		}
+	}
 }
 
 func (ctx *GenericExecManager) IsTaskConfigured(taskName string) bool {
-	_, found := ctx.execTaskConfigsByName[taskName]
+	ctx.configMutex.RLock()
+	defer ctx.configMutex.RUnlock()
+	_, found := ctx.taskConfig(taskName)
 	return found
 }
 
+// ReplaceConfigs validates newConfigs, and with RequireCommands checks their commands, and if they pass, atomically
+// replaces all task configurations with them. Tasks that are already running or queued finish as they would have
+// under the old configuration. The manager keeps a copy of newConfigs, so the caller may go on editing its map.
+func (ctx *GenericExecManager) ReplaceConfigs(newConfigs map[string]GenericExecConfig) error {
+	if err := ValidateConfigs(newConfigs); err != nil {
+		return err
+	}
+
+	if ctx.RequireCommands {
+		if err := ctx.checkCommands(newConfigs); err != nil {
+			return err
+		}
+	}
+
+	ctx.configMutex.Lock()
+	defer ctx.configMutex.Unlock()
+	if ctx.isShutDown {
+		return errors.New("the manager has been shut down")
+	}
+	ctx.execTaskConfigsByName = copyConfigs(newConfigs)
+	ctx.syncMutexQueues()
+	ctx.syncTaskAliases()
+	return nil
+}
+
+// Shutdown stops the manager from accepting new tasks. Tasks that are already running or queued still run to
+// completion, and any batched notifications are delivered. Tasks submitted after Shutdown are not run; their result
+// has FailureKind FailureKindShuttingDown.
+func (ctx *GenericExecManager) Shutdown() {
+	ctx.configMutex.Lock()
+	if !ctx.isShutDown {
+		ctx.isShutDown = true
+		for command, queue := range ctx.mutexQueues {
+			queue.closeWhenSent()
+			delete(ctx.mutexQueues, command)
+		}
+	}
+	ctx.configMutex.Unlock()
+
+	ctx.notifyMutex.Lock()
+	var batch string
+	if len(ctx.pendingNotifications) > 0 {
+		batch = ctx.takeNotificationBatch()
+	}
+	ctx.notifyMutex.Unlock()
+	if batch != "" {
+		ctx.notifyCallback(batch)
+	}
+}
+
+// ValidateConfigs checks that every task has a command and that all of its templates parse.
+func ValidateConfigs(configs map[string]GenericExecConfig) error {
+	taskNames := make([]string, 0, len(configs))
+	for taskName := range configs {
+		taskNames = append(taskNames, taskName)
+	}
+	sort.Strings(taskNames)
+	if err := validateAliases(configs, taskNames); err != nil {
+		return err
+	}
+
+	for _, taskName := range taskNames {
+		execConfig := configs[taskName]
+		if execConfig.Command == "" {
+			return fmt.Errorf("task \"%s\": no command configured", taskName)
+		}
+		if err := validateArgTemplates(execConfig); err != nil {
+			return fmt.Errorf("task \"%s\": %v", taskName, err)
+		}
+		if err := validateNamespaces(execConfig.Namespaces); err != nil {
+			return fmt.Errorf("task \"%s\": %v", taskName, err)
+		}
+		if err := validateArgGroups(execConfig); err != nil {
+			return fmt.Errorf("task \"%s\": %v", taskName, err)
+		}
+		for _, pattern := range append([]string{execConfig.SuccessStderrPattern, execConfig.FailureStderrPattern}, execConfig.WatchPatterns...) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("task \"%s\": %v", taskName, err)
+			}
+		}
+		if len(execConfig.ArgPatterns) > len(execConfig.Args) {
+			return fmt.Errorf("task \"%s\": %d ArgPatterns for %d Args", taskName, len(execConfig.ArgPatterns), len(execConfig.Args))
+		}
+		for _, pattern := range execConfig.ArgPatterns {
+			if _, err := regexp.Compile(anchorArgPattern(pattern)); err != nil {
+				return fmt.Errorf("task \"%s\": %v", taskName, err)
+			}
+		}
+		switch execConfig.Restart {
+		case "", "never", "on-failure", "always":
+		default:
+			return fmt.Errorf("task \"%s\": unknown Restart policy \"%s\"", taskName, execConfig.Restart)
+		}
+		switch execConfig.TruncateFrom {
+		case "", "tail", "head":
+		default:
+			return fmt.Errorf("task \"%s\": unknown TruncateFrom \"%s\"", taskName, execConfig.TruncateFrom)
+		}
+		switch execConfig.MessageOutput {
+		case "", "stdout", "stderr", "combined":
+		default:
+			return fmt.Errorf("task \"%s\": unknown MessageOutput \"%s\"", taskName, execConfig.MessageOutput)
+		}
+		if len(execConfig.LineDelimiter) > 1 {
+			return fmt.Errorf("task \"%s\": LineDelimiter \"%s\" is not a single byte", taskName, execConfig.LineDelimiter)
+		}
+		if execConfig.MaxCPURatio > 0 && !cpuSamplingSupported {
+			return fmt.Errorf("task \"%s\": %v", taskName, errCPUSamplingUnsupported)
+		}
+		switch execConfig.SuppressRepeatNotifications {
+		case "", "message", "output":
+		default:
+			return fmt.Errorf("task \"%s\": unknown SuppressRepeatNotifications \"%s\"", taskName, execConfig.SuppressRepeatNotifications)
+		}
+		messages := []string{execConfig.SuccessMessage, execConfig.ErrorMessage}
+		for _, message := range execConfig.MessagesByExitCode {
+			messages = append(messages, message)
+		}
+		for _, message := range messages {
+			if _, err := template.New("Message processor").Funcs(messageFuncMap(MapGetter{}, "", "", "", messageRunInfo{})).Parse(message); err != nil {
+				return fmt.Errorf("task \"%s\": %v", taskName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateArgTemplates checks that everything in a task configuration that is rendered like its Args can be.
+func validateArgTemplates(execConfig GenericExecConfig) error {
+	argTemplates := append([]string{execConfig.Argv0, execConfig.Stdin}, execConfig.Args...)
+	argTemplates = append(append(argTemplates, execConfig.PreArgs...), execConfig.PostArgs...)
+	argTemplates = append(argTemplates, execConfig.WindowsArgs...)
+	for _, group := range execConfig.ArgGroups {
+		argTemplates = append(append(argTemplates, group.When), group.Args...)
+	}
+	for _, osCommand := range execConfig.OSCommands {
+		argTemplates = append(argTemplates, osCommand.Args...)
+	}
+	hookArgTemplates := append(append([]string{}, execConfig.OnSuccessArgs...), execConfig.OnFailureArgs...)
+	if execConfig.PlaceholderSyntax {
+		_, err := renderPlaceholderArgs(append(argTemplates, hookArgTemplates...), MapGetter{})
+		return err
+	}
+	// These are the same functions RenderArgTemplates provides.
+	argsFuncMap := argListFuncMap(MapGetter{}, &[]string{})
+	for _, argTemplate := range argTemplates {
+		if _, err := template.New("args processor").Funcs(argsFuncMap).Parse(argTemplate); err != nil {
+			return err
+		}
+	}
+	hookFuncMap := argListFuncMap(withCompletedRun(MapGetter{}, &GenericExecResult{}), &[]string{})
+	for _, argTemplate := range hookArgTemplates {
+		if _, err := template.New("args processor").Funcs(hookFuncMap).Parse(argTemplate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunTask starts the named task and returns a channel that will receive its result.
+//
+// Reentrant tasks start immediately. Non-reentrant tasks wait in a queue shared by every non-reentrant task with the
+// same Command, and run one at a time in the order RunTask was called for them.
 func (ctx *GenericExecManager) RunTask(taskName string, argValues TemplateGetter) <-chan GenericExecResult {
-	resultChan := make(chan GenericExecResult, 1)
+	return ctx.RunTaskContext(context.Background(), taskName, argValues)
+}
+
+// RunTaskMap is like RunTask, with the values available to templates through "request" supplied as a map.
+// Keys missing from the map render as the empty string.
+func (ctx *GenericExecManager) RunTaskMap(taskName string, values map[string]string) <-chan GenericExecResult {
+	return ctx.RunTask(taskName, MapGetter(values))
+}
+
+// RunTaskForEach runs the named task once for each of getters, with at most maxParallel runs in flight at a time,
+// and returns the results in the same order as getters. A maxParallel less than 1 means no limit. Runs of a
+// non-reentrant task still happen one at a time regardless of maxParallel.
+func (ctx *GenericExecManager) RunTaskForEach(taskName string, getters []TemplateGetter, maxParallel int) []GenericExecResult {
+	if maxParallel < 1 {
+		maxParallel = len(getters)
+	}
+	results := make([]GenericExecResult, len(getters))
+	slots := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for ix, getter := range getters {
+		slots <- struct{}{}
+		wg.Add(1)
+		resultChan := ctx.RunTask(taskName, getter)
+		go func(ix int) {
+			defer wg.Done()
+			results[ix] = <-resultChan
+			<-slots
+		}(ix)
+	}
+	wg.Wait()
+	return results
+}
+
+// RunTaskContext is like RunTask, but kills the task's process if runContext is done before it exits.
+// If runContext is done before the task starts, for example while it waits in the queue, the task is not started.
+// The result of a task that was stopped still has whatever output the process wrote before it was, which is often
+// the best clue to why it hung.
+func (ctx *GenericExecManager) RunTaskContext(runContext context.Context, taskName string, argValues TemplateGetter) <-chan GenericExecResult {
+	return ctx.RunTaskWithOptions(runContext, taskName, argValues, RunOptions{})
+}
+
+// RunTaskWithOptions is like RunTaskContext, with additional settings for this run only.
+func (ctx *GenericExecManager) RunTaskWithOptions(runContext context.Context, taskName string, argValues TemplateGetter, options RunOptions) <-chan GenericExecResult {
+	resultChan, _ := ctx.submitRun(runContext, taskName, argValues, options)
+	return resultChan
+}
+
+// submitRun prepares a run of a task and hands it off to run in the background. With options.inline, it returns the
+// run instead, for the caller to run with runInline. Either way, the result is delivered on the returned channel, or
+// on options.resultTo if that is set, in which case the returned channel is nil. With options.ifConfigured, both are
+// nil and nothing is delivered if the task isn't configured.
+func (ctx *GenericExecManager) submitRun(runContext context.Context, taskName string, argValues TemplateGetter, options RunOptions) (resultChan chan GenericExecResult, inlineRun *taskRun) {
+	sink := resultSink{resultChan: options.resultTo, keepOpen: true}
+	if options.resultTo == nil {
+		resultChan = make(chan GenericExecResult, 1)
+		sink = resultSink{resultChan: resultChan}
+	}
+	correlationID := CorrelationID(runContext)
+	values := newRunValues(options.Values)
+	if ctx.BeforeRun != nil {
+		ctx.BeforeRun(taskName, values)
+	}
+	// Once the run is handed off, it closes options.Stdin when it is over; until then, it is closed here.
+	var handedOff bool
+	defer func() {
+		if !handedOff {
+			closeReader(options.Stdin)
+		}
+	}()
+
+	// The config lock is only held to look up the task and its queue. Waiting for room in a full queue, or delivering
+	// a result, while holding it would stall ReplaceConfigs, Shutdown and, behind them, every other reader.
+	ctx.configMutex.RLock()
+	if ctx.isShutDown {
+		canonicalName := ctx.canonicalTaskName(taskName)
+		ctx.configMutex.RUnlock()
+		ctx.deliverResult(sink, GenericExecResult{
+			Name:          taskName,
+			ExitCode:      1,
+			StdErr:        "The task was not run because the manager has been shut down.",
+			FailureKind:   FailureKindShuttingDown,
+			CorrelationID: correlationID,
+			Values:        values,
+			taskName:      canonicalName,
+		})
+		return resultChan, nil
+	}
 
 	// Translate task to Cmd.
-	execConfig, found := ctx.execTaskConfigsByName[taskName]
+	execConfig, found := ctx.taskConfig(taskName)
 	if !found {
+		ctx.configMutex.RUnlock()
+		if options.ifConfigured {
+			return nil, nil
+		}
 		panic(fmt.Sprintf("No task configuration for task \"%s\"", taskName))
 	}
-	cmd, err := ctx.CmdFactory(execConfig.Command, argValues, execConfig.Args...)
+	canonicalName := ctx.canonicalTaskName(taskName)
+	execConfig.Command, execConfig.Args = commandForOS(execConfig, runtime.GOOS)
+	var queue *commandQueue
+	if !execConfig.Reentrant && !options.inline {
+		// Registering as a sender keeps the queue open until this run has been sent, or given up on.
+		queue = ctx.mutexQueues[execConfig.Command]
+		queue.senders.Add(1)
+		defer queue.senders.Done()
+	}
+	ctx.configMutex.RUnlock()
+
+	var requestEnv map[string]string
+	if envGetter, hasEnv := argValues.(EnvTemplateGetter); hasEnv {
+		requestEnv = envGetter.Env()
+	}
+
+	if len(execConfig.Defaults) > 0 {
+		argValues = &defaultsGetter{TemplateGetter: argValues, defaults: execConfig.Defaults}
+	}
+	labels := copyLabels(execConfig.Labels)
+	getter := &runGetter{TemplateGetter: argValues, manager: ctx, correlationID: correlationID, labels: labels, prevStdOut: options.prevStdOut, data: options.TemplateData, placeholders: execConfig.PlaceholderSyntax, now: ctx.now(), values: values, fetchedSecrets: &fetchedSecrets{}}
+	argValues = getter
+	args, argIndexes, groupIndexes, err := expandArgGroups(execConfig.Args, execConfig.ArgGroups, argValues)
 	if err != nil {
-		resultChan <- GenericExecResult{
-			Name:     taskName,
-			ExitCode: 1,
-			StdOut:   "",
-			StdErr:   err.Error(),
-		}
-		close(resultChan)
+		ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+		return resultChan, nil
+	}
+	var renderedArgs []string
+	cmd, err := ctx.CmdFactory(execConfig.Command, getter.forCommandArgs(args, &renderedArgs), args...)
+	if err != nil {
+		ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+		return resultChan, nil
+	}
 
-		ctx.log.Printf("Could not prepare an executable command from the configuration for task %s: %v", taskName, err)
-		return resultChan
+	if hasArgPatterns(&execConfig) {
+		// The CmdFactory may have added arguments of its own, so check the args as it rendered them, unless it didn't
+		// render them with RenderArgTemplates.
+		if renderedArgs == nil {
+			renderedArgs, err = RenderArgTemplates(args, argValues)
+		}
+		if err == nil {
+			err = checkRunArgPatterns(&execConfig, renderedArgs, argIndexes, groupIndexes, secretValues(execConfig.SecretKeys, argValues))
+		}
+		if err != nil {
+			ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+			return resultChan, nil
+		}
+	}
+	if execConfig.OmitEmptyArgs {
+		keptArgs := cmd.Args[:1]
+		for _, arg := range cmd.Args[1:] {
+			if arg != "" {
+				keptArgs = append(keptArgs, arg)
+			}
+		}
+		cmd.Args = keptArgs
+	}
+	if execConfig.Argv0 != "" {
+		renderedArgv0, err := RenderArgTemplates([]string{execConfig.Argv0}, argValues)
+		if err != nil {
+			ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+			return resultChan, nil
+		}
+		cmd.Args[0] = renderedArgv0[0]
+	}
+	if err := ctx.wrapCmd(cmd, argValues); err != nil {
+		ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+		return resultChan, nil
+	}
+	var stdinSent string
+	if execConfig.Stdin != "" {
+		renderedStdin, err := RenderArgTemplates([]string{execConfig.Stdin}, argValues)
+		if err != nil {
+			ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+			return resultChan, nil
+		}
+		cmd.Stdin = strings.NewReader(renderedStdin[0])
+		stdinSent = renderedStdin[0]
+	}
+	var stdin *stdinStream
+	if options.Stdin != nil {
+		stdin = &stdinStream{reader: options.Stdin}
+		cmd.Stdin, stdinSent = stdin, ""
 	}
 
-	if execConfig.Reentrant {
-		go ctx.doRunRunRunDaDooRunRun(cmd, &execConfig, argValues, resultChan)
+	var resolvedEnv []string
+	if customizesEnv(&execConfig, requestEnv) {
+		cmd.Env = resolveEnv(cmd.Env, &execConfig, requestEnv)
+		resolvedEnv = cmd.Env
+	}
+	if err := ctx.checkArgListSize(cmd); err != nil {
+		ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+		return resultChan, nil
+	}
+	cmd.WaitDelay = execConfig.WaitDelay
+	cmd.ExtraFiles = options.ExtraFiles
+	if len(execConfig.Namespaces) > 0 {
+		if err := applyNamespaces(cmd, execConfig.Namespaces); err != nil {
+			ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+			return resultChan, nil
+		}
+	}
+	var preCmd, postCmd *exec.Cmd
+	if execConfig.PreCommand != "" {
+		if preCmd, err = ctx.prepareStep(execConfig.PreCommand, execConfig.PreArgs, &execConfig, argValues, requestEnv); err != nil {
+			ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+			return resultChan, nil
+		}
+	}
+	if execConfig.PostCommand != "" {
+		if postCmd, err = ctx.prepareStep(execConfig.PostCommand, execConfig.PostArgs, &execConfig, argValues, requestEnv); err != nil {
+			ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, err)
+			return resultChan, nil
+		}
+	}
+	stopDeadline := func() {}
+	if execConfig.DeadlineKey != "" {
+		if deadlineValue := argValues.Get(execConfig.DeadlineKey); deadlineValue != "" {
+			deadline, err := time.Parse(time.RFC3339, deadlineValue)
+			if err != nil {
+				ctx.failPreparation(sink, &execConfig, taskName, canonicalName, correlationID, labels, values, fmt.Errorf("invalid deadline: %v", err))
+				return resultChan, nil
+			}
+			runContext, stopDeadline = context.WithDeadline(runContext, deadline)
+		}
+	}
+	runContext, cancelRun := context.WithCancel(runContext)
+	run := &taskRun{
+		runContext:     runContext,
+		cancel:         func() { cancelRun(); stopDeadline() },
+		cmd:            cmd,
+		execTaskConfig: &execConfig,
+		taskName:       canonicalName,
+		requestValues:  argValues,
+		results:        sink,
+		onOutputLine:   options.OnOutputLine,
+		outputWriter:   options.OutputWriter,
+		outputFlush:    options.OutputFlushInterval,
+		flushEachWrite: options.FlushOutputEachWrite,
+		addedEnv:       mergeEnv(mergeEnv(nil, execConfig.Env), requestEnv),
+		resolvedEnv:    resolvedEnv,
+		requestEnv:     requestEnv,
+		labels:         labels,
+		values:         values,
+		secrets:        secretValues(execConfig.SecretKeys, argValues),
+		stdinSent:      stdinSent,
+		stdin:          stdin,
+		preCmd:         preCmd,
+		postCmd:        postCmd,
+	}
+	if queue != nil {
+		run.dequeued = make(chan struct{})
+	}
+	ctx.trackRun(run)
+	if options.inline {
+		run.enqueuedAt = time.Now()
+		inlineRun = run
+		handedOff = true
+	} else if execConfig.Reentrant && execConfig.MaxConcurrent > 0 {
+		run.enqueuedAt = time.Now()
+		go ctx.doRunInTaskSlot(run)
+		handedOff = true
+	} else if execConfig.Reentrant {
+		go ctx.doRunRunRunDaDooRunRun(run)
+		handedOff = true
 	} else {
-		ctx.mutexQueues[execConfig.Command] <- mutexQueueMessage{
-			cmd:            cmd,
-			execTaskConfig: &execConfig,
-			requestValues:  argValues,
-			resultChan:     resultChan,
+		run.enqueuedAt = time.Now()
+		select {
+		case queue.runs <- run:
+			handedOff = true
+		default:
+			if ctx.IsCommandPaused(execConfig.Command) {
+				ctx.rejectFullQueue(run, taskName, correlationID, "is full and the command is paused")
+			} else if ctx.EnqueueTimeout <= 0 {
+				// A run whose context is done before there is room is handed off all the same, for watchQueuedRun to
+				// fail as cancelled or timed out.
+				select {
+				case queue.runs <- run:
+				case <-run.runContext.Done():
+				}
+				handedOff = true
+			} else {
+				enqueueTimer := time.NewTimer(ctx.EnqueueTimeout)
+				defer enqueueTimer.Stop()
+				select {
+				case queue.runs <- run:
+					handedOff = true
+				case <-run.runContext.Done():
+					handedOff = true
+				case <-enqueueTimer.C:
+					ctx.rejectFullQueue(run, taskName, correlationID, fmt.Sprintf("stayed full for %v", ctx.EnqueueTimeout))
+				}
+			}
+		}
+		if handedOff {
+			go ctx.watchQueuedRun(run)
 		}
 	}
 
-	return resultChan
+	return resultChan, inlineRun
+}
+
+// rejectFullQueue hands run, which was never enqueued, a FailureKindEnqueueTimeout result. reason says what was
+// wrong with its queue.
+func (ctx *GenericExecManager) rejectFullQueue(run *taskRun, taskName string, correlationID string, reason string) {
+	run.cancel()
+	ctx.untrackRun(run)
+	ctx.deliverResult(run.results, GenericExecResult{
+		Name:          taskName,
+		ExitCode:      1,
+		StdErr:        fmt.Sprintf("The task was not run because the queue for command \"%s\" %s.", run.execTaskConfig.Command, reason),
+		FailureKind:   FailureKindEnqueueTimeout,
+		CorrelationID: correlationID,
+		Labels:        run.labels,
+		Values:        run.values,
+		sensitive:     run.execTaskConfig.Sensitive,
+		taskName:      run.taskName,
+	})
+	ctx.logf(LogLevelError, "Task %s was not run because its queue %s.", taskName, reason)
 }
 
 // https://en.wikipedia.org/wiki/Da_Doo_Ron_Ron
-func (ctx *GenericExecManager) doRunRunRunDaDooRunRun(cmd *exec.Cmd, execConfig *GenericExecConfig, templateValues TemplateGetter, resultChan chan<- GenericExecResult) {
-	outBuffer := &bytes.Buffer{}
-	errBuffer := &bytes.Buffer{}
+func (ctx *GenericExecManager) doRunRunRunDaDooRunRun(run *taskRun) {
+	cmd, execConfig, templateValues, results := run.cmd, run.execTaskConfig, run.requestValues, run.results
+	ctx.markRunStarted(run)
+	defer run.cancel()
+	keepTail := execConfig.TruncateFrom == "head"
+	outBuffer := ctx.newOutputBuffer(execConfig.MaxOutputBytes, execConfig.TailLines, keepTail)
+	errBuffer := ctx.newOutputBuffer(execConfig.MaxOutputBytes, execConfig.TailLines, keepTail)
+	defer outBuffer.release()
+	defer errBuffer.release()
 	cmd.Stdout = outBuffer
 	cmd.Stderr = errBuffer
-
-	result := GenericExecResult{Name: execConfig.Name}
-	err := cmd.Run()
-	result.StdErr = strings.TrimSpace(errBuffer.String())
-	errBuffer.Truncate(0)
-	result.StdOut = strings.TrimSpace(outBuffer.String())
-	outBuffer.Truncate(0)
-	if err != nil {
-		result.ExitCode = 1
-		// It takes two(!) type assertions to get at the exit code.
-		if exitErr, isExitErr := err.(*exec.ExitError); isExitErr {
-			if waitStatus, isWaitStatus := exitErr.Sys().(syscall.WaitStatus); isWaitStatus {
-				result.ExitCode = waitStatus.ExitStatus()
+	var stdoutHash hash.Hash
+	if execConfig.HashOutput {
+		// Hashed as it is written, since MaxOutputBytes, TailLines or OutputMemoryBudget may not keep all of it.
+		stdoutHash = sha256.New()
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdoutHash)
+	}
+	var stdoutLines, stderrLines *lineWriter
+	var droppedOutputCallbacks atomic.Int64
+	watchPatterns := ctx.compileWatchPatterns(execConfig)
+	if run.onOutputLine != nil || len(watchPatterns) > 0 {
+		onOutputLine := run.onOutputLine
+		stdoutLines, stderrLines = newLineWriters(func(stream OutputStream, line string) {
+			if onOutputLine != nil && !ctx.observe(func() { onOutputLine(stream, line) }) {
+				droppedOutputCallbacks.Add(1)
 			}
+			for _, pattern := range watchPatterns {
+				if pattern.MatchString(line) {
+					expr := pattern.String()
+					if !ctx.observe(func() { ctx.OnMatch(execConfig.Name, expr, line) }) {
+						droppedOutputCallbacks.Add(1)
+					}
+				}
+			}
+		}, lineDelimiter(execConfig))
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdoutLines)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderrLines)
+	}
+	var stdoutTee *teeWriter
+	stopFlushing := func() {}
+	if run.outputWriter != nil {
+		var stderrTee *teeWriter
+		stdoutTee, stderrTee = newTeeWriters(run.outputWriter, run.flushEachWrite)
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdoutTee)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderrTee)
+		if run.outputFlush > 0 {
+			stopFlushing = stdoutTee.flushEvery(run.outputFlush)
 		}
-	} else {
-		result.ExitCode = 0
+	}
+
+	runContext := run.runContext
+	var guard *outputGuard
+	if execConfig.FailOnOutputBytes > 0 {
+		var stop context.CancelFunc
+		runContext, stop = context.WithCancel(runContext)
+		defer stop()
+		guard = &outputGuard{limit: int64(execConfig.FailOnOutputBytes), stop: stop}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, guard)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, guard)
+	}
+	startProcess := execConfig.StartProcess
+	var cpuLimit *cpuGuard
+	if execConfig.MaxCPURatio > 0 {
+		var stop context.CancelFunc
+		runContext, stop = context.WithCancel(runContext)
+		defer stop()
+		cpuLimit = newCPUGuard(execConfig, stop)
+		startProcess = cpuLimit.watching(startProcess)
+	}
+
+	result := GenericExecResult{Name: execConfig.Name, CorrelationID: CorrelationID(run.runContext), Labels: run.labels, Values: run.values, sensitive: execConfig.Sensitive, taskName: run.taskName}
+	var stoppedByContext bool
+	releaseProcessSlot, err := ctx.acquireProcessSlot(runContext)
+	if err == errProcessLimit {
+		result.FailureKind = FailureKindProcessLimit
+	} else if err != nil {
+		stoppedByContext = true
+	}
+	startedAt := time.Now()
+	if !run.enqueuedAt.IsZero() {
+		result.QueueWait = startedAt.Sub(run.enqueuedAt)
+	}
+	if err == nil && run.preCmd != nil {
+		result.PreCommand, err = ctx.runStep(runContext, run.preCmd, execConfig)
+		if err != nil {
+			result.FailureKind = FailureKindPreCommand
+			// If the context stopped the PreCommand, the run was cancelled or timed out rather than failing setup.
+			stoppedByContext = runContext.Err() != nil
+		}
+		startedAt = time.Now()
+	}
+	if err == nil && run.stdin != nil {
+		err = run.stdin.attach(cmd)
+	}
+	if err == nil {
+		stopHeartbeat := ctx.startHeartbeat(execConfig.Name, startedAt)
+		stoppedByContext, err = runCmd(runContext, cmd, startProcess, execConfig.CancelSignal, execConfig.WaitDelay)
+		stopHeartbeat()
+		result.ExecTime = time.Since(startedAt)
+		if cmd.ProcessState != nil {
+			result.UserTime, result.SystemTime = cmd.ProcessState.UserTime(), cmd.ProcessState.SystemTime()
+		}
+		if run.postCmd != nil {
+			postContext, cancelPost := afterRunContext(runContext, execConfig)
+			result.PostCommand, _ = ctx.runStep(postContext, run.postCmd, execConfig)
+			cancelPost()
+		}
+	}
+	if run.stdin != nil {
+		result.StdinError = run.stdin.finish()
+		if err == nil && result.StdinError != nil {
+			// The process got a truncated input, however it exited.
+			err = result.StdinError
+		}
+	}
+	if stdoutLines != nil {
+		stdoutLines.flush()
+		stderrLines.flush()
+		result.DroppedOutputCallbacks = droppedOutputCallbacks.Load()
+	}
+	stopFlushing()
+	if stdoutTee != nil {
+		result.StreamError = stdoutTee.streamError()
+	}
+	result.StdErrBytes = errBuffer.Bytes()
+	result.StdOutBytes = outBuffer.Bytes()
+	result.OutputTruncated = outBuffer.truncated || errBuffer.truncated
+	result.StdInSent = redact(run.stdinSent, run.secrets)
+	if execConfig.MaxOutputBytes > 0 && len(result.StdInSent) > execConfig.MaxOutputBytes {
+		result.StdInSent = cutAtRune(result.StdInSent, execConfig.MaxOutputBytes)
+	}
+	for _, variable := range run.resolvedEnv {
+		result.ResolvedEnv = append(result.ResolvedEnv, redact(variable, run.secrets))
+	}
+	rawStdErr := string(result.StdErrBytes)
+	rawStdOut := string(result.StdOutBytes)
+	if execConfig.CollapseCarriageReturns {
+		rawStdOut, rawStdErr = collapseCarriageReturns(rawStdOut), collapseCarriageReturns(rawStdErr)
+	}
+	rawStdOut = outBuffer.marked(rawStdOut, execConfig.TruncationMarker)
+	rawStdErr = errBuffer.marked(rawStdErr, execConfig.TruncationMarker)
+	result.StdErr = strings.TrimSpace(rawStdErr)
+	result.StdOut = strings.TrimSpace(rawStdOut)
+	if stdoutHash != nil {
+		result.StdOutHash = hex.EncodeToString(stdoutHash.Sum(nil))
+	}
+	result.ExitCode = exitCodeOf(err)
+	if err != nil && cmd.Process == nil && result.FailureKind == FailureKindNone && runContext.Err() == nil {
+		// The process never started, so there is no output to say why.
+		result.StdErr = fmt.Sprintf("Could not start the command: %v", err)
+		result.FailureKind = startFailureKind(err)
+	}
+	if result.FailureKind == FailureKindProcessLimit {
+		result.StdErr = fmt.Sprintf("The task was not run because MaxProcesses, %d, stayed reached for %v.", ctx.MaxProcesses, ctx.EnqueueTimeout)
+	}
+
+	if guard != nil && guard.hasTripped() {
+		result.FailureKind = FailureKindOutputLimit
+	} else if cpuLimit != nil && cpuLimit.hasTripped() {
+		result.FailureKind = FailureKindCPULimit
+	} else if stoppedByContext && run.runContext.Err() == context.DeadlineExceeded {
+		result.FailureKind = FailureKindTimeout
+	} else if stoppedByContext {
+		result.FailureKind = FailureKindCancelled
+	}
+	if stoppedByContext && cmd.Process == nil {
+		// There's no exit code for a process that never started.
+		result.ExitCode = -1
+	}
+
+	var successReason string
+	result.Succeeded, successReason = decideSuccess(execConfig, &result)
+
+	customSuccess := execConfig.SuccessFunc != nil && result.FailureKind == FailureKindNone
+	if (result.Succeeded || customSuccess) && execConfig.Parser != nil {
+		result.Parsed, result.ParseError = execConfig.Parser(result.StdOut, result.StdErr)
+	}
+	if customSuccess {
+		result.Succeeded, successReason = applySuccessFunc(execConfig, result)
+	}
+	if cmd.Process != nil {
+		ctx.runHook(runContext, run, &result)
+	}
+	releaseProcessSlot()
+
+	messageStdOut, messageStdErr := result.StdOut, result.StdErr
+	if execConfig.RawMessageOutput {
+		messageStdOut, messageStdErr = rawStdOut, rawStdErr
 	}
 
 	// Send notifications if configured, and log.
 	var logMsg, notificationMsg string
-	if result.ExitCode == 0 {
-		logMsg = fmt.Sprintf("Command \"%s\" exited 0.", cmdStringApproximation(cmd))
-		if execConfig.SuccessMessage != "" {
-			notificationMsg, err = renderMessageTemplate(execConfig.SuccessMessage, templateValues, &result.StdOut, &result.StdErr)
+	commandDescription := fmt.Sprintf("Command \"%s\"", cmdStringApproximation(cmd))
+	if execConfig.Sensitive {
+		commandDescription = fmt.Sprintf("Command for sensitive task \"%s\"", execConfig.Name)
+	}
+	successMessage, errorMessage := execConfig.SuccessMessage, execConfig.ErrorMessage
+	runInfo := messageRunInfo{duration: result.ExecTime, exitCode: result.ExitCode}
+	if cmd.Process != nil {
+		runInfo.pid = cmd.Process.Pid
+	}
+	if message, found := execConfig.MessagesByExitCode[result.ExitCode]; found && result.FailureKind == FailureKindNone {
+		successMessage, errorMessage = message, message
+	}
+	if result.Succeeded {
+		if successReason == "" {
+			logMsg = fmt.Sprintf("%s exited 0.", commandDescription)
+		} else {
+			logMsg = fmt.Sprintf("%s exited %d, which counts as success because %s.", commandDescription, result.ExitCode, successReason)
+		}
+		if successMessage != "" {
+			notificationMsg, err = renderMessageTemplate(successMessage, templateValues, messageStdOut, messageStdErr, execConfig.MessageOutput, runInfo)
 			if err != nil {
 				notificationMsg = logMsg + fmt.Sprintf(" However, an error occurred processing the success Message template: %v", err)
 			}
-			logMsg += fmt.Sprintf("\nSending notification: \"%s\"", notificationMsg)
 		}
 	} else {
-		logMsg = fmt.Sprintf("Command \"%s\" exited %d!", cmdStringApproximation(cmd), result.ExitCode)
-		if execConfig.ErrorMessage != "" {
-			notificationMsg, err = renderMessageTemplate(execConfig.ErrorMessage, templateValues, &result.StdOut, &result.StdErr)
+		if result.FailureKind == FailureKindPreCommand {
+			logMsg = fmt.Sprintf("%s was not run because its PreCommand exited %d!", commandDescription, result.PreCommand.ExitCode)
+		} else if successReason == "" {
+			logMsg = fmt.Sprintf("%s exited %d!", commandDescription, result.ExitCode)
+		} else if result.ExitCode == 0 {
+			logMsg = fmt.Sprintf("%s exited 0, but counts as failure because %s!", commandDescription, successReason)
+		} else {
+			logMsg = fmt.Sprintf("%s exited %d because %s!", commandDescription, result.ExitCode, successReason)
+		}
+		if !execConfig.Sensitive {
+			if cmd.Dir != "" {
+				logMsg += fmt.Sprintf("\nIn working directory: %s", cmd.Dir)
+			}
+			if ctx.LogEnvOnFailure && len(run.addedEnv) > 0 {
+				logMsg += fmt.Sprintf("\nWith environment additions: %s", strings.Join(run.addedEnv, " "))
+			}
+		}
+		if errorMessage != "" {
+			notificationMsg, err = renderMessageTemplate(errorMessage, templateValues, messageStdOut, messageStdErr, execConfig.MessageOutput, runInfo)
 			if err != nil {
 				notificationMsg = logMsg + fmt.Sprintf(" Additionally, an error occurred processing the error Message template: %v", err)
 			}
-			logMsg += fmt.Sprintf("\nSending notification: \"%s\"", notificationMsg)
 		}
 	}
-	if len(result.StdOut) > 0 {
-		logMsg += fmt.Sprintf("\nOn StdOut: %s", result.StdOut)
+	if notificationMsg != "" {
+		result.NotificationSuppressed = ctx.repeatsLastNotification(execConfig, result, notificationMsg)
+		loggedMsg := fmt.Sprintf(": \"%s\"", notificationMsg)
+		if execConfig.Sensitive {
+			loggedMsg = "."
+		}
+		if result.NotificationSuppressed {
+			logMsg += "\nNot sending notification, which is unchanged since the last one" + loggedMsg
+		} else {
+			logMsg += "\nSending notification" + loggedMsg
+		}
 	}
-	if len(result.StdErr) > 0 {
-		logMsg += fmt.Sprintf("\nOn StdErr: %s", result.StdErr)
+	if execConfig.Sensitive {
+		// Parse errors commonly quote the output they couldn't parse.
+		if result.ParseError != nil {
+			logMsg += "\nCould not parse output."
+		}
+	} else {
+		if result.ParseError != nil {
+			logMsg += fmt.Sprintf("\nCould not parse output: %v", result.ParseError)
+		}
+		if len(result.StdOut) > 0 {
+			logMsg += fmt.Sprintf("\nOn StdOut: %s", result.StdOut)
+		}
+		if len(result.StdErr) > 0 {
+			logMsg += fmt.Sprintf("\nOn StdErr: %s", result.StdErr)
+		}
 	}
 
+	// Hook and message templates may have fetched more secrets.
+	run.secrets = secretValues(execConfig.SecretKeys, templateValues)
+
 	// Strip out ANSI color sequences from messages
 
-	if logMsg != "" {
-		ctx.log.Println(stripansi.Strip(string(logMsg)))
+	logMsg, notificationMsg = redact(logMsg, run.secrets), redact(notificationMsg, run.secrets)
+	if ctx.LogJSON {
+		ctx.logf(ctx.logLevelFor(result), "%s", completionLogJSON(execConfig, cmd, result, successReason, notificationMsg, run.secrets))
+	} else if logMsg != "" {
+		ctx.logf(ctx.logLevelFor(result), "%s", ctx.prefixLogLines(stripansi.Strip(string(logMsg)), execConfig.Name, cmd))
 	}
 
 	if notificationMsg != "" {
-		ctx.notifyCallback(stripansi.Strip(string(notificationMsg)))
+		if !result.NotificationSuppressed {
+			ctx.notify(stripansi.Strip(string(notificationMsg)))
+		}
 		result.Message = notificationMsg
 	}
 
-	resultChan <- result
-	close(resultChan)
+	ctx.untrackRun(run)
+	ctx.deliverResult(results, result)
 }
 
-func (ctx *GenericExecManager) mutexQueueConsumer(queue <-chan mutexQueueMessage) {
+// startHeartbeat calls the Heartbeat callback periodically, if there is one, until the returned function is called.
+// Once it returns, there will be no more calls, except ones ObserverQueueSize has queued and not yet made.
+func (ctx *GenericExecManager) startHeartbeat(taskName string, startedAt time.Time) (stop func()) {
+	if ctx.Heartbeat == nil || ctx.HeartbeatInterval <= 0 {
+		return func() {}
+	}
+
+	stopChan := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(ctx.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				elapsed := time.Since(startedAt)
+				ctx.observe(func() { ctx.Heartbeat(taskName, elapsed) })
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stopChan)
+		<-stopped
+	}
+}
+
+func (ctx *GenericExecManager) prefixLogLines(logMsg string, taskName string, cmd *exec.Cmd) string {
+	if !ctx.PrefixLogLines && !ctx.PrefixLogLinesWithPid {
+		return logMsg
+	}
+	prefix := "[" + taskName + "] "
+	if ctx.PrefixLogLinesWithPid && cmd.Process != nil {
+		prefix = fmt.Sprintf("[%s %d] ", taskName, cmd.Process.Pid)
+	}
+	return prefix + strings.ReplaceAll(logMsg, "\n", "\n"+prefix)
+}
+
+func (ctx *GenericExecManager) deliverResult(sink resultSink, result GenericExecResult) {
+	ctx.countResult(result)
+	if ctx.ResultTransformer != nil {
+		// A transformer can't see the unexported fields, so it may well return a result without them.
+		taskName, sensitive := result.taskName, result.sensitive
+		result = ctx.ResultTransformer(result)
+		result.taskName, result.sensitive = taskName, sensitive
+	}
+	if ctx.TrackLastResult {
+		ctx.recordLastResult(result)
+	}
+	if ctx.ResultHistorySize > 0 {
+		ctx.recordHistory(result)
+	}
+	if ctx.OnResult != nil {
+		ctx.observe(func() { ctx.OnResult(result) })
+	}
+	sink.send(result)
+}
+
+// commandForOS returns the command and arguments of execConfig to run on the operating system goos.
+func commandForOS(execConfig GenericExecConfig, goos string) (string, []string) {
+	if osCommand, found := execConfig.OSCommands[goos]; found && osCommand.Command != "" {
+		return osCommand.Command, osCommand.Args
+	}
+	if goos == "windows" && execConfig.WindowsCommand != "" {
+		return execConfig.WindowsCommand, execConfig.WindowsArgs
+	}
+	return execConfig.Command, execConfig.Args
+}
+
+// customizesEnv returns whether a task's configuration or request changes the environment its processes inherit.
+func customizesEnv(execConfig *GenericExecConfig, requestEnv map[string]string) bool {
+	return len(execConfig.Env) > 0 || len(execConfig.UnsetEnv) > 0 || len(requestEnv) > 0
+}
+
+// resolveEnv returns the environment for a task's process that would otherwise have env, where nil means the
+// manager's own environment.
+func resolveEnv(env []string, execConfig *GenericExecConfig, requestEnv map[string]string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	if len(execConfig.UnsetEnv) > 0 {
+		kept := make([]string, 0, len(env))
+		for _, variable := range env {
+			name := strings.SplitN(variable, "=", 2)[0]
+			unset := false
+			for _, unsetName := range execConfig.UnsetEnv {
+				unset = unset || unsetName == name
+			}
+			if !unset {
+				kept = append(kept, variable)
+			}
+		}
+		env = kept
+	}
+	return mergeEnv(mergeEnv(env, execConfig.Env), requestEnv)
+}
+
+// mergeEnv returns env with the variables in overrides replacing any of the same name, or appended in sorted order.
+func mergeEnv(env []string, overrides map[string]string) []string {
+	if len(overrides) == 0 {
+		return env
+	}
+	merged := make([]string, 0, len(env)+len(overrides))
+	replaced := make(map[string]bool, len(overrides))
+	for _, variable := range env {
+		name := strings.SplitN(variable, "=", 2)[0]
+		if value, overridden := overrides[name]; overridden {
+			variable = name + "=" + value
+			replaced[name] = true
+		}
+		merged = append(merged, variable)
+	}
+
+	added := make([]string, 0, len(overrides))
+	for name := range overrides {
+		if !replaced[name] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		merged = append(merged, name+"="+overrides[name])
+	}
+	return merged
+}
+
+// failPreparation delivers the result for a task whose command could not be prepared from its configuration. For
+// Sensitive tasks, err is only reported in the result, not logged.
+func (ctx *GenericExecManager) failPreparation(sink resultSink, execConfig *GenericExecConfig, taskName string, canonicalName string, correlationID string, labels map[string]string, values *RunValues, err error) {
+	ctx.deliverResult(sink, GenericExecResult{
+		Name:          taskName,
+		ExitCode:      1,
+		StdOut:        "",
+		StdErr:        err.Error(),
+		CorrelationID: correlationID,
+		Labels:        labels,
+		Values:        values,
+		sensitive:     execConfig.Sensitive,
+		taskName:      canonicalName,
+	})
+
+	if execConfig.Sensitive {
+		// Errors preparing the command commonly quote the rendered args.
+		ctx.logf(LogLevelError, "Could not prepare an executable command from the configuration for sensitive task %s.", taskName)
+		return
+	}
+	ctx.logf(LogLevelError, "Could not prepare an executable command from the configuration for task %s: %v", taskName, err)
+}
+
+// ProcessStarter starts cmd's process, in place of cmd.Start, and returns the function to wait for it with in place
+// of cmd.Wait. It must leave cmd.Process set, and the wait function, like cmd.Wait, must set cmd.ProcessState
+// and not return until everything the process wrote has been copied to the cmd.Stdout and cmd.Stderr it was given.
+type ProcessStarter func(cmd *exec.Cmd) (wait func() error, err error)
+
+// startCmd is the default ProcessStarter.
+func startCmd(cmd *exec.Cmd) (wait func() error, err error) {
+	return cmd.Wait, cmd.Start()
+}
+
+// startFailureKind classifies err, the error from starting a process that never ran.
+func startFailureKind(err error) FailureKind {
+	switch {
+	case errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist):
+		return FailureKindNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return FailureKindPermission
+	default:
+		return FailureKindStart
+	}
+}
+
+// waitCmd calls wait, which waits for cmd's process as returned by its ProcessStarter, and returns its error,
+// except that a process that exited 0 succeeded even if WaitDelay expired: only a grandchild still holding its
+// output open outlived it.
+func waitCmd(cmd *exec.Cmd, wait func() error) error {
+	err := wait()
+	if errors.Is(err, exec.ErrWaitDelay) && cmd.ProcessState != nil && cmd.ProcessState.Success() {
+		return nil
+	}
+	return err
+}
+
+// defaultCancelGrace is how long a process sent CancelSignal has to exit before it is killed, when its task has no
+// WaitDelay.
+const defaultCancelGrace = 10 * time.Second
+
+// runCmd is cmd.Run, except the process is started with start, if it isn't nil, and is sent cancelSignal, or
+// killed, if runContext is done before it exits. A process sent cancelSignal is killed if it hasn't exited
+// waitDelay, or defaultCancelGrace, later. stoppedByContext is whether that is why the run ended, rather than
+// the process exiting on its own; a process that exits however it likes after receiving cancelSignal counts as
+// stopped.
+func runCmd(runContext context.Context, cmd *exec.Cmd, start ProcessStarter, cancelSignal syscall.Signal, waitDelay time.Duration) (stoppedByContext bool, err error) {
+	if err := runContext.Err(); err != nil {
+		return true, err
+	}
+	if start == nil {
+		start = startCmd
+	}
+	wait, err := start(cmd)
+	if err != nil {
+		return false, err
+	}
+	if runContext.Done() == nil {
+		return false, waitCmd(cmd, wait)
+	}
+
+	var stopping atomic.Bool
+	exited := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-runContext.Done():
+			stopping.Store(true)
+			if cancelSignal == 0 {
+				cmd.Process.Kill()
+				return
+			}
+			cmd.Process.Signal(cancelSignal)
+			if waitDelay <= 0 {
+				waitDelay = defaultCancelGrace
+			}
+			select {
+			case <-time.After(waitDelay):
+				cmd.Process.Kill()
+			case <-exited:
+			}
+		case <-exited:
+		}
+	}()
+	err = waitCmd(cmd, wait)
+	close(exited)
+	<-stopped
+	if !stopping.Load() {
+		return false, err
+	}
+	if cancelSignal == 0 && cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+		// It exited on its own before it could be killed.
+		return false, err
+	}
+	return true, err
+}
+
+func (ctx *GenericExecManager) notify(message string) {
+	if ctx.NotificationBatchWindow <= 0 {
+		ctx.notifyCallback(message)
+		return
+	}
+
+	ctx.notifyMutex.Lock()
+	ctx.pendingNotifications = append(ctx.pendingNotifications, message)
+	if ctx.NotificationBatchMax > 0 && len(ctx.pendingNotifications) >= ctx.NotificationBatchMax {
+		batch := ctx.takeNotificationBatch()
+		ctx.notifyMutex.Unlock()
+		ctx.notifyCallback(batch)
+		return
+	}
+	if ctx.notifyTimer == nil {
+		var timer *time.Timer
+		timer = time.AfterFunc(ctx.NotificationBatchWindow, func() {
+			ctx.notifyMutex.Lock()
+			if ctx.notifyTimer != timer {
+				// This batch was already delivered because it filled up.
+				ctx.notifyMutex.Unlock()
+				return
+			}
+			batch := ctx.takeNotificationBatch()
+			ctx.notifyMutex.Unlock()
+			ctx.notifyCallback(batch)
+		})
+		ctx.notifyTimer = timer
+	}
+	ctx.notifyMutex.Unlock()
+}
+
+// takeNotificationBatch must be called with notifyMutex held.
+func (ctx *GenericExecManager) takeNotificationBatch() string {
+	if ctx.notifyTimer != nil {
+		ctx.notifyTimer.Stop()
+		ctx.notifyTimer = nil
+	}
+	batch := strings.Join(ctx.pendingNotifications, "\n")
+	ctx.pendingNotifications = nil
+	return batch
+}
+
+// commandQueue holds the runs of a non-reentrant command that are waiting to start. senders counts the runs that
+// have looked the queue up and are on their way into it.
+type commandQueue struct {
+	runs    chan *taskRun
+	senders sync.WaitGroup
+}
+
+// closeWhenSent closes the queue once the runs on their way into it are in. The queue must already have been removed
+// from mutexQueues, so that no more senders can find it. It waits in the background, because a full queue can keep
+// a sender waiting for as long as the command runs, or is paused.
+func (queue *commandQueue) closeWhenSent() {
+	go func() {
+		queue.senders.Wait()
+		close(queue.runs)
+	}()
+}
+
+// mutexQueueConsumer runs the queued tasks one at a time, in the order they were enqueued, except while command is
+// paused. It holds the command's lock while each runs, to take turns with RunTaskSync.
+func (ctx *GenericExecManager) mutexQueueConsumer(command string, queue <-chan *taskRun) {
+	lock := ctx.commandLock(command)
 	for message, isOpen := <-queue; isOpen; message, isOpen = <-queue {
-		ctx.doRunRunRunDaDooRunRun(message.cmd, message.execTaskConfig, message.requestValues, message.resultChan)
+		ctx.waitWhilePaused(command, message)
+		select {
+		case lock <- struct{}{}:
+		case <-message.runContext.Done():
+			// watchQueuedRun hands it its cancelled result.
+			continue
+		}
+		if !message.claimed.CompareAndSwap(false, true) {
+			// It was cancelled while queued, and already has its result.
+			<-lock
+			continue
+		}
+		close(message.dequeued)
+		ctx.doRunRunRunDaDooRunRun(message)
+		<-lock
 	}
 }
 
@@ -198,45 +1849,70 @@ func (ctx *GenericExecManager) productionCmdFactory(name string, argValues Templ
 	}
 
 	cmd := exec.Command(name, renderedArgs...)
+	if len(ctx.AllowedCommands) > 0 && !isCommandAllowed(cmd.Path, ctx.AllowedCommands) {
+		return nil, fmt.Errorf("command \"%s\" is not allowed to run because it is not in AllowedCommands", cmd.Path)
+	}
 	return cmd, nil
 }
 
+func isCommandAllowed(path string, allowedCommands []string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range allowedCommands {
+		if filepath.IsAbs(allowed) && filepath.Clean(allowed) == absPath {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderArgTemplates renders each of args as a template with argValues. Args are rendered in order, and later ones
+// can include the rendered value of an earlier one with "arg", as in {{arg 0}}. Dot is the run's
+// RunOptions.TemplateData. For tasks with PlaceholderSyntax, args are rendered as placeholders instead.
 func RenderArgTemplates(args []string, argValues TemplateGetter) ([]string, error) {
-	funcMap := template.FuncMap{
-		"request": argValues.Get,
+	if usesPlaceholders(argValues) {
+		renderedArgs, err := renderPlaceholderArgs(args, requestValues(argValues))
+		if err == nil {
+			recordRenderedArgs(argValues, args, renderedArgs)
+		}
+		return renderedArgs, err
 	}
-	renderedArgs := make([]string, len(args))
-	for ix, templateString := range args {
+	renderedArgs := make([]string, 0, len(args))
+	funcMap := argListFuncMap(argValues, &renderedArgs)
+	for _, templateString := range args {
 		templateEngine := template.New("args processor").Funcs(funcMap)
 		tmpl, err := templateEngine.Parse(templateString)
 		if err != nil {
 			return nil, err
 		}
-		var outBuf bytes.Buffer
-		tmpl.Execute(&outBuf, nil)
-		renderedArgs[ix] = outBuf.String()
+		renderedArg, err := executeTemplate(tmpl, templateData(argValues), renderTimeout(argValues))
+		if err != nil {
+			return nil, err
+		}
+		renderedArgs = append(renderedArgs, renderedArg)
+	}
+	if err := secretFetchError(argValues); err != nil {
+		return nil, err
 	}
+	recordRenderedArgs(argValues, args, renderedArgs)
 	return renderedArgs, nil
 }
 
-func renderMessageTemplate(messageTemplate string, values TemplateGetter, stdout *string, stderr *string) (string, error) {
-	funcMap := template.FuncMap{
-		"request": values.Get,
-		"StdOut": func() string {
-			return strings.Trim(*stdout, " \n")
-		},
-		"StdErr": func() string {
-			return strings.Trim(*stderr, " \n")
-		},
-	}
-	templateEngine := template.New("Message processor").Funcs(funcMap)
+// renderMessageTemplate renders a SuccessMessage or ErrorMessage template. Any trimming of stdout and stderr is
+// the caller's responsibility, so that the template sees exactly what the task configuration asked for.
+func renderMessageTemplate(messageTemplate string, values TemplateGetter, stdout string, stderr string, messageOutput string, run messageRunInfo) (string, error) {
+	templateEngine := template.New("Message processor").Funcs(messageFuncMap(values, stdout, stderr, messageOutput, run))
 	tmpl, err := templateEngine.Parse(messageTemplate)
 	if err != nil {
 		return "", err
 	}
-	var outBuf bytes.Buffer
-	tmpl.Execute(&outBuf, nil)
-	return outBuf.String(), nil
+	rendered, err := executeTemplate(tmpl, templateData(values), renderTimeout(values))
+	if err == nil {
+		err = secretFetchError(values)
+	}
+	return rendered, err
 }
 
 func cmdStringApproximation(cmd *exec.Cmd) string {
@@ -1,15 +1,28 @@
 package genericexec
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func newTestLogger() (*log.Logger, *bytes.Buffer) {
@@ -152,6 +165,47 @@ func TestGenericExecManager_Fail_Nonreentrant(t *testing.T) {
 	genericExecManagerTestCore(t, taskConfigs, []string{"test"}, []TemplateGetter{url.Values{"value1": []string{"a"}}}, []expectedResult{expect})
 }
 
+func TestGenericExecManager_RawMessageOutput(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:             "test",
+			Command:          "test",
+			Args:             []string{"{{request \"value1\"}}"},
+			SuccessMessage:   "[{{StdOut}}]",
+			Reentrant:        true,
+			RawMessageOutput: true,
+		},
+	}
+	expect := expectedResult{
+		result: &GenericExecResult{
+			StdOut:  "a\tb",
+			Message: "[\ta\tb\r\n]",
+		},
+		notificationExpects: []string{"[\ta\tb\r\n]"},
+	}
+	genericExecManagerTestCore(t, taskConfigs, []string{"test"}, []TemplateGetter{url.Values{"value1": []string{"\ta\tb\r\n"}}}, []expectedResult{expect})
+}
+
+func TestGenericExecManager_MessageOutputTrimmed(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "test",
+			Args:           []string{"{{request \"value1\"}}"},
+			SuccessMessage: "[{{StdOut}}]",
+			Reentrant:      true,
+		},
+	}
+	expect := expectedResult{
+		result: &GenericExecResult{
+			StdOut:  "a\tb",
+			Message: "[a\tb]",
+		},
+		notificationExpects: []string{"[a\tb]"},
+	}
+	genericExecManagerTestCore(t, taskConfigs, []string{"test"}, []TemplateGetter{url.Values{"value1": []string{"\ta\tb\r\n"}}}, []expectedResult{expect})
+}
+
 func TestGenericExecManager_reuse(t *testing.T) {
 	taskConfigs := map[string]GenericExecConfig{
 		"test-reentrant": {
@@ -193,6 +247,121 @@ func TestGenericExecManager_reuse(t *testing.T) {
 	genericExecManagerTestCore(t, taskConfigs, taskNames, taskArgs, expects)
 }
 
+func TestGenericExecManager_Nonreentrant_FIFO(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "test",
+			Args:           []string{"{{request \"value1\"}}"},
+			SuccessMessage: "{{StdOut}}",
+			Reentrant:      false,
+		},
+		"test-sibling": {
+			Name:           "test-sibling",
+			Command:        "test",
+			Args:           []string{"{{request \"value1\"}}"},
+			SuccessMessage: "{{StdOut}}",
+			Reentrant:      false,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var mutex sync.Mutex
+	var notifications []string
+	sut.notifyCallback = func(message string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		notifications = append(notifications, message)
+	}
+
+	// Alternate between two tasks sharing a queue; submission order should hold across both of them.
+	resultChans := make([]<-chan GenericExecResult, 20)
+	for i := range resultChans {
+		taskName := "test"
+		if i%2 == 1 {
+			taskName = "test-sibling"
+		}
+		resultChans[i] = sut.RunTask(taskName, url.Values{"value1": []string{fmt.Sprintf("Invocation %d", i+1)}})
+	}
+	for i, resultChan := range resultChans {
+		if result := <-resultChan; result.StdOut != fmt.Sprintf("Invocation %d", i+1) {
+			t.Errorf("Expected result %d to be from invocation %d, got \"%s\"", i+1, i+1, result.StdOut)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, notification := range notifications {
+		if notification != fmt.Sprintf("Invocation %d", i+1) {
+			t.Errorf("Expected invocation %d to complete in position %d, got \"%s\"", i+1, i+1, notification)
+		}
+	}
+}
+
+func TestGenericExecManager_RunTaskMap(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "test",
+			Args:      []string{"{{request \"value1\"}}", "[{{request \"missing\"}}]"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTaskMap("test", map[string]string{"value1": "a"})
+	if result.StdOut != "a []" {
+		t.Errorf("Expected StdOut \"a []\", got \"%s\"", result.StdOut)
+	}
+}
+
+func TestGenericExecManager_RunTaskForEach(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"300ms", "{{request \"value1\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	sut.CmdFactory = func(original func(string, TemplateGetter, ...string) (*exec.Cmd, error)) func(string, TemplateGetter, ...string) (*exec.Cmd, error) {
+		return func(name string, argValues TemplateGetter, arg ...string) (*exec.Cmd, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			return original(name, argValues, arg...)
+		}
+	}(sut.CmdFactory)
+	sut.OnResult = func(GenericExecResult) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		running--
+	}
+
+	getters := make([]TemplateGetter, 10)
+	for i := range getters {
+		getters[i] = MapGetter{"value1": fmt.Sprintf("Input %d", i+1)}
+	}
+	results := sut.RunTaskForEach("slow", getters, 3)
+
+	if len(results) != len(getters) {
+		t.Fatalf("Expected %d results, got %d", len(getters), len(results))
+	}
+	for i, result := range results {
+		if expect := fmt.Sprintf("Input %d", i+1); result.StdOut != expect || result.ExitCode != 0 {
+			t.Errorf("Expected result %d to have StdOut \"%s\", got %+v", i+1, expect, result)
+		}
+	}
+	if maxRunning != 3 {
+		t.Errorf("Expected at most 3 runs in flight, and to reach 3, got %d", maxRunning)
+	}
+}
+
 func genericExecManagerTestCore(t *testing.T, taskConfigs map[string]GenericExecConfig, taskNamesSlice []string, taskArgsSlice []TemplateGetter, expectsSlice []expectedResult) {
 	sut, testLogBuf, notifications := sutFactory(taskConfigs, nil)
 	for i, taskName := range taskNamesSlice {
@@ -244,6 +413,79 @@ func genericExecManagerTestCore(t *testing.T, taskConfigs map[string]GenericExec
 	}
 }
 
+func batchingSutFactory(batchMax int) (*GenericExecManager, func() []string) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "test",
+			Args:           []string{"{{request \"value1\"}}"},
+			SuccessMessage: "{{StdOut}}",
+			Reentrant:      true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var mutex sync.Mutex
+	var notifications []string
+	sut.notifyCallback = func(message string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		notifications = append(notifications, message)
+	}
+	sut.NotificationBatchWindow = 50 * time.Millisecond
+	sut.NotificationBatchMax = batchMax
+
+	return sut, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string{}, notifications...)
+	}
+}
+
+func TestGenericExecManager_NotificationBatching(t *testing.T) {
+	sut, getNotifications := batchingSutFactory(0)
+	// The runs are started together so that they all finish well within the batch window.
+	var resultChans []<-chan GenericExecResult
+	for i := 1; i <= 3; i++ {
+		resultChans = append(resultChans, sut.RunTask("test", url.Values{"value1": []string{fmt.Sprintf("Invocation %d", i)}}))
+	}
+	for _, resultChan := range resultChans {
+		<-resultChan
+	}
+	if notifications := getNotifications(); len(notifications) != 0 {
+		t.Errorf("Expected no notifications before the batch window elapsed, got %v", notifications)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	notifications := getNotifications()
+	if len(notifications) != 1 {
+		t.Fatalf("Expected 1 aggregated notification, got %d", len(notifications))
+	}
+	lines := strings.Split(notifications[0], "\n")
+	sort.Strings(lines)
+	expect := "Invocation 1\nInvocation 2\nInvocation 3"
+	if strings.Join(lines, "\n") != expect {
+		t.Errorf("Expected aggregated notification \"%s\", got \"%s\"", expect, notifications[0])
+	}
+}
+
+func TestGenericExecManager_NotificationBatchMax(t *testing.T) {
+	sut, getNotifications := batchingSutFactory(2)
+	// Notifying directly keeps the notifications well within the short batch window.
+	for i := 1; i <= 3; i++ {
+		sut.notify(fmt.Sprintf("Invocation %d", i))
+	}
+	notifications := getNotifications()
+	if len(notifications) != 1 || notifications[0] != "Invocation 1\nInvocation 2" {
+		t.Errorf("Expected the first 2 notifications to be delivered as soon as the batch filled, got %v", notifications)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	notifications = getNotifications()
+	if len(notifications) != 2 || notifications[1] != "Invocation 3" {
+		t.Errorf("Expected the remaining notification to be delivered after the batch window, got %v", notifications)
+	}
+}
+
 func TestNewGenericExecManager_FactoryError(t *testing.T) {
 	taskConfigs := map[string]GenericExecConfig{
 		"test": {
@@ -278,19 +520,1531 @@ func TestNewGenericExecManager_FactoryError(t *testing.T) {
 	}
 }
 
-// Mock process exec bodies
-func TestHelperExecHandler(t *testing.T) {
-	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
-		return
+func TestGenericExecManager_ReplaceConfigs(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"500ms", "old config"},
+			Reentrant: false,
+		},
 	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	runningResultChan := sut.RunTask("slow", url.Values{})
 
-	if os.Args[3] == "fail" {
-		// Echo the received arguments on StdErr and exit 2
-		fmt.Fprintf(os.Stderr, "%s", strings.Join(os.Args[4:], " "))
-		os.Exit(2)
+	err := sut.ReplaceConfigs(map[string]GenericExecConfig{
+		"replacement": {
+			Name:      "replacement",
+			Command:   "test",
+			Args:      []string{"new config"},
+			Reentrant: false,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error replacing configs: %v", err)
 	}
 
-	// Echo the received arguments on StdOut and exit 0
-	fmt.Print(strings.Join(os.Args[4:], " "))
-	os.Exit(0)
+	if result := <-runningResultChan; result.StdOut != "old config" || result.ExitCode != 0 {
+		t.Errorf("Expected the in-flight task to finish under the old config, got %+v", result)
+	}
+	if sut.IsTaskConfigured("slow") {
+		t.Error("Expected task \"slow\" to be removed")
+	}
+	if !sut.IsTaskConfigured("replacement") {
+		t.Fatal("Expected task \"replacement\" to be configured")
+	}
+	if _, stillQueued := sut.mutexQueues["sleep"]; stillQueued {
+		t.Error("Expected the queue for the removed command to be shut down")
+	}
+	if result := <-sut.RunTask("replacement", url.Values{}); result.StdOut != "new config" {
+		t.Errorf("Expected the replacement task to run, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_ReplaceConfigs_Invalid(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:    "test",
+			Command: "test",
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	err := sut.ReplaceConfigs(map[string]GenericExecConfig{
+		"broken": {
+			Name:    "broken",
+			Command: "test",
+			Args:    []string{"{{request \"value1\""},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "task \"broken\"") {
+		t.Errorf("Expected a validation error naming task \"broken\", got %v", err)
+	}
+	if !sut.IsTaskConfigured("test") || sut.IsTaskConfigured("broken") {
+		t.Error("Expected the original configs to remain in place after a failed replacement")
+	}
+}
+
+func TestGenericExecManager_RunTaskContext_Cancel(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"10s"},
+			Reentrant: false,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runContext, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := <-sut.RunTaskContext(runContext, "slow", url.Values{})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the cancelled task to complete promptly, took %v", elapsed)
+	}
+	if result.ExitCode == 0 {
+		t.Error("Expected a nonzero exit code from a cancelled task")
+	}
+
+	// A context that is already done prevents the task from starting at all.
+	start = time.Now()
+	result = <-sut.RunTaskContext(runContext, "slow", url.Values{})
+	if elapsed := time.Since(start); elapsed > time.Second || result.ExitCode == 0 {
+		t.Errorf("Expected the task not to start with a done context, got %+v after %v", result, elapsed)
+	}
+}
+
+func TestGenericExecManager_PartialOutputOnTimeout(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"hang": {
+			Name:      "hang",
+			Command:   "hang",
+			Args:      []string{"connecting", "waiting for lock"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runContext, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	result := <-sut.RunTaskContext(runContext, "hang", url.Values{})
+	if result.FailureKind != FailureKindTimeout {
+		t.Errorf("Expected the hung task to time out, got %+v", result)
+	}
+	if expect := "connecting\nwaiting for lock"; result.StdOut != expect || result.StdErr != expect {
+		t.Errorf("Expected the output from before the timeout \"%s\" on both streams, got %+v", expect, result)
+	}
+
+	runContext, cancel = context.WithCancel(context.Background())
+	resultChan := sut.RunTaskContext(runContext, "hang", url.Values{})
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	if result := <-resultChan; result.FailureKind != FailureKindCancelled || !strings.HasPrefix(string(result.StdOutBytes), "connecting\n") {
+		t.Errorf("Expected the output from before cancellation in the result, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_CancelSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Signals other than kill are not supported on Windows")
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"trapint": {
+			Name:         "trapint",
+			Command:      "trapint",
+			Reentrant:    true,
+			CancelSignal: syscall.SIGINT,
+			WaitDelay:    5 * time.Second,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runContext, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	result := <-sut.RunTaskContext(runContext, "trapint", url.Values{})
+	if result.ExitCode != 0 || result.StdOut != "ready\ncleaned up" {
+		t.Errorf("Expected the task to clean up and exit 0 on SIGINT, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_WaitDelay(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"orphan": {
+			Name:      "orphan",
+			Command:   "orphan",
+			Args:      []string{"10s"},
+			Reentrant: true,
+			WaitDelay: 100 * time.Millisecond,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runContext, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	<-sut.RunTaskContext(runContext, "orphan", url.Values{})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected WaitDelay to stop waiting on the grandchild's output, took %v", elapsed)
+	}
+}
+
+func TestGenericExecManager_WaitDelayUncancelled(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"orphan": {
+			Name:      "orphan",
+			Command:   "orphan-exit",
+			Args:      []string{"10s"},
+			Reentrant: true,
+			WaitDelay: 100 * time.Millisecond,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	start := time.Now()
+	result := <-sut.RunTask("orphan", url.Values{})
+	if !result.Succeeded || result.ExitCode != 0 || result.StdOut != "hi" {
+		t.Errorf("Expected a process that exited 0 to succeed though its grandchild held its output, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected WaitDelay to stop waiting on the grandchild's output, took %v", elapsed)
+	}
+}
+
+func TestGenericExecManager_OnResult(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"reentrant": {
+			Name:      "reentrant",
+			Command:   "test",
+			Args:      []string{"reentrant"},
+			Reentrant: true,
+		},
+		"queued": {
+			Name:      "queued",
+			Command:   "fail",
+			Args:      []string{"queued"},
+			Reentrant: false,
+		},
+		"broken": {
+			Name:    "broken",
+			Command: "test",
+			Args:    []string{"{{request \"value1\""},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var mutex sync.Mutex
+	observed := map[string]GenericExecResult{}
+	sut.OnResult = func(result GenericExecResult) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		observed[result.Name] = result
+	}
+
+	for _, taskName := range []string{"reentrant", "queued", "broken"} {
+		result := <-sut.RunTask(taskName, url.Values{})
+
+		mutex.Lock()
+		observedResult, found := observed[taskName]
+		mutex.Unlock()
+		if !found {
+			t.Errorf("Expected OnResult to observe the result of task %s", taskName)
+		} else if !reflect.DeepEqual(observedResult, result) {
+			t.Errorf("Expected OnResult to observe %+v for task %s, got %+v", result, taskName, observedResult)
+		}
+	}
+}
+
+func TestGenericExecManager_ExtraFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ExtraFiles is not supported on Windows")
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "readfd3",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipeReader.Close()
+	pipeWriter.WriteString("sent over fd 3")
+	pipeWriter.Close()
+
+	result := <-sut.RunTaskWithOptions(context.Background(), "test", url.Values{}, RunOptions{ExtraFiles: []*os.File{pipeReader}})
+	if result.StdOut != "sent over fd 3" {
+		t.Errorf("Expected the child to read \"sent over fd 3\" from fd 3, got \"%s\" (StdErr \"%s\")", result.StdOut, result.StdErr)
+	}
+}
+
+func TestGenericExecManager_Parser(t *testing.T) {
+	lastInteger := func(stdout, stderr string) (interface{}, error) {
+		lines := strings.Split(stdout, "\n")
+		return strconv.Atoi(lines[len(lines)-1])
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "test",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+			Parser:    lastInteger,
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"value1": []string{"Counted\n42"}})
+	if result.Parsed != 42 || result.ParseError != nil {
+		t.Errorf("Expected Parsed 42 and no ParseError, got %v and %v", result.Parsed, result.ParseError)
+	}
+
+	result = <-sut.RunTask("test", url.Values{"value1": []string{"Counted\nmany"}})
+	if result.ParseError == nil {
+		t.Error("Expected a ParseError for output that does not end in an integer")
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("Expected a ParseError not to change the exit code, got %d", result.ExitCode)
+	}
+	if !strings.Contains(testLogBuf.String(), "Could not parse output") {
+		t.Errorf("Expected the parse error to be logged, got \"%s\"", testLogBuf.String())
+	}
+}
+
+func TestGenericExecManager_QueueWaitAndExecTime(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"500ms"},
+			Reentrant: false,
+		},
+		"slow-reentrant": {
+			Name:      "slow-reentrant",
+			Command:   "sleep",
+			Args:      []string{"500ms"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	firstChan := sut.RunTask("slow", url.Values{})
+	secondChan := sut.RunTask("slow", url.Values{})
+	reentrantChan := sut.RunTask("slow-reentrant", url.Values{})
+	first, second, reentrant := <-firstChan, <-secondChan, <-reentrantChan
+
+	for _, result := range []GenericExecResult{first, second, reentrant} {
+		if result.ExecTime < 500*time.Millisecond {
+			t.Errorf("Expected ExecTime of at least the command's 500ms, got %v", result.ExecTime)
+		}
+	}
+	if second.QueueWait < 400*time.Millisecond {
+		t.Errorf("Expected the second run to wait in the queue for the first, got QueueWait %v", second.QueueWait)
+	}
+	if second.QueueWait <= first.QueueWait {
+		t.Errorf("Expected the second run to wait longer than the first, got %v and %v", second.QueueWait, first.QueueWait)
+	}
+	if reentrant.QueueWait != 0 {
+		t.Errorf("Expected zero QueueWait for a reentrant task, got %v", reentrant.QueueWait)
+	}
+}
+
+func TestGenericExecManager_HashOutput(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:       "test",
+			Command:    "test",
+			Args:       []string{"{{request \"value1\"}}"},
+			Reentrant:  true,
+			HashOutput: true,
+		},
+		"test-unhashed": {
+			Name:      "test-unhashed",
+			Command:   "test",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"value1": []string{"hello"}})
+	if expect := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; result.StdOutHash != expect {
+		t.Errorf("Expected StdOutHash %s, got %s", expect, result.StdOutHash)
+	}
+
+	// The hash covers the output before it is trimmed.
+	result = <-sut.RunTask("test", url.Values{"value1": []string{"hello\n"}})
+	if expect := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"; result.StdOutHash != expect {
+		t.Errorf("Expected StdOutHash %s, got %s", expect, result.StdOutHash)
+	}
+
+	result = <-sut.RunTask("test-unhashed", url.Values{"value1": []string{"hello"}})
+	if result.StdOutHash != "" {
+		t.Errorf("Expected no StdOutHash without HashOutput, got %s", result.StdOutHash)
+	}
+}
+
+func TestGenericExecManager_HashOutputTruncated(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "test",
+			Args:           []string{"{{request \"value1\"}}"},
+			HashOutput:     true,
+			MaxOutputBytes: 3,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	// The hash covers all of the output, not just what MaxOutputBytes kept.
+	result := <-sut.RunTask("test", url.Values{"value1": []string{"hello"}})
+	if !result.OutputTruncated {
+		t.Errorf("Expected the output to be truncated, got %+v", result)
+	}
+	if expect := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; result.StdOutHash != expect {
+		t.Errorf("Expected StdOutHash %s, got %s", expect, result.StdOutHash)
+	}
+}
+
+func TestGenericExecManager_Shutdown(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"200ms", "finished"},
+			Reentrant: false,
+		},
+		"test": {
+			Name:      "test",
+			Command:   "test",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	queuedChans := []<-chan GenericExecResult{sut.RunTask("slow", url.Values{}), sut.RunTask("slow", url.Values{})}
+
+	sut.Shutdown()
+	for _, taskName := range []string{"slow", "test"} {
+		result := <-sut.RunTask(taskName, url.Values{})
+		if result.FailureKind != FailureKindShuttingDown || result.ExitCode == 0 {
+			t.Errorf("Expected task %s to be rejected after shutdown, got %+v", taskName, result)
+		}
+	}
+	for _, queuedChan := range queuedChans {
+		if result := <-queuedChan; result.StdOut != "finished" || result.FailureKind != FailureKindNone {
+			t.Errorf("Expected tasks queued before shutdown to finish, got %+v", result)
+		}
+	}
+	if err := sut.ReplaceConfigs(taskConfigs); err == nil {
+		t.Error("Expected ReplaceConfigs to fail after shutdown")
+	}
+}
+
+func TestGenericExecManager_MaxArgBytes(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "echo",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+		},
+		"env": {
+			Name:      "env",
+			Command:   "echo",
+			Args:      []string{"short"},
+			Env:       map[string]string{"BIG": strings.Repeat("x", 1000)},
+			Reentrant: true,
+		},
+	}
+	testLog, _ := newTestLogger()
+	sut := NewGenericExecManager(taskConfigs, testLog, func(string) {})
+	sut.MaxArgBytes = argListSize("", nil, os.Environ()) + 500
+
+	if result := <-sut.RunTask("test", url.Values{"value1": []string{"short"}}); result.ExitCode != 0 {
+		t.Errorf("Expected an argument list under the limit to run, got %+v", result)
+	}
+	result := <-sut.RunTask("test", url.Values{"value1": []string{strings.Repeat("x", 1000)}})
+	if result.ExitCode == 0 || !strings.Contains(result.StdErr, "argument list too long") {
+		t.Errorf("Expected an \"argument list too long\" error, got %+v", result)
+	}
+	// The environment counted is the one the process gets.
+	result = <-sut.RunTask("env", url.Values{})
+	if result.ExitCode == 0 || !strings.Contains(result.StdErr, "argument list too long") {
+		t.Errorf("Expected the task's Env to count toward the limit, got %+v", result)
+	}
+
+	if runtime.GOOS == "linux" {
+		sut.MaxArgBytes = PlatformMaxArgBytes
+		result = <-sut.RunTask("test", url.Values{"value1": []string{strings.Repeat("x", 200*1024)}})
+		if result.ExitCode == 0 || !strings.Contains(result.StdErr, "argument 1 is 204800 bytes") {
+			t.Errorf("Expected an argument over the OS's limit for one argument to be reported, got %+v", result)
+		}
+	}
+}
+
+func TestGenericExecManager_OnOutputLine(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "test",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+		},
+		"fail": {
+			Name:      "fail",
+			Command:   "fail",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	for _, taskName := range []string{"test", "fail"} {
+		var lines []string
+		options := RunOptions{
+			OnOutputLine: func(stream OutputStream, line string) {
+				lines = append(lines, fmt.Sprintf("%s: %s", stream, line))
+			},
+		}
+		result := <-sut.RunTaskWithOptions(context.Background(), taskName, url.Values{"value1": []string{"line 1\nline 2\n\npartial"}}, options)
+
+		stream := "stdout"
+		if taskName == "fail" {
+			stream = "stderr"
+		}
+		expect := []string{stream + ": line 1", stream + ": line 2", stream + ": ", stream + ": partial"}
+		if strings.Join(lines, "|") != strings.Join(expect, "|") {
+			t.Errorf("Expected lines %q, got %q", expect, lines)
+		}
+		if result.StdOut+result.StdErr != "line 1\nline 2\n\npartial" {
+			t.Errorf("Expected the output to still be captured in the result, got %+v", result)
+		}
+	}
+}
+
+func TestGenericExecManager_LineDelimiter(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"find": {
+			Name:          "find",
+			Command:       "print0",
+			Args:          []string{"./a", "./with space", "./with\nnewline"},
+			LineDelimiter: "\x00",
+			WatchPatterns: []string{"newline$"},
+			Reentrant:     true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var matches []string
+	sut.OnMatch = func(taskName string, pattern string, line string) {
+		matches = append(matches, line)
+	}
+
+	var lines []string
+	options := RunOptions{
+		OnOutputLine: func(stream OutputStream, line string) {
+			lines = append(lines, line)
+		},
+	}
+	<-sut.RunTaskWithOptions(context.Background(), "find", url.Values{}, options)
+	if expect := []string{"./a", "./with space", "./with\nnewline"}; !reflect.DeepEqual(lines, expect) {
+		t.Errorf("Expected null-delimited lines %q, got %q", expect, lines)
+	}
+	if !reflect.DeepEqual(matches, []string{"./with\nnewline"}) {
+		t.Errorf("Expected WatchPatterns to match whole null-delimited lines, got %q", matches)
+	}
+
+	invalid := map[string]GenericExecConfig{
+		"find": {Name: "find", Command: "find", LineDelimiter: "\r\n"},
+	}
+	if err := ValidateConfigs(invalid); err == nil {
+		t.Error("Expected a LineDelimiter of more than one byte to be rejected")
+	}
+}
+
+func TestGenericExecManager_WatchPatterns(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:          "test",
+			Command:       "lines",
+			Args:          []string{"200"},
+			WatchPatterns: []string{"^line 15$", "never printed"},
+			Reentrant:     true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var mutex sync.Mutex
+	var matches []string
+	var matchedAt time.Time
+	sut.OnMatch = func(taskName string, pattern string, line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		matches = append(matches, fmt.Sprintf("%s %s %s", taskName, pattern, line))
+		matchedAt = time.Now()
+	}
+
+	startedAt := time.Now()
+	result := <-sut.RunTask("test", url.Values{})
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(matches) != 1 || matches[0] != "test ^line 15$ line 15" {
+		t.Errorf("Expected one match, got %q", matches)
+	}
+	// The task prints a line every millisecond, so line 15 comes well before the end of its 200.
+	if matchedAt.Sub(startedAt) > result.QueueWait+result.ExecTime-100*time.Millisecond {
+		t.Errorf("Expected OnMatch to be called while the task was running, but it was called after %v of %v", matchedAt.Sub(startedAt), result.ExecTime)
+	}
+}
+
+func TestGenericExecManager_AllowedCommands(t *testing.T) {
+	allowedPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("No sh to allow")
+	}
+	allowedPath, _ = filepath.Abs(allowedPath)
+	testLog, _ := newTestLogger()
+	sut := NewGenericExecManager(map[string]GenericExecConfig{}, testLog, func(string) {})
+	sut.AllowedCommands = []string{allowedPath}
+
+	if _, err := sut.CmdFactory("sh", url.Values{}, "-c", "true"); err != nil {
+		t.Errorf("Expected %s to be allowed, got %v", allowedPath, err)
+	}
+	if _, err := sut.CmdFactory(allowedPath, url.Values{}, "-c", "true"); err != nil {
+		t.Errorf("Expected %s to be allowed, got %v", allowedPath, err)
+	}
+	for _, disallowed := range []string{os.Args[0], "definitely-not-a-real-command", "./sh"} {
+		if _, err := sut.CmdFactory(disallowed, url.Values{}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("Expected %s to be rejected by the allowlist, got %v", disallowed, err)
+		}
+	}
+}
+
+func TestGenericExecManager_OutputBytes(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"binary": {
+			Name:      "binary",
+			Command:   "binary",
+			Reentrant: true,
+		},
+		"fail": {
+			Name:      "fail",
+			Command:   "fail",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	expect := make([]byte, 256)
+	for b := range expect {
+		expect[b] = byte(b)
+	}
+	result := <-sut.RunTask("binary", url.Values{})
+	if !bytes.Equal(result.StdOutBytes, expect) {
+		t.Errorf("Expected StdOutBytes to be every byte value in order, got %v", result.StdOutBytes)
+	}
+
+	result = <-sut.RunTask("fail", url.Values{"value1": []string{" untrimmed\t\n"}})
+	if string(result.StdErrBytes) != " untrimmed\t\n" {
+		t.Errorf("Expected StdErrBytes to be untrimmed, got %q", result.StdErrBytes)
+	}
+}
+
+func TestGenericExecManager_PrefixLogLines(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"fail": {
+			Name:         "fail",
+			Command:      "fail",
+			Args:         []string{"line 1\nline 2"},
+			ErrorMessage: "failed",
+			Reentrant:    true,
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+	sut.PrefixLogLines = true
+
+	<-sut.RunTask("fail", url.Values{})
+	logLines := strings.Split(strings.TrimSuffix(testLogBuf.String(), "\n"), "\n")
+	if len(logLines) < 4 {
+		t.Fatalf("Expected a multi-line log message, got \"%s\"", testLogBuf.String())
+	}
+	for _, line := range logLines {
+		if !strings.HasPrefix(line, "[fail] ") {
+			t.Errorf("Expected log line \"%s\" to be prefixed with the task name", line)
+		}
+	}
+
+	testLogBuf.Reset()
+	sut.PrefixLogLinesWithPid = true
+	<-sut.RunTask("fail", url.Values{})
+	logLines = strings.Split(strings.TrimSuffix(testLogBuf.String(), "\n"), "\n")
+	prefix := logLines[0][:strings.Index(logLines[0], "]")+2]
+	var pid int
+	if _, err := fmt.Sscanf(prefix, "[fail %d] ", &pid); err != nil || pid <= 0 {
+		t.Errorf("Expected a prefix with the task name and PID, got \"%s\"", prefix)
+	}
+	for _, line := range logLines {
+		if !strings.HasPrefix(line, prefix) {
+			t.Errorf("Expected log line \"%s\" to be prefixed with \"%s\"", line, prefix)
+		}
+	}
+}
+
+func TestGenericExecManager_Heartbeat(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"1s"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var mutex sync.Mutex
+	var heartbeats []time.Duration
+	sut.Heartbeat = func(taskName string, elapsed time.Duration) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if taskName != "slow" {
+			t.Errorf("Expected a heartbeat for task slow, got %s", taskName)
+		}
+		heartbeats = append(heartbeats, elapsed)
+	}
+	sut.HeartbeatInterval = 200 * time.Millisecond
+
+	<-sut.RunTask("slow", url.Values{})
+	mutex.Lock()
+	count := len(heartbeats)
+	if count < 3 {
+		t.Errorf("Expected at least 3 heartbeats during a 1s task, got %d", count)
+	}
+	for i := 1; i < count; i++ {
+		if heartbeats[i] <= heartbeats[i-1] {
+			t.Errorf("Expected increasing elapsed times, got %v", heartbeats)
+		}
+	}
+	mutex.Unlock()
+
+	time.Sleep(500 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(heartbeats) != count {
+		t.Errorf("Expected no heartbeats after the task completed, got %d more", len(heartbeats)-count)
+	}
+}
+
+type envGetter struct {
+	MapGetter
+	env map[string]string
+}
+
+func (getter envGetter) Env() map[string]string {
+	return getter.env
+}
+
+func TestGenericExecManager_RequestEnv(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"printenv": {
+			Name:      "printenv",
+			Command:   "printenv",
+			Args:      []string{"FROM_REQUEST", "FROM_CONFIG", "OVERRIDDEN", "GO_WANT_HELPER_PROCESS"},
+			Reentrant: true,
+			Env:       map[string]string{"FROM_CONFIG": "config", "OVERRIDDEN": "config"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	getter := envGetter{env: map[string]string{"FROM_REQUEST": "s3cret", "OVERRIDDEN": "request"}}
+	result := <-sut.RunTask("printenv", getter)
+	expect := "FROM_REQUEST=s3cret\nFROM_CONFIG=config\nOVERRIDDEN=request\nGO_WANT_HELPER_PROCESS=1"
+	if result.StdOut != expect {
+		t.Errorf("Expected environment \"%s\", got \"%s\"", expect, result.StdOut)
+	}
+
+	result = <-sut.RunTask("printenv", MapGetter{})
+	expect = "FROM_REQUEST=\nFROM_CONFIG=config\nOVERRIDDEN=config\nGO_WANT_HELPER_PROCESS=1"
+	if result.StdOut != expect {
+		t.Errorf("Expected environment \"%s\", got \"%s\"", expect, result.StdOut)
+	}
+}
+
+func TestGenericExecManager_ResolvedEnv(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"printenv": {
+			Name:       "printenv",
+			Command:    "printenv",
+			Args:       []string{"KEPT", "DROPPED", "OVERRIDDEN"},
+			Reentrant:  true,
+			Env:        map[string]string{"FROM_CONFIG": "config", "OVERRIDDEN": "config"},
+			UnsetEnv:   []string{"DROPPED"},
+			SecretKeys: []string{"token"},
+		},
+		"inherited": {
+			Name:      "inherited",
+			Command:   "printenv",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	helperCmdFactory := sut.CmdFactory
+	sut.CmdFactory = func(name string, argValues TemplateGetter, arg ...string) (*exec.Cmd, error) {
+		cmd, err := helperCmdFactory(name, argValues, arg...)
+		if cmd != nil {
+			cmd.Env = append(cmd.Env, "KEPT=parent", "DROPPED=parent", "OVERRIDDEN=parent")
+		}
+		return cmd, err
+	}
+
+	getter := envGetter{MapGetter: MapGetter{"token": "s3cret"}, env: map[string]string{"FROM_REQUEST": "s3cret"}}
+	result := <-sut.RunTask("printenv", getter)
+	expect := []string{"GO_WANT_HELPER_PROCESS=1", "KEPT=parent", "OVERRIDDEN=config", "FROM_CONFIG=config", "FROM_REQUEST=[REDACTED]"}
+	if !reflect.DeepEqual(result.ResolvedEnv, expect) {
+		t.Errorf("Expected resolved environment %q, got %q", expect, result.ResolvedEnv)
+	}
+	if result.StdOut != "KEPT=parent\nDROPPED=\nOVERRIDDEN=config" {
+		t.Errorf("Expected the process to see the resolved environment, got \"%s\"", result.StdOut)
+	}
+
+	if result := <-sut.RunTask("inherited", url.Values{}); result.ResolvedEnv != nil {
+		t.Errorf("Expected no resolved environment for a task that doesn't change it, got %q", result.ResolvedEnv)
+	}
+}
+
+func TestGenericExecManager_Sensitive(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "test",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+			Sensitive: true,
+		},
+		"fail": {
+			Name:      "fail",
+			Command:   "fail",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+			Sensitive: true,
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+
+	for _, taskName := range []string{"test", "fail"} {
+		testLogBuf.Reset()
+		result := <-sut.RunTask(taskName, url.Values{"value1": []string{"hunter2"}})
+		if result.StdOut+result.StdErr != "hunter2" {
+			t.Errorf("Expected sensitive output to still be returned in the result, got %+v", result)
+		}
+		logStuff := testLogBuf.String()
+		if strings.Contains(logStuff, "hunter2") {
+			t.Errorf("Expected no sensitive output in the log, got \"%s\"", logStuff)
+		}
+		if !strings.Contains(logStuff, fmt.Sprintf("Command for sensitive task \"%s\" exited", taskName)) {
+			t.Errorf("Expected the log to still report the task's exit, got \"%s\"", logStuff)
+		}
+	}
+
+	// Errors preparing the command quote the rendered args.
+	taskConfigs["checked"] = GenericExecConfig{
+		Name:        "checked",
+		Command:     "test",
+		Args:        []string{"{{request \"value1\"}}"},
+		ArgPatterns: []string{`[a-z]+`},
+		Reentrant:   true,
+		Sensitive:   true,
+	}
+	sut, testLogBuf, _ = sutFactory(taskConfigs, nil)
+	result := <-sut.RunTask("checked", url.Values{"value1": []string{"hunter2-Password"}})
+	if result.ExitCode == 0 || !strings.Contains(result.StdErr, "hunter2-Password") {
+		t.Errorf("Expected the preparation error in the result, got %+v", result)
+	}
+	logStuff := testLogBuf.String()
+	if strings.Contains(logStuff, "hunter2") {
+		t.Errorf("Expected no sensitive args in the log, got \"%s\"", logStuff)
+	}
+	if !strings.Contains(logStuff, "Could not prepare an executable command from the configuration for sensitive task checked.") {
+		t.Errorf("Expected the log to still report the failure, got \"%s\"", logStuff)
+	}
+}
+
+func TestGenericExecManager_Sensitive_Notification(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "test",
+			Args:           []string{"{{request \"value1\"}}"},
+			SuccessMessage: "got {{StdOut}}",
+			Reentrant:      true,
+			Sensitive:      true,
+		},
+		"fail": {
+			Name:         "fail",
+			Command:      "fail",
+			Args:         []string{"{{request \"value1\"}}"},
+			ErrorMessage: "failed with {{StdErr}}",
+			Reentrant:    true,
+			Sensitive:    true,
+		},
+	}
+	sut, testLogBuf, notifications := sutFactory(taskConfigs, nil)
+
+	for _, logJSON := range []bool{false, true} {
+		sut.LogJSON = logJSON
+		for _, taskName := range []string{"test", "fail"} {
+			testLogBuf.Reset()
+			<-sut.RunTask(taskName, url.Values{"value1": []string{"hunter2"}})
+			if sent := **notifications; len(sent) == 0 || !strings.Contains(sent[len(sent)-1], "hunter2") {
+				t.Errorf("Expected the notification to still quote the output, got %v", sent)
+			}
+			if !strings.Contains(testLogBuf.String(), taskName) {
+				t.Errorf("Expected the run to be logged with LogJSON %v", logJSON)
+			}
+			if logStuff := testLogBuf.String(); strings.Contains(logStuff, "hunter2") {
+				t.Errorf("Expected no sensitive notification in the log with LogJSON %v, got \"%s\"", logJSON, logStuff)
+			}
+		}
+	}
+}
+
+func TestGenericExecManager_FailureLogContext(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"fail": {
+			Name:      "fail",
+			Command:   "fail",
+			Reentrant: true,
+			Env:       map[string]string{"FROM_CONFIG": "config"},
+		},
+		"sensitive": {
+			Name:      "sensitive",
+			Command:   "fail",
+			Reentrant: true,
+			Sensitive: true,
+			Env:       map[string]string{"FROM_CONFIG": "config"},
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+	workDir := t.TempDir()
+	cmdFactory := sut.CmdFactory
+	sut.CmdFactory = func(name string, argValues TemplateGetter, arg ...string) (*exec.Cmd, error) {
+		cmd, err := cmdFactory(name, argValues, arg...)
+		if cmd != nil {
+			cmd.Dir = workDir
+		}
+		return cmd, err
+	}
+	getter := envGetter{env: map[string]string{"FROM_REQUEST": "request"}}
+
+	<-sut.RunTask("fail", getter)
+	logStuff := testLogBuf.String()
+	if !strings.Contains(logStuff, "In working directory: "+workDir) {
+		t.Errorf("Expected the working directory in the failure log, got \"%s\"", logStuff)
+	}
+	if strings.Contains(logStuff, "FROM_CONFIG") {
+		t.Errorf("Expected no environment in the log without LogEnvOnFailure, got \"%s\"", logStuff)
+	}
+
+	sut.LogEnvOnFailure = true
+	testLogBuf.Reset()
+	<-sut.RunTask("fail", getter)
+	logStuff = testLogBuf.String()
+	if !strings.Contains(logStuff, "With environment additions: FROM_CONFIG=config FROM_REQUEST=request") {
+		t.Errorf("Expected the added environment in the failure log, got \"%s\"", logStuff)
+	}
+	if strings.Contains(logStuff, "GO_WANT_HELPER_PROCESS") {
+		t.Errorf("Expected no inherited environment in the failure log, got \"%s\"", logStuff)
+	}
+
+	testLogBuf.Reset()
+	<-sut.RunTask("sensitive", getter)
+	logStuff = testLogBuf.String()
+	if strings.Contains(logStuff, workDir) || strings.Contains(logStuff, "FROM_CONFIG") {
+		t.Errorf("Expected no working directory or environment in the log for a sensitive task, got \"%s\"", logStuff)
+	}
+}
+
+func TestGenericExecManager_StartFailure(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "/nonexistent/genericexec-test", Reentrant: true},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.CmdFactory = func(name string, argValues TemplateGetter, arg ...string) (*exec.Cmd, error) {
+		return exec.Command(name), nil
+	}
+
+	result := <-sut.RunTask("test", url.Values{})
+	if result.ExitCode != 1 || !strings.HasPrefix(result.StdErr, "Could not start the command: ") {
+		t.Errorf("Expected a command that can't start to fail and say why, got %+v", result)
+	}
+	if result.Succeeded || result.FailureKind != FailureKindNotFound {
+		t.Errorf("Expected a missing command to fail as %q, got %+v", FailureKindNotFound, result)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	notExecutable := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\necho hi\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sut.ReplaceConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: notExecutable, Reentrant: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	result = <-sut.RunTask("test", url.Values{})
+	if result.Succeeded || result.FailureKind != FailureKindPermission {
+		t.Errorf("Expected a command that isn't executable to fail as %q, got %+v", FailureKindPermission, result)
+	}
+
+	// A process that started and exited nonzero isn't a start failure.
+	sut.CmdFactory = func(name string, argValues TemplateGetter, arg ...string) (*exec.Cmd, error) {
+		return exec.Command("/bin/sh", "-c", "exit 3"), nil
+	}
+	result = <-sut.RunTask("test", url.Values{})
+	if result.ExitCode != 3 || result.FailureKind != FailureKindNone {
+		t.Errorf("Expected a process that ran and failed to report its exit code and no FailureKind, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_ClassifiesContextStops(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"exit": {Name: "exit", Command: "exit", Args: []string{"2"}, Reentrant: true},
+		"slow": {Name: "slow", Command: "sleep", Args: []string{"10s"}, Reentrant: true},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	timeoutContext, cancelTimeout := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelTimeout()
+	result := <-sut.RunTaskContext(timeoutContext, "exit", url.Values{})
+	if result.ExitCode != 2 || result.FailureKind != FailureKindNone {
+		t.Errorf("Expected a process that exits 2 on its own to report exit code 2 and no FailureKind, got %+v", result)
+	}
+
+	timeoutContext, cancelTimeout = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelTimeout()
+	result = <-sut.RunTaskContext(timeoutContext, "slow", url.Values{})
+	if result.ExitCode != -1 || result.FailureKind != FailureKindTimeout {
+		t.Errorf("Expected a process killed at its deadline to report exit code -1 and FailureKind %s, got %+v", FailureKindTimeout, result)
+	}
+
+	cancelContext, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	result = <-sut.RunTaskContext(cancelContext, "slow", url.Values{})
+	if result.ExitCode != -1 || result.FailureKind != FailureKindCancelled {
+		t.Errorf("Expected a process killed by cancellation to report exit code -1 and FailureKind %s, got %+v", FailureKindCancelled, result)
+	}
+
+	result = <-sut.RunTaskContext(cancelContext, "exit", url.Values{})
+	if result.ExitCode != -1 || result.FailureKind != FailureKindCancelled {
+		t.Errorf("Expected a run cancelled before it started to report exit code -1 and FailureKind %s, got %+v", FailureKindCancelled, result)
+	}
+}
+
+func TestGenericExecManager_ResultTransformer(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"exit": {
+			Name:      "exit",
+			Command:   "exit",
+			Args:      []string{"{{request \"code\"}}", "{{request \"output\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.ResultTransformer = func(result GenericExecResult) GenericExecResult {
+		if result.ExitCode == 3 && strings.Contains(result.StdOut, "nothing to do") {
+			result.ExitCode = 0
+		}
+		return result
+	}
+	var observed GenericExecResult
+	sut.OnResult = func(result GenericExecResult) {
+		observed = result
+	}
+
+	result := <-sut.RunTask("exit", url.Values{"code": []string{"3"}, "output": []string{"nothing to do"}})
+	if result.ExitCode != 0 || observed.ExitCode != 0 {
+		t.Errorf("Expected exit code 3 with benign output to be transformed to 0, got %d (observed %d)", result.ExitCode, observed.ExitCode)
+	}
+	result = <-sut.RunTask("exit", url.Values{"code": []string{"3"}, "output": []string{"disk on fire"}})
+	if result.ExitCode != 3 {
+		t.Errorf("Expected exit code 3 with other output to be left alone, got %d", result.ExitCode)
+	}
+}
+
+func TestGenericExecManager_Argv0(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"greet": {
+			Name:      "greet",
+			Command:   "multicall",
+			Argv0:     "{{request \"applet\"}}",
+			Args:      []string{"world"},
+			Reentrant: true,
+		},
+		"default": {
+			Name:      "default",
+			Command:   "multicall",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("greet", url.Values{"applet": []string{"greet"}})
+	if result.ExitCode != 0 || result.StdOut != "hello world" {
+		t.Errorf("Expected the greet applet to be selected by argv[0], got %+v", result)
+	}
+	result = <-sut.RunTask("default", url.Values{})
+	if result.ExitCode != 1 || result.StdErr != "unknown applet "+os.Args[0] {
+		t.Errorf("Expected argv[0] to default to the command path, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_Argv0_Busybox(t *testing.T) {
+	busybox, err := exec.LookPath("busybox")
+	if err != nil {
+		t.Skip("busybox is not installed")
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"echo": {
+			Name:      "echo",
+			Command:   busybox,
+			Argv0:     "echo",
+			Args:      []string{"from", "busybox"},
+			Reentrant: true,
+		},
+	}
+	testLog, _ := newTestLogger()
+	sut := NewGenericExecManager(taskConfigs, testLog, func(string) {})
+
+	if result := <-sut.RunTask("echo", url.Values{}); result.StdOut != "from busybox" {
+		t.Errorf("Expected busybox to run its echo applet, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_OutputWriter_BrokenPipe(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"lines": {
+			Name:      "lines",
+			Command:   "lines",
+			Args:      []string{"100"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	// The consumer reads a little of the output, then goes away.
+	pipeReader, pipeWriter := io.Pipe()
+	consumerGone := make(chan struct{})
+	go func() {
+		io.ReadFull(pipeReader, make([]byte, 10))
+		pipeReader.Close()
+		close(consumerGone)
+	}()
+
+	resultChan := sut.RunTaskWithOptions(context.Background(), "lines", url.Values{}, RunOptions{OutputWriter: pipeWriter})
+	select {
+	case result := <-resultChan:
+		<-consumerGone
+		if result.ExitCode != 0 || !strings.HasSuffix(result.StdOut, "line 100") {
+			t.Errorf("Expected the task to run to completion with all its output, got %+v", result)
+		}
+		if result.StreamError != io.ErrClosedPipe {
+			t.Errorf("Expected StreamError to report the closed pipe, got %v", result.StreamError)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the task to complete even though its output consumer went away")
+	}
+}
+
+func TestGenericExecManager_OutputWriter(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"lines": {
+			Name:      "lines",
+			Command:   "lines",
+			Args:      []string{"3"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	var streamed bytes.Buffer
+	result := <-sut.RunTaskWithOptions(context.Background(), "lines", url.Values{}, RunOptions{OutputWriter: &streamed})
+	if streamed.String() != "line 1\nline 2\nline 3\n" || result.StreamError != nil {
+		t.Errorf("Expected all output to be written to OutputWriter, got \"%s\" and %v", streamed.String(), result.StreamError)
+	}
+}
+
+func TestGenericExecManager_OutputFlushInterval(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"hang": {
+			Name:      "hang",
+			Command:   "hang",
+			Args:      []string{"so far"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	outputPath := filepath.Join(t.TempDir(), "output")
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outputFile.Close()
+	// Without flushing, the output would sit in the buffer until the task exits.
+	buffered := bufio.NewWriterSize(outputFile, 64*1024)
+
+	runContext, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resultChan := sut.RunTaskWithOptions(runContext, "hang", url.Values{}, RunOptions{
+		OutputWriter:        buffered,
+		OutputFlushInterval: 50 * time.Millisecond,
+	})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		content, _ := os.ReadFile(outputPath)
+		if strings.Contains(string(content), "so far") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected output to be flushed to the file while the task runs, got \"%s\"", content)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	select {
+	case result := <-resultChan:
+		t.Fatalf("Expected the task to still be running, got %+v", result)
+	default:
+	}
+
+	cancel()
+	if result := <-resultChan; result.StreamError != nil || buffered.Buffered() != 0 {
+		t.Errorf("Expected the output to be flushed without error when the task exits, got %v with %d bytes buffered", result.StreamError, buffered.Buffered())
+	}
+}
+
+func TestGenericExecManager_FlushOutputEachWrite(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"lines": {
+			Name:      "lines",
+			Command:   "lines",
+			Args:      []string{"3"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	var flushed bytes.Buffer
+	buffered := bufio.NewWriterSize(&flushed, 64*1024)
+	result := <-sut.RunTaskWithOptions(context.Background(), "lines", url.Values{}, RunOptions{OutputWriter: buffered, FlushOutputEachWrite: true})
+	if flushed.String() != "line 1\nline 2\nline 3\n" || result.StreamError != nil {
+		t.Errorf("Expected every write to be flushed through OutputWriter, got \"%s\" and %v", flushed.String(), result.StreamError)
+	}
+}
+
+func TestGenericExecManager_OmitEmptyArgs(t *testing.T) {
+	args := []string{"{{if request \"verbose\"}}--verbose{{end}}", "--name={{request \"name\"}}", "{{request \"target\"}}"}
+	taskConfigs := map[string]GenericExecConfig{
+		"omit": {
+			Name:          "omit",
+			Command:       "args",
+			Args:          args,
+			Reentrant:     true,
+			OmitEmptyArgs: true,
+		},
+		"keep": {
+			Name:      "keep",
+			Command:   "args",
+			Args:      args,
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	cases := []struct {
+		taskName string
+		values   url.Values
+		expect   string
+	}{
+		{"omit", url.Values{"verbose": []string{"1"}, "target": []string{"t"}}, `["--verbose" "--name=" "t"]`},
+		{"omit", url.Values{"target": []string{"t"}}, `["--name=" "t"]`},
+		{"omit", url.Values{}, `["--name="]`},
+		{"keep", url.Values{"target": []string{"t"}}, `["" "--name=" "t"]`},
+	}
+	for _, testCase := range cases {
+		if result := <-sut.RunTask(testCase.taskName, testCase.values); result.StdOut != testCase.expect {
+			t.Errorf("Expected task %s with %v to get args %s, got %s", testCase.taskName, testCase.values, testCase.expect, result.StdOut)
+		}
+	}
+}
+
+func TestGenericExecManager_EnqueueTimeout(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"{{request \"duration\"}}"},
+			Reentrant: false,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.EnqueueTimeout = 100 * time.Millisecond
+
+	blockingChan := sut.RunTask("slow", url.Values{"duration": []string{"2s"}})
+	time.Sleep(200 * time.Millisecond)
+	queuedChans := make([]<-chan GenericExecResult, cap(sut.mutexQueues["sleep"].runs))
+	for i := range queuedChans {
+		queuedChans[i] = sut.RunTask("slow", url.Values{"duration": []string{"0s"}})
+	}
+
+	start := time.Now()
+	result := <-sut.RunTask("slow", url.Values{"duration": []string{"0s"}})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected RunTask to give up on a full queue after EnqueueTimeout, took %v", elapsed)
+	}
+	if result.FailureKind != FailureKindEnqueueTimeout || result.ExitCode == 0 {
+		t.Errorf("Expected an enqueue timeout result, got %+v", result)
+	}
+
+	for _, resultChan := range append(queuedChans, blockingChan) {
+		if result := <-resultChan; result.FailureKind != FailureKindNone || result.ExitCode != 0 {
+			t.Errorf("Expected tasks that fit in the queue to run, got %+v", result)
+		}
+	}
+	if sut.IsCommandBusy("sleep") {
+		t.Error("Expected the rejected task not to be left tracked")
+	}
+}
+
+func TestGenericExecManager_FullQueueDoesNotBlockConfigReaders(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"{{request \"duration\"}}"},
+			Reentrant: false,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	blockingChan := sut.RunTask("slow", url.Values{"duration": []string{"2s"}})
+	time.Sleep(200 * time.Millisecond)
+	queuedChans := make([]<-chan GenericExecResult, cap(sut.mutexQueues["sleep"].runs))
+	for i := range queuedChans {
+		queuedChans[i] = sut.RunTask("slow", url.Values{"duration": []string{"0s"}})
+	}
+	overflowChan := make(chan (<-chan GenericExecResult), 1)
+	go func() {
+		overflowChan <- sut.RunTask("slow", url.Values{"duration": []string{"0s"}})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	replaced := make(chan error, 1)
+	go func() {
+		replaced <- sut.ReplaceConfigs(taskConfigs)
+	}()
+	configured := make(chan bool, 1)
+	go func() {
+		configured <- sut.IsTaskConfigured("slow")
+	}()
+	select {
+	case isConfigured := <-configured:
+		if !isConfigured {
+			t.Error("Expected task \"slow\" to still be configured")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected IsTaskConfigured not to wait for room in a full queue")
+	}
+	if err := <-replaced; err != nil {
+		t.Fatalf("Unexpected error replacing configs: %v", err)
+	}
+
+	for _, resultChan := range append(queuedChans, blockingChan, <-overflowChan) {
+		if result := <-resultChan; result.ExitCode != 0 {
+			t.Errorf("Expected every queued task to run, got %+v", result)
+		}
+	}
+}
+
+// Mock process exec bodies
+func TestHelperExecHandler(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	switch os.Args[3] {
+	case "fail":
+		// Echo the received arguments on StdErr and exit 2
+		fmt.Fprintf(os.Stderr, "%s", strings.Join(os.Args[4:], " "))
+		os.Exit(2)
+	case "sleep", "reentrant-sleep":
+		// Sleep for the duration given as the first argument, then echo the remaining arguments on StdOut and exit 0
+		duration, _ := time.ParseDuration(os.Args[4])
+		time.Sleep(duration)
+		fmt.Print(strings.Join(os.Args[5:], " "))
+		os.Exit(0)
+	case "hang":
+		// Print each argument on its own line on StdOut and on StdErr, then sleep until killed
+		for _, line := range os.Args[4:] {
+			fmt.Println(line)
+			fmt.Fprintln(os.Stderr, line)
+		}
+		select {}
+	case "exit":
+		// Echo all but the first argument on StdOut and exit with the code given as the first argument
+		code, _ := strconv.Atoi(os.Args[4])
+		fmt.Print(strings.Join(os.Args[5:], " "))
+		os.Exit(code)
+	case "multicall":
+		// Behave like a multi-call binary, choosing what to do from argv[0]
+		if filepath.Base(os.Args[0]) == "greet" {
+			fmt.Print("hello ", strings.Join(os.Args[4:], " "))
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "unknown applet %s", os.Args[0])
+		os.Exit(1)
+	case "lines":
+		// Print the number of lines given as the first argument, pausing briefly between them, and exit 0
+		count, _ := strconv.Atoi(os.Args[4])
+		for i := 1; i <= count; i++ {
+			fmt.Printf("line %d\n", i)
+			time.Sleep(time.Millisecond)
+		}
+		os.Exit(0)
+	case "args":
+		// Print the received arguments, quoted, on StdOut and exit 0
+		fmt.Printf("%q", os.Args[4:])
+		os.Exit(0)
+	case "timestamps":
+		// Sleep for the duration given as the first argument, then print when the sleep started and ended in Unix
+		// nanoseconds and exit 0
+		duration, _ := time.ParseDuration(os.Args[4])
+		start := time.Now()
+		time.Sleep(duration)
+		fmt.Printf("%d %d", start.UnixNano(), time.Now().UnixNano())
+		os.Exit(0)
+	case "upper":
+		// Echo StdIn on StdOut in upper case and exit 0
+		input, _ := io.ReadAll(os.Stdin)
+		fmt.Print(strings.ToUpper(string(input)))
+		os.Exit(0)
+	case "countstdin":
+		// Print how many bytes could be read from StdIn and exit 0
+		count, _ := io.Copy(io.Discard, os.Stdin)
+		fmt.Print(count)
+		os.Exit(0)
+	case "interfaces":
+		// Print the names of the network interfaces the process can see, one per line, and exit 0
+		interfaces, _ := net.Interfaces()
+		for _, iface := range interfaces {
+			fmt.Println(iface.Name)
+		}
+		os.Exit(0)
+	case "stderr":
+		// Echo all but the first argument on StdErr and exit with the code given as the first argument
+		code, _ := strconv.Atoi(os.Args[4])
+		fmt.Fprint(os.Stderr, strings.Join(os.Args[5:], " "))
+		os.Exit(code)
+	case "printenv":
+		// Print NAME=value for each environment variable named in the arguments and exit 0
+		for _, name := range os.Args[4:] {
+			fmt.Printf("%s=%s\n", name, os.Getenv(name))
+		}
+		os.Exit(0)
+	case "spin":
+		// Busy-loop until killed
+		for counter := 0; ; counter++ {
+		}
+	case "touch":
+		// Create the file named by the first argument
+		if err := os.WriteFile(os.Args[4], nil, 0o644); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "print0":
+		// Print each argument followed by a null byte, as find -print0 does, and exit 0
+		for _, arg := range os.Args[4:] {
+			fmt.Print(arg, "\x00")
+		}
+		os.Exit(0)
+	case "binary":
+		// Write every possible byte value on StdOut and exit 0
+		for b := 0; b < 256; b++ {
+			os.Stdout.Write([]byte{byte(b)})
+		}
+		os.Exit(0)
+	case "readfd3":
+		// Echo whatever can be read from file descriptor 3 on StdOut and exit 0
+		io.Copy(os.Stdout, os.NewFile(3, "fd3"))
+		os.Exit(0)
+	case "trapint":
+		// Wait for SIGINT, then clean up and exit 0. Give up and exit 1 after 10 seconds.
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		fmt.Println("ready")
+		select {
+		case <-interrupts:
+			fmt.Print("cleaned up")
+			os.Exit(0)
+		case <-time.After(10 * time.Second):
+			os.Exit(1)
+		}
+	case "orphan":
+		// Start a grandchild that sleeps for the duration given as the first argument while holding our StdOut open,
+		// then sleep for just as long ourselves.
+		grandchild := exec.Command(os.Args[0], "-test.run=TestHelperExecHandler", "--", "sleep", os.Args[4])
+		grandchild.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		grandchild.Stdout = os.Stdout
+		grandchild.Start()
+		duration, _ := time.ParseDuration(os.Args[4])
+		time.Sleep(duration)
+		os.Exit(0)
+	case "orphan-exit":
+		// Start a grandchild that sleeps for the duration given as the first argument while holding our StdOut open,
+		// and exit 0 right away.
+		grandchild := exec.Command(os.Args[0], "-test.run=TestHelperExecHandler", "--", "sleep", os.Args[4])
+		grandchild.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		grandchild.Stdout = os.Stdout
+		grandchild.Start()
+		fmt.Print("hi")
+		os.Exit(0)
+	}
+
+	// Echo the received arguments on StdOut and exit 0
+	fmt.Print(strings.Join(os.Args[4:], " "))
+	os.Exit(0)
+}
+
+func TestCommandForOS(t *testing.T) {
+	execConfig := GenericExecConfig{
+		Command:        "ls",
+		Args:           []string{"-l"},
+		WindowsCommand: "dir.exe",
+		WindowsArgs:    []string{"/w"},
+		OSCommands:     map[string]OSCommand{"darwin": {Command: "gls", Args: []string{"-l", "--color"}}, "plan9": {}},
+	}
+	for goos, expect := range map[string]string{"linux": "ls [-l]", "windows": "dir.exe [/w]", "darwin": "gls [-l --color]", "plan9": "ls [-l]"} {
+		command, args := commandForOS(execConfig, goos)
+		if actual := fmt.Sprintf("%s %v", command, args); actual != expect {
+			t.Errorf("Expected %s to run \"%s\", got \"%s\"", goos, expect, actual)
+		}
+	}
+	execConfig.OSCommands = map[string]OSCommand{"windows": {Command: "ls.exe"}}
+	if command, args := commandForOS(execConfig, "windows"); command != "ls.exe" || args != nil {
+		t.Errorf("Expected OSCommands to take precedence over WindowsCommand, got %s %v", command, args)
+	}
+
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:       "test",
+			Command:    "fail",
+			OSCommands: map[string]OSCommand{runtime.GOOS: {Command: "echo", Args: []string{"chosen"}}},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	if result := <-sut.RunTask("test", url.Values{}); result.StdOut != "chosen" {
+		t.Errorf("Expected the command for this OS to run, got %+v", result)
+	}
 }
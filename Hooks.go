@@ -0,0 +1,54 @@
+package genericexec
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultHookTimeout is how long a PostCommand, OnSuccessCommand or OnFailureCommand may run when its task has no
+// HookTimeout.
+const defaultHookTimeout = 30 * time.Second
+
+// withCompletedRun returns argValues for rendering the args of a hook following a run with result, so that the
+// hook's templates can use StdOut, StdErr and ExitCode.
+func withCompletedRun(argValues TemplateGetter, result *GenericExecResult) TemplateGetter {
+	wrapped, isWrapped := argValues.(*runGetter)
+	if !isWrapped {
+		return &runGetter{TemplateGetter: argValues, completedRun: result}
+	}
+	copied := *wrapped
+	copied.completedRun = result
+	return &copied
+}
+
+// runHook runs the task's OnSuccessCommand or OnFailureCommand, whichever applies to the completed run, if the task
+// has it, and records its outcome in result.
+func (ctx *GenericExecManager) runHook(runContext context.Context, run *taskRun, result *GenericExecResult) {
+	execConfig := run.execTaskConfig
+	command, args, outcome := execConfig.OnFailureCommand, execConfig.OnFailureArgs, &result.OnFailureCommand
+	if result.Succeeded {
+		command, args, outcome = execConfig.OnSuccessCommand, execConfig.OnSuccessArgs, &result.OnSuccessCommand
+	}
+	if command == "" {
+		return
+	}
+	cmd, err := ctx.prepareStep(command, args, execConfig, withCompletedRun(run.requestValues, result), run.requestEnv)
+	if err != nil {
+		*outcome = &CommandStepResult{ExitCode: 1, StdErr: fmt.Sprintf("Could not prepare the command: %v", err)}
+		return
+	}
+	hookContext, cancelHook := afterRunContext(runContext, execConfig)
+	defer cancelHook()
+	*outcome, _ = ctx.runStep(hookContext, cmd, execConfig)
+}
+
+// afterRunContext returns the context for a step that follows a run, a PostCommand or a hook. The run's context is
+// often why it failed, and is done by now, so the step gets one of its own, bounded by HookTimeout.
+func afterRunContext(runContext context.Context, execConfig *GenericExecConfig) (context.Context, context.CancelFunc) {
+	timeout := execConfig.HookTimeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	return context.WithTimeout(context.WithoutCancel(runContext), timeout)
+}
@@ -0,0 +1,68 @@
+package genericexec
+
+import (
+	"encoding/json"
+	"os/exec"
+
+	"github.com/acarl005/stripansi"
+)
+
+// jsonLogOutputBytes is how much of each output stream a JSON log entry includes.
+const jsonLogOutputBytes = 4096
+
+// CompletionLogEntry is what the manager logs, as one line of JSON, for each completed task run when LogJSON is set.
+// For Sensitive tasks, Command is replaced by a description and the output, parse error and notification are left
+// out. SecretKeys values are redacted throughout.
+type CompletionLogEntry struct {
+	Task            string      `json:"task"`
+	CorrelationID   string      `json:"correlation_id,omitempty"`
+	Command         string      `json:"command"`
+	ExitCode        int         `json:"exit_code"`
+	Succeeded       bool        `json:"succeeded"`
+	FailureKind     FailureKind `json:"failure_kind,omitempty"`
+	Reason          string      `json:"reason,omitempty"`
+	DurationSeconds float64     `json:"duration_seconds"`
+	StdOut          string      `json:"stdout,omitempty"`
+	StdErr          string      `json:"stderr,omitempty"`
+	OutputTruncated bool        `json:"output_truncated,omitempty"`
+	ParseError      string      `json:"parse_error,omitempty"`
+	Notification    string      `json:"notification,omitempty"`
+	// NotificationSuppressed is whether Notification was not sent because it repeated the last one.
+	NotificationSuppressed bool `json:"notification_suppressed,omitempty"`
+}
+
+// completionLogJSON returns the CompletionLogEntry for a completed run, serialized.
+func completionLogJSON(execConfig *GenericExecConfig, cmd *exec.Cmd, result GenericExecResult, successReason string, notificationMsg string, secrets []string) string {
+	entry := CompletionLogEntry{
+		Task:            execConfig.Name,
+		CorrelationID:   result.CorrelationID,
+		Command:         redact(cmdStringApproximation(cmd), secrets),
+		ExitCode:        result.ExitCode,
+		Succeeded:       result.Succeeded,
+		FailureKind:     result.FailureKind,
+		Reason:          successReason,
+		DurationSeconds: result.ExecTime.Seconds(),
+		OutputTruncated: result.OutputTruncated,
+
+		NotificationSuppressed: result.NotificationSuppressed,
+	}
+	if execConfig.Sensitive {
+		entry.Command = "sensitive task " + execConfig.Name
+	} else {
+		entry.Notification = stripansi.Strip(redact(notificationMsg, secrets))
+		// Redacted before it is cut, so that a secret straddling the cut doesn't leave its start behind.
+		entry.StdOut, entry.StdErr = stripansi.Strip(redact(result.StdOut, secrets)), stripansi.Strip(redact(result.StdErr, secrets))
+		if len(entry.StdOut) > jsonLogOutputBytes {
+			entry.StdOut, entry.OutputTruncated = cutAtRune(entry.StdOut, jsonLogOutputBytes), true
+		}
+		if len(entry.StdErr) > jsonLogOutputBytes {
+			entry.StdErr, entry.OutputTruncated = cutAtRune(entry.StdErr, jsonLogOutputBytes), true
+		}
+		if result.ParseError != nil {
+			entry.ParseError = redact(result.ParseError.Error(), secrets)
+		}
+	}
+	// Nothing in entry can fail to marshal.
+	serialized, _ := json.Marshal(entry)
+	return string(serialized)
+}
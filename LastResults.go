@@ -0,0 +1,137 @@
+package genericexec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"strings"
+)
+
+func (ctx *GenericExecManager) recordLastResult(result GenericExecResult) {
+	ctx.lastResultsMutex.Lock()
+	defer ctx.lastResultsMutex.Unlock()
+	if ctx.lastResults == nil {
+		ctx.lastResults = make(map[string]retainedResult)
+	}
+	ctx.lastResults[result.taskName] = ctx.retain(result)
+}
+
+// LastResult returns the result most recently delivered for the named task, as its caller received it, and whether
+// there is one. Results are only recorded while TrackLastResult is set, and only one is kept per task, whether the
+// task was run by its name or one of its Aliases.
+func (ctx *GenericExecManager) LastResult(taskName string) (GenericExecResult, bool) {
+	ctx.configMutex.RLock()
+	taskName = ctx.canonicalTaskName(taskName)
+	ctx.configMutex.RUnlock()
+
+	ctx.lastResultsMutex.Lock()
+	defer ctx.lastResultsMutex.Unlock()
+	retained, found := ctx.lastResults[taskName]
+	return retained.expand(), found
+}
+
+const defaultResultHistoryOutputBytes = 4096
+
+// recordHistory adds result to the ring buffer of recent results, overwriting the oldest once it is full.
+func (ctx *GenericExecManager) recordHistory(result GenericExecResult) {
+	outputLimit := ctx.ResultHistoryOutputBytes
+	if outputLimit <= 0 {
+		outputLimit = defaultResultHistoryOutputBytes
+	}
+	retained := ctx.retain(truncateResultOutput(result, outputLimit))
+
+	ctx.lastResultsMutex.Lock()
+	defer ctx.lastResultsMutex.Unlock()
+	if cap(ctx.resultHistory) != ctx.ResultHistorySize {
+		// The size was changed, so start over.
+		ctx.resultHistory = make([]retainedResult, 0, ctx.ResultHistorySize)
+		ctx.historyNext = 0
+	}
+	if len(ctx.resultHistory) < cap(ctx.resultHistory) {
+		ctx.resultHistory = append(ctx.resultHistory, retained)
+		return
+	}
+	ctx.resultHistory[ctx.historyNext] = retained
+	ctx.historyNext = (ctx.historyNext + 1) % len(ctx.resultHistory)
+}
+
+// RecentResults returns up to the n most recent results kept because of ResultHistorySize, oldest first.
+func (ctx *GenericExecManager) RecentResults(n int) []GenericExecResult {
+	ctx.lastResultsMutex.Lock()
+	defer ctx.lastResultsMutex.Unlock()
+	if n > len(ctx.resultHistory) {
+		n = len(ctx.resultHistory)
+	}
+	if n <= 0 {
+		return nil
+	}
+	recent := make([]GenericExecResult, 0, n)
+	// Once the buffer is full, historyNext is the oldest result.
+	for i := len(ctx.resultHistory) - n; i < len(ctx.resultHistory); i++ {
+		recent = append(recent, ctx.resultHistory[(ctx.historyNext+i)%len(ctx.resultHistory)].expand())
+	}
+	return recent
+}
+
+// truncateResultOutput returns result with its output cut off at limit bytes per stream, or a few less for StdOut and
+// StdErr so as not to split a character. What is kept is copied, so that the rest can be freed.
+func truncateResultOutput(result GenericExecResult, limit int) GenericExecResult {
+	if len(result.StdOutBytes) > limit {
+		result.StdOutBytes = append([]byte(nil), result.StdOutBytes[:limit]...)
+		result.OutputTruncated = true
+	}
+	if len(result.StdErrBytes) > limit {
+		result.StdErrBytes = append([]byte(nil), result.StdErrBytes[:limit]...)
+		result.OutputTruncated = true
+	}
+	if len(result.StdOut) > limit {
+		result.StdOut = strings.Clone(cutAtRune(result.StdOut, limit))
+		result.OutputTruncated = true
+	}
+	if len(result.StdErr) > limit {
+		result.StdErr = strings.Clone(cutAtRune(result.StdErr, limit))
+		result.OutputTruncated = true
+	}
+	return result
+}
+
+// retainedResult is a result kept for LastResult or RecentResults. With CompressRetainedOutput, its output is moved
+// out of result into compressedOutput.
+type retainedResult struct {
+	result           GenericExecResult
+	compressedOutput []byte
+}
+
+// retainedOutput is the part of a retainedResult that is compressed.
+type retainedOutput struct {
+	StdOut, StdErr           string
+	StdOutBytes, StdErrBytes []byte
+}
+
+func (ctx *GenericExecManager) retain(result GenericExecResult) retainedResult {
+	if !ctx.CompressRetainedOutput {
+		return retainedResult{result: result}
+	}
+	var compressed bytes.Buffer
+	zipper := gzip.NewWriter(&compressed)
+	output := retainedOutput{StdOut: result.StdOut, StdErr: result.StdErr, StdOutBytes: result.StdOutBytes, StdErrBytes: result.StdErrBytes}
+	if err := gob.NewEncoder(zipper).Encode(output); err != nil || zipper.Close() != nil {
+		return retainedResult{result: result}
+	}
+	result.StdOut, result.StdErr, result.StdOutBytes, result.StdErrBytes = "", "", nil, nil
+	return retainedResult{result: result, compressedOutput: compressed.Bytes()}
+}
+
+// expand returns the retained result with its output as it was before compression, if it was compressed.
+func (retained retainedResult) expand() GenericExecResult {
+	result := retained.result
+	if retained.compressedOutput == nil {
+		return result
+	}
+	var output retainedOutput
+	// The output was compressed by retain, so it can be read back.
+	unzipper, _ := gzip.NewReader(bytes.NewReader(retained.compressedOutput))
+	gob.NewDecoder(unzipper).Decode(&output)
+	result.StdOut, result.StdErr, result.StdOutBytes, result.StdErrBytes = output.StdOut, output.StdErr, output.StdOutBytes, output.StdErrBytes
+	return result
+}
@@ -0,0 +1,138 @@
+package genericexec
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_LastResult(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "echo",
+			Args:      []string{"{{request \"word\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	<-sut.RunTask("test", url.Values{"word": []string{"untracked"}})
+	if _, found := sut.LastResult("test"); found {
+		t.Error("Expected no last result without TrackLastResult")
+	}
+
+	sut.TrackLastResult = true
+	<-sut.RunTask("test", url.Values{"word": []string{"first"}})
+	<-sut.RunTask("test", url.Values{"word": []string{"second"}})
+	if result, found := sut.LastResult("test"); !found || result.StdOut != "second" {
+		t.Errorf("Expected the second run's result, got %+v", result)
+	}
+	if _, found := sut.LastResult("other"); found {
+		t.Error("Expected no last result for a task that hasn't run")
+	}
+}
+
+func TestGenericExecManager_LastResult_SharedName(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"first": {
+			Command:   "echo",
+			Args:      []string{"first"},
+			Reentrant: true,
+			Aliases:   []string{"primero"},
+		},
+		"second": {
+			Command:   "echo",
+			Args:      []string{"second"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.TrackLastResult = true
+	<-sut.RunTask("primero", url.Values{})
+	<-sut.RunTask("second", url.Values{})
+	if result, found := sut.LastResult("first"); !found || result.StdOut != "first" {
+		t.Errorf("Expected the first task's result, got %+v", result)
+	}
+	if result, found := sut.LastResult("primero"); !found || result.StdOut != "first" {
+		t.Errorf("Expected the first task's result by its alias, got %+v", result)
+	}
+	if result, found := sut.LastResult("second"); !found || result.StdOut != "second" {
+		t.Errorf("Expected the second task's result, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_RecentResults(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "echo",
+			Args:      []string{"{{request \"word\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.ResultHistorySize = 3
+	sut.ResultHistoryOutputBytes = 5
+	if recent := sut.RecentResults(3); len(recent) != 0 {
+		t.Errorf("Expected no results before any run, got %+v", recent)
+	}
+
+	for _, word := range []string{"one", "two", "three", "four", "five"} {
+		<-sut.RunTask("test", url.Values{"word": []string{word}})
+	}
+	var words []string
+	for _, result := range sut.RecentResults(10) {
+		words = append(words, result.StdOut)
+	}
+	if expect := "three four five"; strings.Join(words, " ") != expect {
+		t.Errorf("Expected the last 3 results oldest first, \"%s\", got %q", expect, words)
+	}
+	if recent := sut.RecentResults(1); len(recent) != 1 || recent[0].StdOut != "five" {
+		t.Errorf("Expected only the most recent result, got %+v", recent)
+	}
+
+	<-sut.RunTask("test", url.Values{"word": []string{"truncated"}})
+	recent := sut.RecentResults(1)[0]
+	if recent.StdOut != "trunc" || string(recent.StdOutBytes) != "trunc" || !recent.OutputTruncated {
+		t.Errorf("Expected the kept output cut off at ResultHistoryOutputBytes, got %+v", recent)
+	}
+
+	<-sut.RunTask("test", url.Values{"word": []string{"abcdé"}})
+	if recent := sut.RecentResults(1)[0]; recent.StdOut != "abcd" {
+		t.Errorf("Expected the kept output cut off before the character that would be split, got %q", recent.StdOut)
+	}
+}
+
+func TestGenericExecManager_CompressRetainedOutput(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "lines",
+			Args:      []string{"300"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.TrackLastResult = true
+	sut.ResultHistorySize = 2
+	sut.ResultHistoryOutputBytes = 1 << 20
+	sut.CompressRetainedOutput = true
+
+	delivered := <-sut.RunTask("test", url.Values{})
+	if len(delivered.StdOutBytes) < 2000 {
+		t.Fatalf("Expected plenty of output, got %d bytes", len(delivered.StdOutBytes))
+	}
+	stored := sut.lastResults["test"]
+	if stored.result.StdOut != "" || len(stored.compressedOutput) == 0 || len(stored.compressedOutput) >= len(delivered.StdOutBytes) {
+		t.Errorf("Expected the retained output to be stored compressed, got %d compressed bytes for %d", len(stored.compressedOutput), len(delivered.StdOutBytes))
+	}
+
+	last, _ := sut.LastResult("test")
+	recent := sut.RecentResults(1)[0]
+	for _, retained := range []GenericExecResult{last, recent} {
+		if retained.StdOut != delivered.StdOut || !bytes.Equal(retained.StdOutBytes, delivered.StdOutBytes) || retained.StdErr != delivered.StdErr || retained.ExitCode != delivered.ExitCode {
+			t.Errorf("Expected the retained result to decompress to what was delivered, got %+v", retained)
+		}
+	}
+}
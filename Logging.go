@@ -0,0 +1,46 @@
+package genericexec
+
+// LogLevel is the severity of a message the manager logs.
+type LogLevel int
+
+const (
+	LogLevelInfo LogLevel = iota
+	LogLevelWarn
+	LogLevelError
+)
+
+func (level LogLevel) String() string {
+	switch level {
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// LeveledLogger is implemented by loggers that can record a severity with each message, for
+// GenericExecManager.LeveledLogger.
+type LeveledLogger interface {
+	Logf(level LogLevel, format string, args ...interface{})
+}
+
+func (ctx *GenericExecManager) logf(level LogLevel, format string, args ...interface{}) {
+	if ctx.LeveledLogger != nil {
+		ctx.LeveledLogger.Logf(level, format, args...)
+		return
+	}
+	ctx.log.Printf(format, args...)
+}
+
+// logLevelFor returns the severity to log the completion of a run with.
+func (ctx *GenericExecManager) logLevelFor(result GenericExecResult) LogLevel {
+	if ctx.ExitCodeLogLevel != nil {
+		return ctx.ExitCodeLogLevel(result.ExitCode)
+	}
+	if result.Succeeded {
+		return LogLevelInfo
+	}
+	return LogLevelError
+}
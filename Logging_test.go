@@ -0,0 +1,152 @@
+package genericexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type capturedLog struct {
+	level   LogLevel
+	message string
+}
+
+type capturingLeveledLogger struct {
+	mutex   sync.Mutex
+	entries []capturedLog
+}
+
+func (logger *capturingLeveledLogger) Logf(level LogLevel, format string, args ...interface{}) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	logger.entries = append(logger.entries, capturedLog{level: level, message: fmt.Sprintf(format, args...)})
+}
+
+func (logger *capturingLeveledLogger) last() capturedLog {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	return logger.entries[len(logger.entries)-1]
+}
+
+func TestGenericExecManager_LeveledLogger(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"exit": {
+			Name:      "exit",
+			Command:   "exit",
+			Args:      []string{"{{request \"code\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+	logger := &capturingLeveledLogger{}
+	sut.LeveledLogger = logger
+
+	for code, level := range map[string]LogLevel{"0": LogLevelInfo, "1": LogLevelError} {
+		<-sut.RunTask("exit", url.Values{"code": []string{code}})
+		if entry := logger.last(); entry.level != level || !strings.Contains(entry.message, "exited "+code) {
+			t.Errorf("Expected exit %s to be logged at %v by default, got %v: \"%s\"", code, level, entry.level, entry.message)
+		}
+	}
+
+	sut.ExitCodeLogLevel = func(exitCode int) LogLevel {
+		switch {
+		case exitCode == 0:
+			return LogLevelInfo
+		case exitCode == 1:
+			return LogLevelWarn
+		default:
+			return LogLevelError
+		}
+	}
+	for code, level := range map[string]LogLevel{"0": LogLevelInfo, "1": LogLevelWarn, "2": LogLevelError, "7": LogLevelError} {
+		<-sut.RunTask("exit", url.Values{"code": []string{code}})
+		if entry := logger.last(); entry.level != level || !strings.Contains(entry.message, "exited "+code) {
+			t.Errorf("Expected exit %s to be logged at %v, got %v: \"%s\"", code, level, entry.level, entry.message)
+		}
+	}
+
+	if testLogBuf.Len() != 0 {
+		t.Errorf("Expected nothing logged to the plain logger when a LeveledLogger is set, got \"%s\"", testLogBuf.String())
+	}
+}
+
+func TestGenericExecManager_LogJSON(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"exit": {
+			Name:           "exit",
+			Command:        "exit",
+			Args:           []string{"{{request \"code\"}}", "{{request \"msg\"}}"},
+			SuccessMessage: "done: {{StdOut}}",
+			Reentrant:      true,
+			SecretKeys:     []string{"msg"},
+		},
+		"secret": {
+			Name:      "secret",
+			Command:   "exit",
+			Args:      []string{"1", "hidden"},
+			Reentrant: true,
+			Sensitive: true,
+		},
+		"padded": {
+			Name:       "padded",
+			Command:    "exit",
+			Args:       []string{"0", "{{request \"pad\"}}{{request \"msg\"}}"},
+			Reentrant:  true,
+			SecretKeys: []string{"msg"},
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+	logger := &capturingLeveledLogger{}
+	sut.LeveledLogger = logger
+	sut.LogJSON = true
+
+	<-sut.RunTask("exit", url.Values{"code": []string{"0"}, "msg": []string{"s3cret"}})
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(logger.last().message), &entry); err != nil {
+		t.Fatalf("Expected the log entry to be valid JSON, got %v: \"%s\"", err, logger.last().message)
+	}
+	for field, expect := range map[string]interface{}{
+		"task":         "exit",
+		"command":      "exit 0 [REDACTED]",
+		"exit_code":    float64(0),
+		"succeeded":    true,
+		"stdout":       "[REDACTED]",
+		"notification": "done: [REDACTED]",
+	} {
+		if entry[field] != expect {
+			t.Errorf("Expected %s to be %v in the log entry, got %v", field, expect, entry[field])
+		}
+	}
+	if _, hasDuration := entry["duration_seconds"].(float64); !hasDuration {
+		t.Errorf("Expected a duration_seconds in the log entry, got %v", entry)
+	}
+	if strings.Contains(logger.last().message, "\n") {
+		t.Errorf("Expected the log entry on one line, got \"%s\"", logger.last().message)
+	}
+
+	// A secret straddling the cut at jsonLogOutputBytes is still redacted.
+	<-sut.RunTask("padded", url.Values{"pad": []string{strings.Repeat("x", jsonLogOutputBytes-3)}, "msg": []string{"s3cret"}})
+	var padded CompletionLogEntry
+	if err := json.Unmarshal([]byte(logger.last().message), &padded); err != nil {
+		t.Fatalf("Expected the log entry to be valid JSON, got %v", err)
+	}
+	if !padded.OutputTruncated || strings.Contains(padded.StdOut, "s3c") {
+		t.Errorf("Expected the cut output without any of the secret, got \"...%s\"", padded.StdOut[len(padded.StdOut)-10:])
+	}
+
+	<-sut.RunTask("secret", url.Values{})
+	var sensitive CompletionLogEntry
+	if err := json.Unmarshal([]byte(logger.last().message), &sensitive); err != nil {
+		t.Fatalf("Expected the log entry to be valid JSON, got %v", err)
+	}
+	if sensitive.Succeeded || sensitive.ExitCode != 1 || strings.Contains(logger.last().message, "hidden") {
+		t.Errorf("Expected a failed sensitive run to be logged without its output or arguments, got \"%s\"", logger.last().message)
+	}
+
+	if testLogBuf.Len() != 0 {
+		t.Errorf("Expected nothing logged to the plain logger when a LeveledLogger is set, got \"%s\"", testLogBuf.String())
+	}
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package genericexec
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+var namespaceCloneFlags = map[string]uintptr{
+	"mount":   syscall.CLONE_NEWNS,
+	"network": syscall.CLONE_NEWNET,
+	"pid":     syscall.CLONE_NEWPID,
+	"uts":     syscall.CLONE_NEWUTS,
+	"ipc":     syscall.CLONE_NEWIPC,
+	"user":    syscall.CLONE_NEWUSER,
+}
+
+func validateNamespaces(namespaces []string) error {
+	for _, namespace := range namespaces {
+		if _, known := namespaceCloneFlags[namespace]; !known {
+			return fmt.Errorf("unknown namespace \"%s\"", namespace)
+		}
+	}
+	return nil
+}
+
+// applyNamespaces makes cmd start in new namespaces of the given kinds.
+func applyNamespaces(cmd *exec.Cmd, namespaces []string) error {
+	if err := validateNamespaces(namespaces); err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	for _, namespace := range namespaces {
+		cmd.SysProcAttr.Cloneflags |= namespaceCloneFlags[namespace]
+	}
+	return nil
+}
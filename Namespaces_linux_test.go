@@ -0,0 +1,57 @@
+//go:build linux
+
+package genericexec
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_Namespaces(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Creating a network namespace requires root")
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"isolated": {
+			Name:       "isolated",
+			Command:    "interfaces",
+			Namespaces: []string{"network"},
+			Reentrant:  true,
+		},
+		"host": {
+			Name:      "host",
+			Command:   "interfaces",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("isolated", url.Values{})
+	if strings.Contains(result.StdErr, "operation not permitted") {
+		t.Skipf("Not permitted to create a network namespace here: %s", result.StdErr)
+	}
+	if result.ExitCode != 0 || result.StdOut != "lo" {
+		t.Errorf("Expected only the loopback interface in a new network namespace, got %+v", result)
+	}
+
+	hostInterfaces, err := net.Interfaces()
+	if err != nil || len(hostInterfaces) < 2 {
+		t.Skip("The host has too few network interfaces to tell isolation from none")
+	}
+	result = <-sut.RunTask("host", url.Values{})
+	if strings.Count(result.StdOut, "\n") == 0 {
+		t.Errorf("Expected a task without Namespaces to see the host's interfaces, got \"%s\"", result.StdOut)
+	}
+}
+
+func TestValidateConfigs_Namespaces(t *testing.T) {
+	err := ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", Namespaces: []string{"network", "time-travel"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown namespace \"time-travel\"") {
+		t.Errorf("Expected an unknown namespace to fail validation, got %v", err)
+	}
+}
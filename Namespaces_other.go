@@ -0,0 +1,21 @@
+//go:build !linux
+
+package genericexec
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errNamespacesUnsupported = errors.New("namespaces are only supported on Linux")
+
+func validateNamespaces(namespaces []string) error {
+	if len(namespaces) > 0 {
+		return errNamespacesUnsupported
+	}
+	return nil
+}
+
+func applyNamespaces(cmd *exec.Cmd, namespaces []string) error {
+	return validateNamespaces(namespaces)
+}
@@ -0,0 +1,46 @@
+package genericexec
+
+// observeQueue holds calls to observer callbacks waiting to be made, when ObserverQueueSize is set.
+type observeQueue struct {
+	pending  []func()
+	draining bool
+}
+
+// observe makes call to an observer callback: right away by default, or with ObserverQueueSize, from a separate
+// goroutine that makes calls one at a time in the order they were queued. A call that finds the queue full is
+// dropped, and observe returns false.
+func (ctx *GenericExecManager) observe(call func()) bool {
+	if ctx.ObserverQueueSize <= 0 {
+		call()
+		return true
+	}
+	ctx.observeMutex.Lock()
+	defer ctx.observeMutex.Unlock()
+	if len(ctx.observeQueue.pending) >= ctx.ObserverQueueSize {
+		ctx.stats.droppedCallbacks.Add(1)
+		return false
+	}
+	ctx.observeQueue.pending = append(ctx.observeQueue.pending, call)
+	if !ctx.observeQueue.draining {
+		ctx.observeQueue.draining = true
+		go ctx.drainObserveQueue()
+	}
+	return true
+}
+
+// drainObserveQueue makes queued calls until there are none left. There is never more than one draining at once.
+func (ctx *GenericExecManager) drainObserveQueue() {
+	for {
+		ctx.observeMutex.Lock()
+		if len(ctx.observeQueue.pending) == 0 {
+			ctx.observeQueue.draining = false
+			ctx.observeMutex.Unlock()
+			return
+		}
+		call := ctx.observeQueue.pending[0]
+		ctx.observeQueue.pending[0] = nil
+		ctx.observeQueue.pending = ctx.observeQueue.pending[1:]
+		ctx.observeMutex.Unlock()
+		call()
+	}
+}
@@ -0,0 +1,94 @@
+package genericexec
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_ObserverQueueSize(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "echo", Reentrant: true},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.ObserverQueueSize = 2
+	var mutex sync.Mutex
+	observed := 0
+	release := make(chan struct{})
+	sut.OnResult = func(result GenericExecResult) {
+		<-release
+		mutex.Lock()
+		defer mutex.Unlock()
+		observed++
+	}
+
+	// OnResult is stuck until release is closed, so the runs can only complete if they don't wait for it.
+	completed := make(chan struct{})
+	go func() {
+		defer close(completed)
+		for i := 0; i < 5; i++ {
+			<-sut.RunTask("test", url.Values{})
+		}
+	}()
+	select {
+	case <-completed:
+	case <-time.After(20 * time.Second):
+		t.Fatal("Expected a stuck OnResult not to hold up tasks")
+	}
+	// One call is being made and two are queued.
+	dropped := sut.Stats().DroppedCallbacks
+	if dropped < 2 {
+		t.Errorf("Expected OnResult calls beyond the queue size to be dropped, but %d were", dropped)
+	}
+
+	// The calls that were queued are still made.
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		mutex.Lock()
+		done := uint64(observed)+dropped == 5
+		mutex.Unlock()
+		if done {
+			return
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	t.Errorf("Expected every OnResult call to be made or dropped, got %d made and %d dropped", observed, dropped)
+}
+
+func TestGenericExecManager_ObserverQueueSize_DroppedOutputCallbacks(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"lines": {Name: "lines", Command: "lines", Args: []string{"10"}, Reentrant: true},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.ObserverQueueSize = 2
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	observed := 0
+	onOutputLine := func(stream OutputStream, line string) {
+		<-release
+		mutex.Lock()
+		defer mutex.Unlock()
+		observed++
+	}
+
+	result := <-sut.RunTaskWithOptions(context.Background(), "lines", url.Values{}, RunOptions{OnOutputLine: onOutputLine})
+	close(release)
+	// At most one call is being made and two are queued; the rest were dropped, and the result says so.
+	if result.DroppedOutputCallbacks < 7 {
+		t.Errorf("Expected the result to count the dropped OnOutputLine calls, got %d", result.DroppedOutputCallbacks)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		mutex.Lock()
+		done := int64(observed)+result.DroppedOutputCallbacks == 10
+		mutex.Unlock()
+		if done {
+			return
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	t.Errorf("Expected every OnOutputLine call to be made or counted as dropped, got %d made and %d dropped", observed, result.DroppedOutputCallbacks)
+}
@@ -0,0 +1,205 @@
+package genericexec
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// outputBuffer holds a task's output for its result, keeping no more than its limit and what the manager's
+// OutputMemoryBudget allows, and when tailLines is positive, only that many of the most recent lines.
+// Anything else written to it is discarded: past the limit, that is the newest output unless keepTail or tailLines
+// is set, and then it is the oldest. Like bytes.Buffer, it is not safe for
+// concurrent writes; each of a task's streams has its own.
+type outputBuffer struct {
+	// buffer is not embedded, so that its ReadFrom can't be used to bypass the limits.
+	buffer    bytes.Buffer
+	manager   *GenericExecManager
+	limit     int
+	tailLines int
+	keepTail  bool
+	reserved  int64
+	truncated bool
+	// droppedBytes and droppedLines count the output discarded, and the newlines in it.
+	droppedBytes int
+	droppedLines int
+}
+
+func (ctx *GenericExecManager) newOutputBuffer(limit int, tailLines int, keepTail bool) *outputBuffer {
+	return &outputBuffer{manager: ctx, limit: limit, tailLines: tailLines, keepTail: keepTail || tailLines > 0}
+}
+
+func (buffer *outputBuffer) Write(p []byte) (int, error) {
+	keep := len(p)
+	if !buffer.keepTail && buffer.limit > 0 && buffer.buffer.Len()+keep > buffer.limit {
+		keep = buffer.limit - buffer.buffer.Len()
+	}
+	if buffer.manager.OutputMemoryBudget > 0 {
+		granted := int(buffer.manager.reserveOutput(int64(keep)))
+		buffer.reserved += int64(granted)
+		if buffer.keepTail && granted < keep {
+			// The oldest output held gives up its share of the budget to the newest.
+			reuse := keep - granted
+			if reuse > buffer.buffer.Len() {
+				reuse = buffer.buffer.Len()
+			}
+			buffer.drop(reuse)
+			granted += reuse
+		}
+		keep = granted
+	}
+	// What doesn't fit in the budget is the oldest of p, when the most recent output is kept.
+	kept, dropped := p[:keep], p[keep:]
+	if buffer.keepTail {
+		kept, dropped = p[len(p)-keep:], p[:len(p)-keep]
+	}
+	buffer.buffer.Write(kept)
+	if keep < len(p) {
+		buffer.truncated = true
+		buffer.droppedBytes += len(dropped)
+		buffer.droppedLines += bytes.Count(dropped, []byte{'\n'})
+	}
+	if buffer.tailLines > 0 {
+		buffer.dropOldLines()
+	}
+	// Keep the most recent output within the limit.
+	if buffer.keepTail && buffer.limit > 0 && buffer.buffer.Len() > buffer.limit {
+		buffer.discard(buffer.buffer.Len() - buffer.limit)
+	}
+	return len(p), nil
+}
+
+// dropOldLines discards lines from the start of the buffer until it holds no more than tailLines lines, counting
+// a final line without a newline.
+func (buffer *outputBuffer) dropOldLines() {
+	data := buffer.buffer.Bytes()
+	lines := bytes.Count(data, []byte{'\n'})
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		lines++
+	}
+	drop := 0
+	for ; lines > buffer.tailLines; lines-- {
+		drop += bytes.IndexByte(data[drop:], '\n') + 1
+	}
+	buffer.discard(drop)
+}
+
+// discard drops n bytes from the start of the buffer, returning them to the OutputMemoryBudget.
+func (buffer *outputBuffer) discard(n int) {
+	if n <= 0 {
+		return
+	}
+	buffer.drop(n)
+	if buffer.manager.OutputMemoryBudget > 0 {
+		buffer.manager.releaseOutput(int64(n))
+		buffer.reserved -= int64(n)
+	}
+}
+
+// drop drops n bytes from the start of the buffer, keeping their share of the OutputMemoryBudget.
+func (buffer *outputBuffer) drop(n int) {
+	if n <= 0 {
+		return
+	}
+	buffer.droppedLines += bytes.Count(buffer.buffer.Next(n), []byte{'\n'})
+	buffer.droppedBytes += n
+	buffer.truncated = true
+}
+
+func (buffer *outputBuffer) Bytes() []byte {
+	return buffer.buffer.Bytes()
+}
+
+// marked returns output, the text of the output kept, with marker, if it isn't empty and anything was discarded,
+// where the discarded output would have been: after output, or before it when the oldest output was discarded.
+// ${bytes} and ${lines} in marker are replaced with how many bytes were discarded and how many newlines they had.
+func (buffer *outputBuffer) marked(output string, marker string) string {
+	if marker == "" || !buffer.truncated {
+		return output
+	}
+	rendered, err := renderPlaceholders(marker, MapGetter{"bytes": strconv.Itoa(buffer.droppedBytes), "lines": strconv.Itoa(buffer.droppedLines)})
+	if err != nil {
+		rendered = marker
+	}
+	if buffer.keepTail {
+		return rendered + output
+	}
+	return output + rendered
+}
+
+// release returns the buffer's share of the OutputMemoryBudget once its task has completed.
+func (buffer *outputBuffer) release() {
+	buffer.manager.releaseOutput(buffer.reserved)
+	buffer.reserved = 0
+}
+
+// reserveOutput claims up to n bytes of the OutputMemoryBudget and returns how many were granted.
+func (ctx *GenericExecManager) reserveOutput(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	ctx.outputMutex.Lock()
+	defer ctx.outputMutex.Unlock()
+	if available := ctx.OutputMemoryBudget - ctx.outputBytesInUse; n > available {
+		n = available
+		if n < 0 {
+			n = 0
+		}
+	}
+	ctx.outputBytesInUse += n
+	if ctx.outputBytesInUse > ctx.outputBytesHighest {
+		ctx.outputBytesHighest = ctx.outputBytesInUse
+	}
+	return n
+}
+
+func (ctx *GenericExecManager) releaseOutput(n int64) {
+	if n == 0 {
+		return
+	}
+	ctx.outputMutex.Lock()
+	defer ctx.outputMutex.Unlock()
+	ctx.outputBytesInUse -= n
+}
+
+// outputGuard counts everything a task writes to stdout and stderr together, and calls stop once the total exceeds
+// limit. Both of a task's streams write to the same outputGuard.
+type outputGuard struct {
+	limit   int64
+	stop    func()
+	mutex   sync.Mutex
+	written int64
+	tripped bool
+}
+
+func (guard *outputGuard) Write(p []byte) (int, error) {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	guard.written += int64(len(p))
+	if guard.written > guard.limit && !guard.tripped {
+		guard.tripped = true
+		guard.stop()
+	}
+	return len(p), nil
+}
+
+func (guard *outputGuard) hasTripped() bool {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	return guard.tripped
+}
+
+// cutAtRune returns the first n bytes of s, or a few less so as not to split a UTF-8 encoded character. Bytes that
+// aren't UTF-8, such as binary or Latin-1 input, are kept as they are.
+func cutAtRune(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for cut := n; cut > 0 && cut > n-utf8.UTFMax; cut-- {
+		if utf8.RuneStart(s[cut]) {
+			return s[:cut]
+		}
+	}
+	return s[:n]
+}
@@ -0,0 +1,217 @@
+package genericexec
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_MaxOutputBytes(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "lines",
+			Args:           []string{"20"},
+			MaxOutputBytes: 30,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{})
+	if result.ExitCode != 0 {
+		t.Errorf("Expected a truncated task to run to completion, got exit code %d", result.ExitCode)
+	}
+	if len(result.StdOutBytes) != 30 || !result.OutputTruncated {
+		t.Errorf("Expected 30 bytes of truncated output, got %d bytes, OutputTruncated %v", len(result.StdOutBytes), result.OutputTruncated)
+	}
+	if result.StdOut != "line 1\nline 2\nline 3\nline 4\nli" {
+		t.Errorf("Expected the first 30 bytes of output to be kept, got \"%s\"", result.StdOut)
+	}
+}
+
+func TestGenericExecManager_TruncationMarker(t *testing.T) {
+	marker := "[${bytes} bytes, ${lines} newlines cut]"
+	taskConfigs := map[string]GenericExecConfig{
+		"head": {
+			Name:             "head",
+			Command:          "lines",
+			Args:             []string{"20"},
+			MaxOutputBytes:   30,
+			TruncationMarker: marker,
+			Reentrant:        true,
+		},
+		"tail": {
+			Name:             "tail",
+			Command:          "lines",
+			Args:             []string{"20"},
+			MaxOutputBytes:   30,
+			TruncateFrom:     "head",
+			TruncationMarker: marker,
+			Reentrant:        true,
+		},
+		"lines": {
+			Name:             "lines",
+			Command:          "lines",
+			Args:             []string{"20"},
+			TailLines:        3,
+			TruncationMarker: marker,
+			Reentrant:        true,
+		},
+		"untruncated": {
+			Name:             "untruncated",
+			Command:          "lines",
+			Args:             []string{"2"},
+			MaxOutputBytes:   30,
+			TruncationMarker: marker,
+			Reentrant:        true,
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Fatalf("Expected truncation settings to be valid, got %v", err)
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	// The 20 lines are 151 bytes with 20 newlines.
+	for taskName, expect := range map[string]string{
+		"head":        "line 1\nline 2\nline 3\nline 4\nli[121 bytes, 16 newlines cut]",
+		"tail":        "[121 bytes, 16 newlines cut]ne 17\nline 18\nline 19\nline 20",
+		"lines":       "[127 bytes, 17 newlines cut]line 18\nline 19\nline 20",
+		"untruncated": "line 1\nline 2",
+	} {
+		result := <-sut.RunTask(taskName, url.Values{})
+		if result.StdOut != expect {
+			t.Errorf("Expected %s to keep %q, got %q", taskName, expect, result.StdOut)
+		}
+		if strings.Contains(string(result.StdOutBytes), "cut]") {
+			t.Errorf("Expected the marker to be left out of %s's StdOutBytes, got %q", taskName, result.StdOutBytes)
+		}
+	}
+
+	taskConfigs["head"] = GenericExecConfig{Name: "head", Command: "lines", TruncateFrom: "middle"}
+	if err := ValidateConfigs(taskConfigs); err == nil {
+		t.Error("Expected an unknown TruncateFrom to be invalid")
+	}
+}
+
+func TestGenericExecManager_OutputMemoryBudget(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "lines",
+			Args:      []string{"200"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	// Each run writes 1692 bytes; together, these would use far more than the budget.
+	const runs = 10
+	sut.OutputMemoryBudget = 4000
+
+	var wg sync.WaitGroup
+	results := make([]GenericExecResult, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = <-sut.RunTask("test", url.Values{})
+		}(i)
+	}
+	wg.Wait()
+
+	var total, truncated int
+	for _, result := range results {
+		total += len(result.StdOutBytes)
+		if result.OutputTruncated {
+			truncated++
+		}
+	}
+	if total > 4000 && truncated == 0 {
+		t.Errorf("Expected some output to be truncated")
+	}
+
+	sut.outputMutex.Lock()
+	defer sut.outputMutex.Unlock()
+	if sut.outputBytesHighest > sut.OutputMemoryBudget {
+		t.Errorf("Expected no more than %d bytes of output buffered at once, got %d", sut.OutputMemoryBudget, sut.outputBytesHighest)
+	}
+	if sut.outputBytesHighest == 0 {
+		t.Errorf("Expected output to be counted against the budget")
+	}
+	if sut.outputBytesInUse != 0 {
+		t.Errorf("Expected completed runs to free their share of the budget, but %d bytes are still counted", sut.outputBytesInUse)
+	}
+}
+
+func TestGenericExecManager_TailLines(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "lines",
+			Args:      []string{"100"},
+			TailLines: 10,
+		},
+		"capped": {
+			Name:           "capped",
+			Command:        "lines",
+			Args:           []string{"100"},
+			TailLines:      10,
+			MaxOutputBytes: 20,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{})
+	expect := "line 91\nline 92\nline 93\nline 94\nline 95\nline 96\nline 97\nline 98\nline 99\nline 100"
+	if result.StdOut != expect || !result.OutputTruncated {
+		t.Errorf("Expected the last 10 lines, truncated, got \"%s\", OutputTruncated %v", result.StdOut, result.OutputTruncated)
+	}
+
+	result = <-sut.RunTask("capped", url.Values{})
+	if result.StdOut != "98\nline 99\nline 100" {
+		t.Errorf("Expected the last 20 bytes of the last 10 lines, got \"%s\"", result.StdOut)
+	}
+
+	// The budget grants only part of the output, which must then be its end.
+	sut.OutputMemoryBudget = 30
+	result = <-sut.RunTask("test", url.Values{})
+	if !strings.HasSuffix(result.StdOut, "\nline 99\nline 100") || len(result.StdOut) > 30 || !result.OutputTruncated {
+		t.Errorf("Expected no more than the last 30 bytes of output, truncated, got \"%s\", OutputTruncated %v", result.StdOut, result.OutputTruncated)
+	}
+}
+
+func TestGenericExecManager_FailOnOutputBytes(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:              "test",
+			Command:           "lines",
+			Args:              []string{"5000"},
+			FailOnOutputBytes: 100,
+		},
+		"quiet": {
+			Name:              "quiet",
+			Command:           "lines",
+			Args:              []string{"3"},
+			FailOnOutputBytes: 100,
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{})
+	if result.Succeeded || result.FailureKind != FailureKindOutputLimit {
+		t.Errorf("Expected a task writing too much to fail with FailureKind %s, got %+v", FailureKindOutputLimit, result)
+	}
+	// Printing 5000 lines takes at least 5s, so finishing well before then means the process was killed.
+	if result.ExecTime > 3*time.Second {
+		t.Errorf("Expected the task to be stopped soon after exceeding its limit, but it ran for %v", result.ExecTime)
+	}
+	if !strings.Contains(testLogBuf.String(), "because it was stopped for writing more than 100 bytes!") {
+		t.Errorf("Expected the log to say why the task failed, got \"%s\"", testLogBuf.String())
+	}
+
+	result = <-sut.RunTask("quiet", url.Values{})
+	if !result.Succeeded || result.FailureKind != FailureKindNone {
+		t.Errorf("Expected a task writing less than its limit to succeed, got %+v", result)
+	}
+}
@@ -0,0 +1,177 @@
+package genericexec
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// OutputStream identifies which of a task's output streams a line was written to.
+type OutputStream int
+
+const (
+	StdOutStream OutputStream = iota
+	StdErrStream
+)
+
+func (stream OutputStream) String() string {
+	if stream == StdErrStream {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// lineWriter passes each complete line written to it, without its delimiter, to onLine. Any final partial line is
+// passed on by flush. Writers sharing a mutex never call onLine concurrently.
+type lineWriter struct {
+	stream    OutputStream
+	onLine    func(stream OutputStream, line string)
+	mutex     *sync.Mutex
+	delimiter byte
+	partial   []byte
+}
+
+func newLineWriters(onLine func(stream OutputStream, line string), delimiter byte) (stdout *lineWriter, stderr *lineWriter) {
+	mutex := &sync.Mutex{}
+	return &lineWriter{stream: StdOutStream, onLine: onLine, mutex: mutex, delimiter: delimiter},
+		&lineWriter{stream: StdErrStream, onLine: onLine, mutex: mutex, delimiter: delimiter}
+}
+
+// lineDelimiter returns the byte a task's output lines end with: the task's LineDelimiter, or a newline.
+func lineDelimiter(execConfig *GenericExecConfig) byte {
+	if execConfig.LineDelimiter == "" {
+		return '\n'
+	}
+	return execConfig.LineDelimiter[0]
+}
+
+func (writer *lineWriter) Write(p []byte) (int, error) {
+	writer.partial = append(writer.partial, p...)
+	for {
+		end := bytes.IndexByte(writer.partial, writer.delimiter)
+		if end < 0 {
+			break
+		}
+		writer.emit(string(writer.partial[:end]))
+		writer.partial = writer.partial[end+1:]
+	}
+	return len(p), nil
+}
+
+func (writer *lineWriter) flush() {
+	if len(writer.partial) > 0 {
+		writer.emit(string(writer.partial))
+		writer.partial = nil
+	}
+}
+
+func (writer *lineWriter) emit(line string) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.onLine(writer.stream, line)
+}
+
+// teeWriter copies everything written to it to sink until a write to sink fails. From then on it discards what is
+// written to it, so that a sink that has gone away, such as a disconnected client, can't stall or fail the task.
+// Writers sharing a mutex never write to sink concurrently.
+type teeWriter struct {
+	sink  io.Writer
+	mutex *sync.Mutex
+	err   *error
+	// flushEachWrite flushes sink after every write, and unflushed is whether anything has been written to sink
+	// since it was last flushed.
+	flushEachWrite bool
+	unflushed      *bool
+}
+
+func newTeeWriters(sink io.Writer, flushEachWrite bool) (stdout *teeWriter, stderr *teeWriter) {
+	mutex := &sync.Mutex{}
+	err := new(error)
+	unflushed := new(bool)
+	return &teeWriter{sink: sink, mutex: mutex, err: err, flushEachWrite: flushEachWrite, unflushed: unflushed},
+		&teeWriter{sink: sink, mutex: mutex, err: err, flushEachWrite: flushEachWrite, unflushed: unflushed}
+}
+
+func (writer *teeWriter) Write(p []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	if *writer.err == nil {
+		if _, err := writer.sink.Write(p); err != nil {
+			*writer.err = err
+		}
+		*writer.unflushed = true
+		if writer.flushEachWrite {
+			writer.flushLocked()
+		}
+	}
+	return len(p), nil
+}
+
+// flushLocked flushes sink, if anything was written to it since it was last flushed, by calling its Flush method, as
+// for a *bufio.Writer, or its Sync method, as for an *os.File. An error from Flush is the stream's error; an error from
+// Sync is not, since Sync fails for files, such as terminals, that can't be synced but don't need to be.
+func (writer *teeWriter) flushLocked() {
+	if !*writer.unflushed || *writer.err != nil {
+		return
+	}
+	*writer.unflushed = false
+	switch sink := writer.sink.(type) {
+	case interface{ Flush() error }:
+		*writer.err = sink.Flush()
+	case interface{ Sync() error }:
+		sink.Sync()
+	}
+}
+
+// flushEvery flushes sink every interval, if anything was written to it in the meantime, until stop is called,
+// which flushes it one last time.
+func (writer *teeWriter) flushEvery(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ticker.C:
+				writer.mutex.Lock()
+				writer.flushLocked()
+				writer.mutex.Unlock()
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(stopped)
+		<-done
+		writer.mutex.Lock()
+		defer writer.mutex.Unlock()
+		writer.flushLocked()
+	}
+}
+
+// streamError returns the error shared by a pair of teeWriters.
+func (writer *teeWriter) streamError() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	return *writer.err
+}
+
+// compileWatchPatterns returns the task's WatchPatterns, or nothing if there is no OnMatch to report matches to.
+// Invalid patterns are reported by ValidateConfigs, and never match.
+func (ctx *GenericExecManager) compileWatchPatterns(execConfig *GenericExecConfig) []*regexp.Regexp {
+	if ctx.OnMatch == nil {
+		return nil
+	}
+	var patterns []*regexp.Regexp
+	for _, pattern := range execConfig.WatchPatterns {
+		if compiled, err := regexp.Compile(pattern); err == nil {
+			patterns = append(patterns, compiled)
+		}
+	}
+	return patterns
+}
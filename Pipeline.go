@@ -0,0 +1,26 @@
+package genericexec
+
+import (
+	"context"
+)
+
+// RunTaskPipeline runs the named tasks one after another with the same argValues, stopping after the first that
+// does not succeed, and returns the results of those that ran. Each task's templates can render the previous task's
+// output, exactly as written to stdout, with "prev_stdout", so a task whose Stdin is {{prev_stdout}} reads what the
+// task before it wrote.
+//
+// Unlike a pipe between processes, each task's whole output is held in memory and only passed on once the task has
+// exited, so this suits small amounts of intermediate data, and memory use grows with the size of the output.
+func (ctx *GenericExecManager) RunTaskPipeline(taskNames []string, argValues TemplateGetter) []GenericExecResult {
+	results := make([]GenericExecResult, 0, len(taskNames))
+	var prevStdOut string
+	for _, taskName := range taskNames {
+		result := <-ctx.RunTaskWithOptions(context.Background(), taskName, argValues, RunOptions{prevStdOut: prevStdOut})
+		results = append(results, result)
+		if !result.Succeeded {
+			break
+		}
+		prevStdOut = string(result.StdOutBytes)
+	}
+	return results
+}
@@ -0,0 +1,46 @@
+package genericexec
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestGenericExecManager_RunTaskPipeline(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"greet": {
+			Name:      "greet",
+			Command:   "echo",
+			Args:      []string{"hello", "{{request \"name\"}}"},
+			Reentrant: true,
+		},
+		"shout": {
+			Name:           "shout",
+			Command:        "upper",
+			Stdin:          "{{prev_stdout}}",
+			SuccessMessage: "{{prev_stdout}} became {{StdOut}}",
+			Reentrant:      true,
+		},
+		"fail": {
+			Name:      "fail",
+			Command:   "fail",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	results := sut.RunTaskPipeline([]string{"greet", "shout"}, url.Values{"name": []string{"world"}})
+	if len(results) != 2 {
+		t.Fatalf("Expected both tasks to run, got %+v", results)
+	}
+	if results[1].StdOut != "HELLO WORLD" {
+		t.Errorf("Expected the first task's output as the second's input, got \"%s\"", results[1].StdOut)
+	}
+	if results[1].Message != "hello world became HELLO WORLD" {
+		t.Errorf("Expected prev_stdout in the message, got \"%s\"", results[1].Message)
+	}
+
+	results = sut.RunTaskPipeline([]string{"greet", "fail", "shout"}, url.Values{"name": []string{"world"}})
+	if len(results) != 2 || results[1].Name != "fail" {
+		t.Errorf("Expected the pipeline to stop after the failed task, got %+v", results)
+	}
+}
@@ -0,0 +1,52 @@
+package genericexec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// usesPlaceholders reports whether args rendered with values are for a task with PlaceholderSyntax.
+func usesPlaceholders(values TemplateGetter) bool {
+	wrapped, isWrapped := values.(*runGetter)
+	return isWrapped && wrapped.placeholders
+}
+
+func renderPlaceholderArgs(args []string, values TemplateGetter) ([]string, error) {
+	renderedArgs := make([]string, len(args))
+	for ix, arg := range args {
+		rendered, err := renderPlaceholders(arg, values)
+		if err != nil {
+			return nil, err
+		}
+		renderedArgs[ix] = rendered
+	}
+	return renderedArgs, nil
+}
+
+// renderPlaceholders replaces each ${name} in text with the request value name, and each $$ with $.
+func renderPlaceholders(text string, values TemplateGetter) (string, error) {
+	var rendered strings.Builder
+	for {
+		dollar := strings.IndexByte(text, '$')
+		if dollar < 0 || dollar == len(text)-1 {
+			rendered.WriteString(text)
+			return rendered.String(), nil
+		}
+		rendered.WriteString(text[:dollar])
+		switch text[dollar+1] {
+		case '$':
+			rendered.WriteByte('$')
+			text = text[dollar+2:]
+		case '{':
+			end := strings.IndexByte(text[dollar+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated placeholder in \"%s\"", text)
+			}
+			rendered.WriteString(values.Get(text[dollar+2 : dollar+2+end]))
+			text = text[dollar+2+end+1:]
+		default:
+			rendered.WriteByte('$')
+			text = text[dollar+1:]
+		}
+	}
+}
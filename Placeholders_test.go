@@ -0,0 +1,64 @@
+package genericexec
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRenderPlaceholders(t *testing.T) {
+	values := MapGetter{"host": "db1", "port": "5432", "empty": ""}
+	cases := map[string]string{
+		"${host}:${port}":          "db1:5432",
+		"--host=${host}":           "--host=db1",
+		"costs $$5":                "costs $5",
+		"$${host}":                 "${host}",
+		"literal $ and $x and $":   "literal $ and $x and $",
+		"${missing}${empty}":       "",
+		"{{request \"host\"}}":     "{{request \"host\"}}",
+		"$$$${host}":               "$${host}",
+		"${host}${port}$${port}$$": "db15432${port}$",
+	}
+	for text, expect := range cases {
+		rendered, err := renderPlaceholders(text, values)
+		if err != nil || rendered != expect {
+			t.Errorf("Expected \"%s\" to render as \"%s\", got \"%s\" and error %v", text, expect, rendered, err)
+		}
+	}
+
+	if _, err := renderPlaceholders("--host=${host", values); err == nil {
+		t.Errorf("Expected an unterminated placeholder to be an error")
+	}
+}
+
+func TestGenericExecManager_PlaceholderSyntax(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:              "test",
+			Command:           "args",
+			Args:              []string{"--user=${user}", "--price=$$${price}", "{{request \"user\"}}"},
+			SuccessMessage:    "Charged {{request \"user\"}}",
+			PlaceholderSyntax: true,
+			Reentrant:         true,
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Errorf("Expected placeholder args to be valid, got %v", err)
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"user": []string{"alice"}, "price": []string{"10"}})
+	if expect := `["--user=alice" "--price=$10" "{{request \"user\"}}"]`; result.StdOut != expect {
+		t.Errorf("Expected args %s, got %s", expect, result.StdOut)
+	}
+	if result.Message != "Charged alice" {
+		t.Errorf("Expected messages to still be templates, got \"%s\"", result.Message)
+	}
+
+	err := ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", Args: []string{"${unterminated"}, PlaceholderSyntax: true},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unterminated placeholder") {
+		t.Errorf("Expected an unterminated placeholder to fail validation, got %v", err)
+	}
+}
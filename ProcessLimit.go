@@ -0,0 +1,52 @@
+package genericexec
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errProcessLimit = errors.New("process limit reached")
+
+// processSlotsFor returns the semaphore limiting the manager's concurrent processes to max.
+func (ctx *GenericExecManager) processSlotsFor(max int) chan struct{} {
+	ctx.processSlotsMutex.Lock()
+	defer ctx.processSlotsMutex.Unlock()
+	// As with MaxConcurrent, changing MaxProcesses starts a new semaphore, and runs holding a slot in the old one
+	// release it there.
+	if cap(ctx.processSlots) != max {
+		ctx.processSlots = make(chan struct{}, max)
+	}
+	return ctx.processSlots
+}
+
+// acquireProcessSlot waits for the run to be allowed to start its processes under MaxProcesses, and returns the
+// function to call once it has finished with them. It fails with errProcessLimit if EnqueueTimeout passes first, or
+// with the context's error if runContext is done first.
+func (ctx *GenericExecManager) acquireProcessSlot(runContext context.Context) (release func(), err error) {
+	uncount := func() { ctx.stats.processes.Add(-1) }
+	if ctx.MaxProcesses <= 0 {
+		ctx.stats.processes.Add(1)
+		return uncount, nil
+	}
+
+	slots := ctx.processSlotsFor(ctx.MaxProcesses)
+	var timeout <-chan time.Time
+	if ctx.EnqueueTimeout > 0 {
+		timer := time.NewTimer(ctx.EnqueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case slots <- struct{}{}:
+		ctx.stats.processes.Add(1)
+		return func() {
+			<-slots
+			uncount()
+		}, nil
+	case <-timeout:
+		return func() {}, errProcessLimit
+	case <-runContext.Done():
+		return func() {}, runContext.Err()
+	}
+}
@@ -0,0 +1,77 @@
+package genericexec
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_MaxProcesses(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"foo": {
+			Name:      "foo",
+			Command:   "timestamps",
+			Args:      []string{"200ms"},
+			Reentrant: true,
+		},
+		"bar": {
+			Name:    "bar",
+			Command: "timestamps",
+			Args:    []string{"200ms"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.MaxProcesses = 1
+
+	type interval struct {
+		start, end time.Time
+	}
+	var mutex sync.Mutex
+	var intervals []interval
+	var wg sync.WaitGroup
+	for _, taskName := range []string{"foo", "foo", "bar"} {
+		resultChan := sut.RunTask(taskName, url.Values{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := <-resultChan
+			if !result.Succeeded {
+				t.Errorf("Expected gated runs to succeed eventually, got %+v", result)
+			}
+			var start, end int64
+			fmt.Sscanf(result.StdOut, "%d %d", &start, &end)
+			mutex.Lock()
+			defer mutex.Unlock()
+			intervals = append(intervals, interval{start: time.Unix(0, start), end: time.Unix(0, end)})
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	if processes := sut.Stats().Processes; processes != 1 {
+		t.Errorf("Expected Stats to report 1 process while the first run executes, got %d", processes)
+	}
+	wg.Wait()
+
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+	for i := 1; i < len(intervals); i++ {
+		if intervals[i].start.Before(intervals[i-1].end) {
+			t.Errorf("Expected no two runs to execute at once with MaxProcesses 1, got %+v", intervals)
+		}
+	}
+	if processes := sut.Stats().Processes; processes != 0 {
+		t.Errorf("Expected Stats to report 0 processes once all runs finished, got %d", processes)
+	}
+
+	sut.EnqueueTimeout = 50 * time.Millisecond
+	first := sut.RunTask("foo", url.Values{})
+	time.Sleep(20 * time.Millisecond)
+	rejected := <-sut.RunTask("foo", url.Values{})
+	if rejected.Succeeded || rejected.FailureKind != FailureKindProcessLimit {
+		t.Errorf("Expected a run waiting past EnqueueTimeout to fail with %q, got %+v", FailureKindProcessLimit, rejected)
+	}
+	if result := <-first; !result.Succeeded {
+		t.Errorf("Expected the run holding the process slot to succeed, got %+v", result)
+	}
+}
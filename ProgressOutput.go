@@ -0,0 +1,23 @@
+package genericexec
+
+import (
+	"strings"
+)
+
+// collapseCarriageReturns returns output with each line reduced to what was written after its last carriage
+// return, so that a line a progress bar redrew many times reads as it was last drawn. A carriage return at the end of
+// a line, as in "\r\n", doesn't erase it.
+func collapseCarriageReturns(output string) string {
+	if !strings.Contains(output, "\r") {
+		return output
+	}
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if lastReturn := strings.LastIndexByte(line, '\r'); lastReturn >= 0 {
+			line = line[lastReturn+1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,54 @@
+package genericexec
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestGenericExecManager_CollapseCarriageReturns(t *testing.T) {
+	progress := "Downloading  10%\rDownloading  55%\rDownloading 100%\nVerifying\r\nDone"
+	taskConfigs := map[string]GenericExecConfig{
+		"collapsed": {
+			Name:                    "collapsed",
+			Command:                 "exit",
+			Args:                    []string{"0", progress},
+			SuccessMessage:          "{{StdOut}}",
+			CollapseCarriageReturns: true,
+			Reentrant:               true,
+		},
+		"raw": {
+			Name:      "raw",
+			Command:   "exit",
+			Args:      []string{"0", progress},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("collapsed", url.Values{})
+	if expect := "Downloading 100%\nVerifying\nDone"; result.StdOut != expect || result.Message != expect {
+		t.Errorf("Expected only the final progress state, got \"%s\" and message \"%s\"", result.StdOut, result.Message)
+	}
+	if string(result.StdOutBytes) != progress {
+		t.Errorf("Expected StdOutBytes to be left as written, got %q", result.StdOutBytes)
+	}
+
+	if result := <-sut.RunTask("raw", url.Values{}); result.StdOut != progress {
+		t.Errorf("Expected output to be kept raw by default, got %q", result.StdOut)
+	}
+}
+
+func TestCollapseCarriageReturns(t *testing.T) {
+	for input, expect := range map[string]string{
+		"":                    "",
+		"no returns\n":        "no returns\n",
+		"1/3\r2/3\r3/3":       "3/3",
+		"a\r\nb\r\n":          "a\nb\n",
+		"long line\rshort\n":  "short\n",
+		"\r\r\rafter returns": "after returns",
+	} {
+		if collapsed := collapseCarriageReturns(input); collapsed != expect {
+			t.Errorf("Expected %q to collapse to %q, got %q", input, expect, collapsed)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package genericexec
+
+// PauseCommand stops runs of non-reentrant tasks with the given Command from starting until ResumeCommand is called
+// for it. A run already executing finishes as usual, and runs submitted in the meantime wait in the queue, in order,
+// including after Shutdown. Once the queue is full, further runs are rejected right away with FailureKind
+// FailureKindEnqueueTimeout instead of waiting for the resume. Queued runs that are cancelled still get their results
+// right away. Pausing has no effect on reentrant tasks, which are never queued.
+func (ctx *GenericExecManager) PauseCommand(command string) {
+	ctx.pauseMutex.Lock()
+	defer ctx.pauseMutex.Unlock()
+	if ctx.pausedCommands == nil {
+		ctx.pausedCommands = make(map[string]chan struct{})
+	}
+	if ctx.pausedCommands[command] == nil {
+		ctx.pausedCommands[command] = make(chan struct{})
+	}
+}
+
+// ResumeCommand lets queued runs of the given Command start again after PauseCommand. It does nothing if the command
+// isn't paused.
+func (ctx *GenericExecManager) ResumeCommand(command string) {
+	ctx.pauseMutex.Lock()
+	defer ctx.pauseMutex.Unlock()
+	if resumed := ctx.pausedCommands[command]; resumed != nil {
+		close(resumed)
+		delete(ctx.pausedCommands, command)
+	}
+}
+
+// IsCommandPaused reports whether PauseCommand has been called for the given Command without ResumeCommand since.
+func (ctx *GenericExecManager) IsCommandPaused(command string) bool {
+	ctx.pauseMutex.Lock()
+	defer ctx.pauseMutex.Unlock()
+	return ctx.pausedCommands[command] != nil
+}
+
+// watchQueuedRun hands run its result as soon as its context is done, if it is still queued then, rather than
+// leaving it until the queue gets to it, which could be long after, behind other runs or a pause.
+func (ctx *GenericExecManager) watchQueuedRun(run *taskRun) {
+	select {
+	case <-run.runContext.Done():
+		if run.claimed.CompareAndSwap(false, true) {
+			// The run fails as cancelled or timed out without being started.
+			ctx.doRunRunRunDaDooRunRun(run)
+		}
+	case <-run.dequeued:
+	}
+}
+
+// waitWhilePaused returns once command isn't paused, or run's context is done so that it can fail as cancelled.
+func (ctx *GenericExecManager) waitWhilePaused(command string, run *taskRun) {
+	ctx.pauseMutex.Lock()
+	resumed := ctx.pausedCommands[command]
+	ctx.pauseMutex.Unlock()
+	if resumed == nil {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-run.runContext.Done():
+	}
+}
@@ -0,0 +1,146 @@
+package genericexec
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_PauseCommand(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:    "slow",
+			Command: "sleep",
+			Args:    []string{"200ms"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	runningChan := sut.RunTask("slow", url.Values{})
+	time.Sleep(50 * time.Millisecond)
+	sut.PauseCommand("sleep")
+	if !sut.IsCommandPaused("sleep") {
+		t.Error("Expected the command to be paused")
+	}
+	queuedChans := []<-chan GenericExecResult{sut.RunTask("slow", url.Values{}), sut.RunTask("slow", url.Values{})}
+
+	if result := <-runningChan; !result.Succeeded {
+		t.Errorf("Expected the run in progress when paused to finish, got %+v", result)
+	}
+	select {
+	case result := <-queuedChans[0]:
+		t.Fatalf("Expected nothing to start while paused, got %+v", result)
+	case <-time.After(400 * time.Millisecond):
+	}
+	if !sut.IsCommandBusy("sleep") {
+		t.Error("Expected the queued runs to still be waiting")
+	}
+
+	sut.ResumeCommand("sleep")
+	if sut.IsCommandPaused("sleep") {
+		t.Error("Expected the command not to be paused after resuming")
+	}
+	for i, queuedChan := range queuedChans {
+		if result := <-queuedChan; !result.Succeeded || result.QueueWait < 400*time.Millisecond {
+			t.Errorf("Expected queued run %d to run once resumed, got %+v", i, result)
+		}
+	}
+	sut.ResumeCommand("sleep")
+}
+
+func TestGenericExecManager_PauseCommand_FullQueue(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"echo": {
+			Name:    "echo",
+			Command: "echo",
+			Args:    []string{"hi"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.PauseCommand("echo")
+
+	// The consumer takes one run out of the queue to wait for the resume, so one more than the queue holds fits.
+	queuedChans := make([]<-chan GenericExecResult, cap(sut.mutexQueues["echo"].runs)+1)
+	for i := range queuedChans {
+		queuedChans[i] = sut.RunTask("echo", url.Values{})
+		if i == 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	select {
+	case result := <-sut.RunTask("echo", url.Values{}):
+		if result.FailureKind != FailureKindEnqueueTimeout || result.ExitCode == 0 {
+			t.Errorf("Expected a run finding the paused command's queue full to be rejected, got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a run finding the paused command's queue full not to wait")
+	}
+
+	replaced := make(chan error, 1)
+	go func() {
+		replaced <- sut.ReplaceConfigs(taskConfigs)
+	}()
+	select {
+	case err := <-replaced:
+		if err != nil {
+			t.Fatalf("Unexpected error replacing configs: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected ReplaceConfigs not to wait for the paused queue")
+	}
+	configured := make(chan bool, 1)
+	go func() {
+		configured <- sut.IsTaskConfigured("echo")
+	}()
+	select {
+	case <-configured:
+	case <-time.After(time.Second):
+		t.Fatal("Expected IsTaskConfigured not to wait for the paused queue")
+	}
+
+	sut.ResumeCommand("echo")
+	for i, queuedChan := range queuedChans {
+		if result := <-queuedChan; !result.Succeeded {
+			t.Errorf("Expected queued run %d to run once resumed, got %+v", i, result)
+		}
+	}
+}
+
+func TestGenericExecManager_PauseCommand_CancelBehindHead(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"echo": {
+			Name:    "echo",
+			Command: "echo",
+			Args:    []string{"hi"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.PauseCommand("echo")
+
+	headChan := sut.RunTask("echo", url.Values{})
+	runContext, cancel := context.WithCancel(context.Background())
+	cancelledChan := sut.RunTaskContext(runContext, "echo", url.Values{})
+	lastChan := sut.RunTask("echo", url.Values{})
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case result := <-cancelledChan:
+		if result.FailureKind != FailureKindCancelled {
+			t.Errorf("Expected the queued run to be cancelled, got %+v", result)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a run cancelled behind the head of a paused queue to get its result right away")
+	}
+
+	sut.ResumeCommand("echo")
+	for i, resultChan := range []<-chan GenericExecResult{headChan, lastChan} {
+		if result := <-resultChan; !result.Succeeded {
+			t.Errorf("Expected queued run %d to run once resumed, got %+v", i, result)
+		}
+	}
+	if sut.IsCommandBusy("echo") {
+		t.Error("Expected the cancelled run not to be left tracked")
+	}
+}
@@ -0,0 +1,40 @@
+package genericexec
+
+import (
+	"sort"
+	"strings"
+)
+
+const redactedText = "[REDACTED]"
+
+// secretValues returns the non-empty values of keys in values, along with any secrets a run's templates fetched with
+// "secret" so far, longest first, so that a secret containing another is redacted whole.
+func secretValues(keys []string, values TemplateGetter) []string {
+	var secrets []string
+	for _, key := range keys {
+		if value := values.Get(key); value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	if wrapped, isWrapped := values.(*runGetter); isWrapped && wrapped.fetchedSecrets != nil {
+		for _, value := range wrapped.fetchedSecrets.list() {
+			if value != "" {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+	sort.SliceStable(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
+
+// redact returns s with every occurrence of each of secrets replaced with redactedText.
+func redact(s string, secrets []string) string {
+	if len(secrets) == 0 || s == "" {
+		return s
+	}
+	replacements := make([]string, 0, 2*len(secrets))
+	for _, secret := range secrets {
+		replacements = append(replacements, secret, redactedText)
+	}
+	return strings.NewReplacer(replacements...).Replace(s)
+}
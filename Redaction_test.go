@@ -0,0 +1,82 @@
+package genericexec
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_StdInSent(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"login": {
+			Name:           "login",
+			Command:        "upper",
+			Args:           []string{"--user={{request \"user\"}}", "--password-hint={{request \"password\"}}"},
+			Stdin:          "{{request \"user\"}}:{{request \"password\"}}",
+			SecretKeys:     []string{"password", "absent"},
+			SuccessMessage: "Logged in with {{request \"password\"}}",
+			Reentrant:      true,
+		},
+		"capped": {
+			Name:           "capped",
+			Command:        "upper",
+			Stdin:          "0123456789",
+			MaxOutputBytes: 4,
+			Reentrant:      true,
+		},
+		"capped-runes": {
+			Name:           "capped-runes",
+			Command:        "upper",
+			Stdin:          "héllo",
+			MaxOutputBytes: 2,
+			Reentrant:      true,
+		},
+		"capped-latin1": {
+			Name:           "capped-latin1",
+			Command:        "upper",
+			Stdin:          "\xe9t\xe9 abc",
+			MaxOutputBytes: 4,
+			Reentrant:      true,
+		},
+		"no-stdin": {
+			Name:      "no-stdin",
+			Command:   "echo",
+			Reentrant: true,
+		},
+	}
+	sut, logBuf, notifications := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("login", url.Values{"user": []string{"alice"}, "password": []string{"hunter2"}})
+	if result.StdInSent != "alice:[REDACTED]" {
+		t.Errorf("Expected the sent stdin with the password redacted, got \"%s\"", result.StdInSent)
+	}
+	if result.StdOut != "ALICE:HUNTER2" {
+		t.Errorf("Expected the command to receive the real password, got \"%s\"", result.StdOut)
+	}
+	if result.Message != "Logged in with [REDACTED]" || (**notifications)[0] != result.Message {
+		t.Errorf("Expected the password redacted from the notification, got \"%s\"", result.Message)
+	}
+	if logged := logBuf.String(); strings.Contains(logged, "hunter2") || !strings.Contains(logged, "--password-hint=[REDACTED]") {
+		t.Errorf("Expected the password redacted from the log, got \"%s\"", logged)
+	}
+
+	if result := <-sut.RunTask("capped", url.Values{}); result.StdInSent != "0123" {
+		t.Errorf("Expected the sent stdin cut off at MaxOutputBytes, got \"%s\"", result.StdInSent)
+	}
+	if result := <-sut.RunTask("capped-runes", url.Values{}); result.StdInSent != "h" {
+		t.Errorf("Expected the sent stdin cut off before the rune MaxOutputBytes splits, got \"%s\"", result.StdInSent)
+	}
+	if result := <-sut.RunTask("capped-latin1", url.Values{}); result.StdInSent != "\xe9t\xe9 " {
+		t.Errorf("Expected the sent stdin's bytes kept as they were, got %q", result.StdInSent)
+	}
+	if result := <-sut.RunTask("no-stdin", url.Values{}); result.StdInSent != "" {
+		t.Errorf("Expected no sent stdin for a task without Stdin, got \"%s\"", result.StdInSent)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	secrets := secretValues([]string{"short", "long"}, MapGetter{"short": "abc", "long": "abcdef"})
+	if redacted := redact("abcdef abc ab", secrets); redacted != "[REDACTED] [REDACTED] ab" {
+		t.Errorf("Expected secrets containing others to be redacted whole, got \"%s\"", redacted)
+	}
+}
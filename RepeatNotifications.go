@@ -0,0 +1,49 @@
+package genericexec
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// sentNotification is what is remembered of the last notification a task sent, for SuppressRepeatNotifications.
+// The key is hashed so that the manager doesn't keep notifications or output, and any secrets in them, around.
+type sentNotification struct {
+	keyHash [sha256.Size]byte
+	sentAt  time.Time
+}
+
+// notificationKey returns what SuppressRepeatNotifications compares between a task's notifications.
+func notificationKey(execConfig *GenericExecConfig, result GenericExecResult, message string) string {
+	if execConfig.NotificationKey != nil {
+		return execConfig.NotificationKey(result, message)
+	}
+	if execConfig.SuppressRepeatNotifications == "output" {
+		return fmt.Sprintf("%d\x00%t\x00%s\x00%s", result.ExitCode, result.Succeeded, result.StdOut, result.StdErr)
+	}
+	return message
+}
+
+// repeatsLastNotification returns whether a run's notification should be suppressed because it repeats the last
+// one its task sent, and otherwise remembers it as the last one sent.
+func (ctx *GenericExecManager) repeatsLastNotification(execConfig *GenericExecConfig, result GenericExecResult, message string) bool {
+	if execConfig.SuppressRepeatNotifications == "" && execConfig.NotificationKey == nil {
+		return false
+	}
+	keyHash := sha256.Sum256([]byte(notificationKey(execConfig, result, message)))
+	now := ctx.now()
+
+	ctx.notifyMutex.Lock()
+	defer ctx.notifyMutex.Unlock()
+	last, found := ctx.lastNotifications[result.taskName]
+	if found && last.keyHash == keyHash {
+		if execConfig.RepeatNotificationInterval <= 0 || now.Sub(last.sentAt) < execConfig.RepeatNotificationInterval {
+			return true
+		}
+	}
+	if ctx.lastNotifications == nil {
+		ctx.lastNotifications = make(map[string]sentNotification)
+	}
+	ctx.lastNotifications[result.taskName] = sentNotification{keyHash: keyHash, sentAt: now}
+	return false
+}
@@ -0,0 +1,131 @@
+package genericexec
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_SuppressRepeatNotifications(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"check": {
+			Name:                        "check",
+			Command:                     "exit",
+			Args:                        []string{"{{request \"code\"}}", "{{request \"status\"}}"},
+			SuccessMessage:              "Status: {{StdOut}}",
+			ErrorMessage:                "Failing: {{StdOut}}",
+			SuppressRepeatNotifications: "message",
+		},
+	}
+	sut, _, notifications := sutFactory(taskConfigs, nil)
+
+	healthy := url.Values{"code": []string{"0"}, "status": []string{"healthy"}}
+	for i := 0; i < 3; i++ {
+		result := <-sut.RunTask("check", healthy)
+		if result.Message != "Status: healthy" || result.NotificationSuppressed != (i > 0) {
+			t.Errorf("Expected run %d to have its message, suppressed only after the first, got %+v", i, result)
+		}
+	}
+	if !reflect.DeepEqual(**notifications, []string{"Status: healthy"}) {
+		t.Errorf("Expected only one notification for three identical runs, got %v", **notifications)
+	}
+
+	<-sut.RunTask("check", url.Values{"code": []string{"1"}, "status": []string{"down"}})
+	<-sut.RunTask("check", healthy)
+	expected := []string{"Status: healthy", "Failing: down", "Status: healthy"}
+	if !reflect.DeepEqual(**notifications, expected) {
+		t.Errorf("Expected a notification each time the message changed, got %v", **notifications)
+	}
+}
+
+func TestGenericExecManager_SuppressRepeatNotifications_SharedName(t *testing.T) {
+	check := GenericExecConfig{
+		Command:                     "exit",
+		Args:                        []string{"0", "healthy"},
+		SuccessMessage:              "Status: {{StdOut}}",
+		SuppressRepeatNotifications: "message",
+	}
+	taskConfigs := map[string]GenericExecConfig{"web": check, "db": check}
+	sut, _, notifications := sutFactory(taskConfigs, nil)
+
+	for _, taskName := range []string{"web", "db", "web"} {
+		<-sut.RunTask(taskName, url.Values{})
+	}
+	expected := []string{"Status: healthy", "Status: healthy"}
+	if !reflect.DeepEqual(**notifications, expected) {
+		t.Errorf("Expected each task's first notification to be sent, got %v", **notifications)
+	}
+}
+
+func TestGenericExecManager_SuppressRepeatNotifications_Output(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"check": {
+			Name:                        "check",
+			Command:                     "exit",
+			Args:                        []string{"0", "{{request \"status\"}}"},
+			SuccessMessage:              "{{StdOut}} after {{Duration}}",
+			SuppressRepeatNotifications: "output",
+			RepeatNotificationInterval:  time.Hour,
+		},
+	}
+	sut, _, notifications := sutFactory(taskConfigs, nil)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sut.Clock = func() time.Time {
+		return now
+	}
+
+	healthy := url.Values{"status": []string{"healthy"}}
+	<-sut.RunTask("check", healthy)
+	now = now.Add(30 * time.Minute)
+	if result := <-sut.RunTask("check", healthy); !result.NotificationSuppressed {
+		t.Errorf("Expected the same output to be suppressed even though the message differs, got %+v", result)
+	}
+	now = now.Add(30 * time.Minute)
+	if result := <-sut.RunTask("check", healthy); result.NotificationSuppressed {
+		t.Errorf("Expected the unchanged notification to be sent once RepeatNotificationInterval passed, got %+v", result)
+	}
+	now = now.Add(time.Minute)
+	if result := <-sut.RunTask("check", healthy); !result.NotificationSuppressed {
+		t.Errorf("Expected the interval to restart from the heartbeat, got %+v", result)
+	}
+	if len(**notifications) != 2 {
+		t.Errorf("Expected the first notification and one heartbeat, got %v", **notifications)
+	}
+}
+
+func TestGenericExecManager_NotificationKey(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"check": {
+			Name:           "check",
+			Command:        "exit",
+			Args:           []string{"{{request \"code\"}}", "{{request \"status\"}}"},
+			SuccessMessage: "{{StdOut}}",
+			ErrorMessage:   "{{StdOut}}",
+			// Only notify when the task goes from passing to failing or back.
+			NotificationKey: func(result GenericExecResult, message string) string {
+				if result.Succeeded {
+					return "up"
+				}
+				return "down"
+			},
+		},
+	}
+	sut, _, notifications := sutFactory(taskConfigs, nil)
+
+	for _, run := range [][]string{{"0", "ok"}, {"0", "fine"}, {"1", "broken"}, {"2", "still broken"}, {"0", "ok"}} {
+		<-sut.RunTask("check", url.Values{"code": []string{run[0]}, "status": []string{run[1]}})
+	}
+	if !reflect.DeepEqual(**notifications, []string{"ok", "broken", "ok"}) {
+		t.Errorf("Expected a notification only when NotificationKey changed, got %v", **notifications)
+	}
+}
+
+func TestValidateConfigs_SuppressRepeatNotifications(t *testing.T) {
+	configs := map[string]GenericExecConfig{
+		"check": {Name: "check", Command: "true", SuppressRepeatNotifications: "always"},
+	}
+	if err := ValidateConfigs(configs); err == nil {
+		t.Error("Expected an unknown SuppressRepeatNotifications to be rejected")
+	}
+}
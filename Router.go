@@ -0,0 +1,51 @@
+package genericexec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ManagerRouter is a GenericExecManagerInterface that runs each task with one of several managers, chosen by the
+// task's name, for example to send tasks to pools of different hosts.
+type ManagerRouter struct {
+	// Route, if set, chooses the manager for a task, returning nil for tasks it doesn't route. It is consulted
+	// before Prefixes.
+	Route func(taskName string) GenericExecManagerInterface
+
+	// Prefixes maps task name prefixes to the manager that runs tasks whose names start with them. When several
+	// prefixes match, the longest wins.
+	Prefixes map[string]GenericExecManagerInterface
+}
+
+// RunTask runs the named task with the manager it routes to. A task that routes to no manager is not run; its
+// result has FailureKind FailureKindUnrouted.
+func (router *ManagerRouter) RunTask(taskName string, getter TemplateGetter) <-chan GenericExecResult {
+	if manager := router.managerFor(taskName); manager != nil {
+		return manager.RunTask(taskName, getter)
+	}
+	resultChan := make(chan GenericExecResult, 1)
+	resultChan <- GenericExecResult{
+		Name:        taskName,
+		ExitCode:    1,
+		StdErr:      fmt.Sprintf("The task was not run because no manager is routed to run task \"%s\".", taskName),
+		FailureKind: FailureKindUnrouted,
+	}
+	close(resultChan)
+	return resultChan
+}
+
+func (router *ManagerRouter) managerFor(taskName string) GenericExecManagerInterface {
+	if router.Route != nil {
+		if manager := router.Route(taskName); manager != nil {
+			return manager
+		}
+	}
+	var manager GenericExecManagerInterface
+	longest := -1
+	for prefix, candidate := range router.Prefixes {
+		if strings.HasPrefix(taskName, prefix) && len(prefix) > longest {
+			manager, longest = candidate, len(prefix)
+		}
+	}
+	return manager
+}
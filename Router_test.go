@@ -0,0 +1,51 @@
+package genericexec
+
+import (
+	"net/url"
+	"testing"
+)
+
+// namedManager records the tasks it is asked to run, and succeeds with its name as the output.
+type namedManager struct {
+	name string
+	ran  []string
+}
+
+func (manager *namedManager) RunTask(taskName string, getter TemplateGetter) <-chan GenericExecResult {
+	manager.ran = append(manager.ran, taskName)
+	resultChan := make(chan GenericExecResult, 1)
+	resultChan <- GenericExecResult{Name: taskName, Succeeded: true, StdOut: manager.name}
+	close(resultChan)
+	return resultChan
+}
+
+func TestManagerRouter(t *testing.T) {
+	builds, deploys, prodDeploys, special := &namedManager{name: "builds"}, &namedManager{name: "deploys"}, &namedManager{name: "prod"}, &namedManager{name: "special"}
+	var router GenericExecManagerInterface = &ManagerRouter{
+		Prefixes: map[string]GenericExecManagerInterface{
+			"build-":       builds,
+			"deploy-":      deploys,
+			"deploy-prod-": prodDeploys,
+		},
+		Route: func(taskName string) GenericExecManagerInterface {
+			if taskName == "build-special" {
+				return special
+			}
+			return nil
+		},
+	}
+
+	for taskName, expect := range map[string]string{"build-app": "builds", "deploy-staging": "deploys", "deploy-prod-web": "prod", "build-special": "special"} {
+		if result := <-router.RunTask(taskName, url.Values{}); result.StdOut != expect || result.Name != taskName {
+			t.Errorf("Expected %s to be run by %s, got %+v", taskName, expect, result)
+		}
+	}
+	if len(builds.ran) != 1 || builds.ran[0] != "build-app" || len(deploys.ran) != 1 || len(prodDeploys.ran) != 1 {
+		t.Errorf("Expected each task to be run once, by one manager, got %v %v %v", builds.ran, deploys.ran, prodDeploys.ran)
+	}
+
+	result := <-router.RunTask("test-unit", url.Values{})
+	if result.Succeeded || result.FailureKind != FailureKindUnrouted || result.StdErr == "" {
+		t.Errorf("Expected an unrouted task to fail clearly, got %+v", result)
+	}
+}
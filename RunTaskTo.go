@@ -0,0 +1,37 @@
+package genericexec
+
+import (
+	"context"
+)
+
+// RunTaskTo is like RunTask, but delivers the result on resultChan instead of a channel of its own, so that callers
+// running many tasks can reuse channels or collect the results of many runs on one. The manager never closes
+// resultChan. A result that finds resultChan full waits to be received on a goroutine of its own, so a slow receiver
+// doesn't hold up a non-reentrant task's queue, or RunTaskTo itself, but results may then arrive out of order.
+func (ctx *GenericExecManager) RunTaskTo(resultChan chan<- GenericExecResult, taskName string, argValues TemplateGetter) {
+	ctx.submitRun(context.Background(), taskName, argValues, RunOptions{resultTo: resultChan})
+}
+
+// resultSink is where a run's result is delivered: the channel the manager made for it, which is closed once the
+// result is delivered, or one a caller of RunTaskTo supplied, which is kept open.
+type resultSink struct {
+	resultChan chan<- GenericExecResult
+	keepOpen   bool
+}
+
+// send delivers result to the sink without waiting for it to be received.
+func (sink resultSink) send(result GenericExecResult) {
+	if !sink.keepOpen {
+		// The manager's own channels have room for their one result.
+		sink.resultChan <- result
+		close(sink.resultChan)
+		return
+	}
+	select {
+	case sink.resultChan <- result:
+	default:
+		go func() {
+			sink.resultChan <- result
+		}()
+	}
+}
@@ -0,0 +1,78 @@
+package genericexec
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_RunTaskTo(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"first": {
+			Name:      "first",
+			Command:   "exit",
+			Args:      []string{"0", "first"},
+			Reentrant: true,
+		},
+		"second": {
+			Name:    "second",
+			Command: "exit",
+			Args:    []string{"2", "second"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	shared := make(chan GenericExecResult, 4)
+	for _, taskName := range []string{"first", "second", "second", "first"} {
+		sut.RunTaskTo(shared, taskName, url.Values{})
+	}
+	var collected []string
+	for i := 0; i < 4; i++ {
+		result := <-shared
+		collected = append(collected, result.Name+":"+result.StdOut)
+	}
+	sort.Strings(collected)
+	if strings.Join(collected, " ") != "first:first first:first second:second second:second" {
+		t.Errorf("Expected every run's result on the shared channel, got %v", collected)
+	}
+
+	select {
+	case result, isOpen := <-shared:
+		t.Errorf("Expected the shared channel to be left open and empty, got %+v, open %v", result, isOpen)
+	default:
+	}
+
+	// The channel can be reused, including for results of runs that can't be started.
+	sut.RunTaskTo(shared, "first", url.Values{})
+	if result := <-shared; result.Name != "first" || !result.Succeeded {
+		t.Errorf("Expected a result on the reused channel, got %+v", result)
+	}
+
+	// Nothing waits for room on the channel: not the queue of a non-reentrant task, nor RunTaskTo itself.
+	unbuffered := make(chan GenericExecResult)
+	for i := 0; i < 3; i++ {
+		sut.RunTaskTo(unbuffered, "second", url.Values{})
+	}
+	for deadline := time.Now().Add(5 * time.Second); sut.IsCommandBusy("exit"); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the queue to get through runs whose results haven't been received")
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if result := <-unbuffered; result.StdOut != "second" {
+			t.Errorf("Expected every run's result on the unbuffered channel, got %+v", result)
+		}
+	}
+
+	sut.Shutdown()
+	sut.RunTaskTo(unbuffered, "first", url.Values{})
+	if result := <-unbuffered; result.FailureKind != FailureKindShuttingDown {
+		t.Errorf("Expected a run that can't be started to be reported on the unbuffered channel, got %+v", result)
+	}
+	sut.RunTaskTo(shared, "first", url.Values{})
+	if result := <-shared; result.FailureKind != FailureKindShuttingDown {
+		t.Errorf("Expected a run that can't be started to be reported on the channel, got %+v", result)
+	}
+}
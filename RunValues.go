@@ -0,0 +1,64 @@
+package genericexec
+
+import (
+	"sync"
+)
+
+// RunValues is a bag of values belonging to one task run, for passing data between the caller, BeforeRun, the
+// task's templates and whatever receives its result, such as OnResult, without globals. Its methods are safe to call
+// concurrently, from any of them; the values themselves are not protected, so values that will be modified
+// concurrently need synchronization of their own.
+type RunValues struct {
+	mutex  sync.RWMutex
+	values map[string]interface{}
+}
+
+// newRunValues returns the RunValues for a run, starting out with a copy of initial.
+func newRunValues(initial map[string]interface{}) *RunValues {
+	values := &RunValues{values: make(map[string]interface{}, len(initial))}
+	for key, value := range initial {
+		values.values[key] = value
+	}
+	return values
+}
+
+// Get returns the value stored under key, and whether there is one. It is safe to call on a nil RunValues, which has
+// no values.
+func (values *RunValues) Get(key string) (interface{}, bool) {
+	if values == nil {
+		return nil, false
+	}
+	values.mutex.RLock()
+	defer values.mutex.RUnlock()
+	value, found := values.values[key]
+	return value, found
+}
+
+// Set stores value under key, replacing any value already there.
+func (values *RunValues) Set(key string, value interface{}) {
+	values.mutex.Lock()
+	defer values.mutex.Unlock()
+	values.values[key] = value
+}
+
+// valueFunc implements "value": {{value "region"}} renders the value stored under "region" in the run's RunValues,
+// or the empty string if there is none.
+func valueFunc(values *RunValues) func(key string) interface{} {
+	return func(key string) interface{} {
+		if value, found := values.Get(key); found {
+			return value
+		}
+		return ""
+	}
+}
+
+// setValueFunc implements "setValue": {{setValue "region" "us-east-1"}} stores a value in the run's RunValues and
+// renders nothing. Outside of a run, it has nowhere to store the value and does nothing.
+func setValueFunc(values *RunValues) func(key string, value interface{}) string {
+	return func(key string, value interface{}) string {
+		if values != nil {
+			values.Set(key, value)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,72 @@
+package genericexec
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestGenericExecManager_RunValues(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"deploy": {
+			Name:           "deploy",
+			Command:        "args",
+			Args:           []string{"--build={{value \"build\"}}{{setValue \"rendered\" true}}"},
+			SuccessMessage: "Deployed build {{value \"build\"}} for {{value \"requester\"}} on {{value \"missing\"}}",
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.BeforeRun = func(taskName string, values *RunValues) {
+		if taskName != "deploy" {
+			t.Errorf("Expected BeforeRun to be called with the task name, got %s", taskName)
+		}
+		values.Set("build", 42)
+	}
+	var fromOnResult interface{}
+	sut.OnResult = func(result GenericExecResult) {
+		fromOnResult, _ = result.Values.Get("rendered")
+	}
+
+	initial := map[string]interface{}{"requester": "ops"}
+	result := <-sut.RunTaskWithOptions(context.Background(), "deploy", url.Values{}, RunOptions{Values: initial})
+	if result.StdOut != `["--build=42"]` {
+		t.Errorf("Expected the Args template to read the value stashed by BeforeRun, got %+v", result)
+	}
+	if result.Message != "Deployed build 42 for ops on " {
+		t.Errorf("Expected the message template to read the run's values, got \"%s\"", result.Message)
+	}
+	if fromOnResult != true {
+		t.Errorf("Expected OnResult to see the value the Args template set, got %v", fromOnResult)
+	}
+	if _, found := initial["build"]; found {
+		t.Error("Expected the caller's map not to be modified")
+	}
+
+	// Each run has values of its own.
+	sut.BeforeRun = nil
+	if result := <-sut.RunTask("deploy", url.Values{}); result.StdOut != `["--build="]` {
+		t.Errorf("Expected a run without values to render them empty, got %+v", result)
+	}
+}
+
+func TestRunValues_Concurrent(t *testing.T) {
+	values := newRunValues(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values.Set("last", i)
+			values.Get("last")
+		}(i)
+	}
+	wg.Wait()
+	if _, found := values.Get("last"); !found {
+		t.Error("Expected a value to be set")
+	}
+	var none *RunValues
+	if _, found := none.Get("last"); found {
+		t.Error("Expected a nil RunValues to have no values")
+	}
+}
@@ -0,0 +1,92 @@
+package genericexec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ScheduleOptions are settings for one schedule started with ScheduleTaskWithOptions.
+type ScheduleOptions struct {
+	// OnResult, if set, is called with the result of each of the schedule's runs, besides the manager's OnResult.
+	// A slow OnResult doesn't hold up the schedule, but calls for runs of a reentrant task can overlap. stop waits
+	// for calls in progress to return.
+	OnResult func(result GenericExecResult)
+}
+
+// ScheduleTask runs the named task, like RunTask, right away and then every interval, until stop is called or the
+// manager is shut down. Results go to OnResult, like those of every run; ScheduleTask has no other use for them.
+// Runs of a non-reentrant task never overlap: when the previous run is still going, the next one is skipped rather
+// than queued behind it. Reentrant tasks are started every interval regardless. The schedule ends by itself, with a
+// logged error, once ReplaceConfigs has removed the task.
+//
+// stop ends the schedule: it cancels the runs still going, if any, and waits for them to exit.
+func (ctx *GenericExecManager) ScheduleTask(taskName string, interval time.Duration, argValues TemplateGetter) (stop func()) {
+	return ctx.ScheduleTaskWithOptions(taskName, interval, argValues, ScheduleOptions{})
+}
+
+// ScheduleTaskWithOptions is like ScheduleTask, with additional settings for this schedule only.
+func (ctx *GenericExecManager) ScheduleTaskWithOptions(taskName string, interval time.Duration, argValues TemplateGetter, options ScheduleOptions) (stop func()) {
+	ctx.configMutex.RLock()
+	_, found := ctx.taskConfig(taskName)
+	ctx.configMutex.RUnlock()
+	if !found {
+		panic(fmt.Sprintf("No task configuration for task \"%s\"", taskName))
+	}
+	if interval <= 0 {
+		panic(fmt.Sprintf("Invalid interval %v for scheduling task \"%s\"", interval, taskName))
+	}
+
+	scheduleContext, cancel := context.WithCancel(context.Background())
+	var runs sync.WaitGroup
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var running atomic.Bool
+		for {
+			// The task is looked up again each time, so that ReplaceConfigs can change whether it is reentrant.
+			ctx.configMutex.RLock()
+			execConfig, found := ctx.taskConfig(taskName)
+			ctx.configMutex.RUnlock()
+			// A task that is gone is left to submitRun, which doesn't run it, to end the schedule.
+			if !found || execConfig.Reentrant || running.CompareAndSwap(false, true) {
+				// A full queue holds up the schedule until there is room, or the schedule is stopped.
+				resultChan, _ := ctx.submitRun(scheduleContext, taskName, argValues, RunOptions{ifConfigured: true})
+				if resultChan == nil {
+					ctx.logf(LogLevelError, "Stopped the schedule of task %s because the task is no longer configured.", taskName)
+					cancel()
+					return
+				}
+				runs.Add(1)
+				go func() {
+					defer runs.Done()
+					result := <-resultChan
+					running.Store(false)
+					if result.FailureKind == FailureKindShuttingDown {
+						cancel()
+					}
+					if options.OnResult != nil {
+						options.OnResult(result)
+					}
+				}()
+			} else {
+				ctx.logf(LogLevelWarn, "Skipped a scheduled run of task %s because the previous one is still going.", taskName)
+			}
+			select {
+			case <-ticker.C:
+			case <-scheduleContext.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-schedulerDone
+		runs.Wait()
+	}
+}
@@ -0,0 +1,243 @@
+package genericexec
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_ScheduleTask(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"fast": {
+			Name:    "fast",
+			Command: "echo",
+			Args:    []string{"tick"},
+		},
+		"slow": {
+			Name:    "slow",
+			Command: "timestamps",
+			Args:    []string{"250ms"},
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+	type interval struct {
+		start, end time.Time
+	}
+	var mutex sync.Mutex
+	fastRuns := 0
+	var slowRuns []interval
+	sut.OnResult = func(result GenericExecResult) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if result.Name == "fast" {
+			fastRuns++
+			return
+		}
+		var start, end int64
+		if _, err := fmt.Sscanf(result.StdOut, "%d %d", &start, &end); err == nil {
+			slowRuns = append(slowRuns, interval{start: time.Unix(0, start), end: time.Unix(0, end)})
+		}
+	}
+	counts := func() (int, int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return fastRuns, len(slowRuns)
+	}
+
+	stopFast := sut.ScheduleTask("fast", 50*time.Millisecond, url.Values{})
+	stopSlow := sut.ScheduleTask("slow", 50*time.Millisecond, url.Values{})
+	deadline := time.Now().Add(30 * time.Second)
+	for fast, slow := counts(); fast < 5 || slow < 2; fast, slow = counts() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected scheduled tasks to run repeatedly, but they ran %d and %d times", fast, slow)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	stopFast()
+	stopSlow()
+
+	mutex.Lock()
+	for i := 1; i < len(slowRuns); i++ {
+		if slowRuns[i].start.Before(slowRuns[i-1].end) {
+			t.Errorf("Expected scheduled runs of a non-reentrant task not to overlap, got %+v", slowRuns)
+		}
+	}
+	mutex.Unlock()
+	if !strings.Contains(testLogBuf.String(), "Skipped a scheduled run of task slow") {
+		t.Errorf("Expected skipped runs to be logged, got \"%s\"", testLogBuf.String())
+	}
+
+	stopped, _ := counts()
+	time.Sleep(200 * time.Millisecond)
+	if fast, _ := counts(); fast != stopped {
+		t.Errorf("Expected no runs after stop, got %d more", fast-stopped)
+	}
+	if sut.IsCommandBusy("timestamps") || sut.IsCommandBusy("echo") {
+		t.Errorf("Expected stop to wait for the schedule's runs to exit")
+	}
+}
+
+func TestGenericExecManager_ScheduleTaskWithOptions(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"fast": {
+			Name:    "fast",
+			Command: "echo",
+			Args:    []string{"tick"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var mutex sync.Mutex
+	var outputs []string
+	stop := sut.ScheduleTaskWithOptions("fast", 50*time.Millisecond, url.Values{}, ScheduleOptions{
+		OnResult: func(result GenericExecResult) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			outputs = append(outputs, result.StdOut)
+		},
+	})
+	count := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(outputs)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for count() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the schedule's OnResult to be called for each run, got %d calls", count())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	mutex.Lock()
+	completed := append([]string(nil), outputs...)
+	mutex.Unlock()
+	// stop cancels a run in progress, whose result is still delivered.
+	stop()
+
+	for _, output := range completed {
+		if output != "tick" {
+			t.Errorf("Expected OnResult to get each run's result, got %v", completed)
+			break
+		}
+	}
+}
+
+func TestGenericExecManager_ScheduleTask_Removed(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"fast": {
+			Name:    "fast",
+			Command: "echo",
+			Args:    []string{"tick"},
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+	ran := make(chan struct{}, 100)
+	stop := sut.ScheduleTaskWithOptions("fast", 50*time.Millisecond, url.Values{}, ScheduleOptions{
+		OnResult: func(GenericExecResult) {
+			ran <- struct{}{}
+		},
+	})
+	<-ran
+	if err := sut.ReplaceConfigs(map[string]GenericExecConfig{}); err != nil {
+		t.Fatalf("Unexpected error replacing configs: %v", err)
+	}
+
+	// Ticks after the task is gone must neither panic nor run anything.
+	time.Sleep(300 * time.Millisecond)
+	for len(ran) > 0 {
+		<-ran
+	}
+	time.Sleep(200 * time.Millisecond)
+	stop()
+	if len(ran) > 0 {
+		t.Errorf("Expected no runs once the task was removed, got %d", len(ran))
+	}
+	if !strings.Contains(testLogBuf.String(), "Stopped the schedule of task fast") {
+		t.Errorf("Expected the end of the schedule to be logged, got \"%s\"", testLogBuf.String())
+	}
+}
+
+func TestGenericExecManager_ScheduleTask_FollowsReplaceConfigs(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"300ms"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	logger := &capturingLeveledLogger{}
+	sut.LeveledLogger = logger
+	skips := func() int {
+		logger.mutex.Lock()
+		defer logger.mutex.Unlock()
+		count := 0
+		for _, entry := range logger.entries {
+			if strings.HasPrefix(entry.message, "Skipped a scheduled run of task slow") {
+				count++
+			}
+		}
+		return count
+	}
+
+	stop := sut.ScheduleTask("slow", 50*time.Millisecond, url.Values{})
+	defer stop()
+	time.Sleep(200 * time.Millisecond)
+	if skips() != 0 {
+		t.Errorf("Expected runs of a reentrant task to overlap, but %d were skipped", skips())
+	}
+	taskConfigs["slow"] = GenericExecConfig{Name: "slow", Command: "sleep", Args: []string{"300ms"}}
+	if err := sut.ReplaceConfigs(taskConfigs); err != nil {
+		t.Fatalf("Unexpected error replacing configs: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); skips() == 0; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected runs to be skipped once the task was no longer reentrant")
+		}
+	}
+}
+
+func TestGenericExecManager_ScheduleTask_StopWithFullQueue(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:    "slow",
+			Command: "sleep",
+			Args:    []string{"5s"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	// One run holds the command and the rest fill its queue.
+	fillContext, cancelFill := context.WithCancel(context.Background())
+	fillers := []<-chan GenericExecResult{sut.RunTaskContext(fillContext, "slow", url.Values{})}
+	for !sut.IsCommandBusy("sleep") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 50; i++ {
+		fillers = append(fillers, sut.RunTaskContext(fillContext, "slow", url.Values{}))
+	}
+	defer func() {
+		cancelFill()
+		for _, filler := range fillers {
+			<-filler
+		}
+	}()
+
+	stop := sut.ScheduleTask("slow", time.Hour, url.Values{})
+	time.Sleep(100 * time.Millisecond)
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected stop to return while the schedule's run waited for room in the queue")
+	}
+}
@@ -0,0 +1,78 @@
+package genericexec
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SecretProvider supplies secrets, such as passwords or API tokens, to task templates through "secret", so that they
+// don't have to be passed in request values. See GenericExecManager.SecretProvider.
+type SecretProvider interface {
+	Get(name string) (string, error)
+}
+
+// fetchedSecrets is the secrets a run's templates fetched with "secret", by name, so that each is fetched once per
+// run and redacted along with the task's SecretKeys values. Since template execution errors are otherwise ignored, err
+// keeps the first failure to fetch one, to fail the run with.
+type fetchedSecrets struct {
+	mutex  sync.Mutex
+	values map[string]string
+	err    error
+}
+
+// secretFunc implements "secret": {{secret "db-password"}} renders the named secret from the manager's
+// SecretProvider. A secret that can't be fetched fails rendering the template.
+func secretFunc(manager *GenericExecManager, fetched *fetchedSecrets) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		if fetched == nil {
+			return "", fmt.Errorf("secret \"%s\" requested outside of a task run", name)
+		}
+		fetched.mutex.Lock()
+		defer fetched.mutex.Unlock()
+		if value, found := fetched.values[name]; found {
+			return value, nil
+		}
+		if manager == nil || manager.SecretProvider == nil {
+			return "", fetched.fail(fmt.Errorf("secret \"%s\" requested, but there is no SecretProvider", name))
+		}
+		value, err := manager.SecretProvider.Get(name)
+		if err != nil {
+			return "", fetched.fail(fmt.Errorf("could not get secret \"%s\": %v", name, err))
+		}
+		if fetched.values == nil {
+			fetched.values = make(map[string]string)
+		}
+		fetched.values[name] = value
+		return value, nil
+	}
+}
+
+// list returns the values fetched so far.
+func (fetched *fetchedSecrets) list() []string {
+	fetched.mutex.Lock()
+	defer fetched.mutex.Unlock()
+	values := make([]string, 0, len(fetched.values))
+	for _, value := range fetched.values {
+		values = append(values, value)
+	}
+	return values
+}
+
+// fail records err, unless an earlier failure was recorded, and returns it. It must be called with mutex held.
+func (fetched *fetchedSecrets) fail(err error) error {
+	if fetched.err == nil {
+		fetched.err = err
+	}
+	return err
+}
+
+// secretFetchError returns the first error fetching a secret for the run that values belong to, if there was one.
+func secretFetchError(values TemplateGetter) error {
+	wrapped, isWrapped := values.(*runGetter)
+	if !isWrapped || wrapped.fetchedSecrets == nil {
+		return nil
+	}
+	wrapped.fetchedSecrets.mutex.Lock()
+	defer wrapped.fetchedSecrets.mutex.Unlock()
+	return wrapped.fetchedSecrets.err
+}
@@ -0,0 +1,70 @@
+package genericexec
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type fakeSecretProvider struct {
+	secrets map[string]string
+	fetches int
+}
+
+func (provider *fakeSecretProvider) Get(name string) (string, error) {
+	provider.fetches++
+	if secret, found := provider.secrets[name]; found {
+		return secret, nil
+	}
+	return "", errors.New("no such secret")
+}
+
+func TestGenericExecManager_SecretProvider(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"login": {
+			Name:           "login",
+			Command:        "upper",
+			Args:           []string{"--token={{secret \"api-token\"}}"},
+			Stdin:          "{{secret \"api-token\"}}",
+			SuccessMessage: "Logged in with {{secret \"api-token\"}}",
+			Reentrant:      true,
+		},
+		"missing": {
+			Name:      "missing",
+			Command:   "upper",
+			Args:      []string{"--token={{secret \"no-such-token\"}}"},
+			Reentrant: true,
+		},
+	}
+	sut, logBuf, notifications := sutFactory(taskConfigs, nil)
+	provider := &fakeSecretProvider{secrets: map[string]string{"api-token": "s3cret"}}
+	sut.SecretProvider = provider
+
+	result := <-sut.RunTask("login", url.Values{})
+	if result.StdOut != "S3CRET" {
+		t.Errorf("Expected the command to receive the secret, got %+v", result)
+	}
+	if provider.fetches != 1 {
+		t.Errorf("Expected the secret to be fetched once for the run, got %d fetches", provider.fetches)
+	}
+	if result.StdInSent != "[REDACTED]" {
+		t.Errorf("Expected the secret redacted from the sent stdin, got \"%s\"", result.StdInSent)
+	}
+	if result.Message != "Logged in with [REDACTED]" || (**notifications)[0] != result.Message {
+		t.Errorf("Expected the secret redacted from the notification, got \"%s\"", result.Message)
+	}
+	if logged := logBuf.String(); strings.Contains(logged, "s3cret") || !strings.Contains(logged, "--token=[REDACTED]") {
+		t.Errorf("Expected the secret redacted from the log, got \"%s\"", logged)
+	}
+
+	result = <-sut.RunTask("missing", url.Values{})
+	if result.ExitCode != 1 || !strings.Contains(result.StdErr, "could not get secret \"no-such-token\": no such secret") {
+		t.Errorf("Expected a secret that can't be fetched to fail the run clearly, got %+v", result)
+	}
+
+	sut.SecretProvider = nil
+	if result := <-sut.RunTask("login", url.Values{}); result.ExitCode != 1 || !strings.Contains(result.StdErr, "there is no SecretProvider") {
+		t.Errorf("Expected a secret without a SecretProvider to fail the run, got %+v", result)
+	}
+}
@@ -0,0 +1,42 @@
+package genericexec
+
+import (
+	"strings"
+)
+
+// ShellQuote quotes s so that a POSIX shell treats it as a single literal word. Quotes, spaces, "$()", backticks
+// and all other shell syntax in s lose their special meaning. It is available to templates as "shquote".
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// WindowsQuote quotes s so that it is parsed as a single argument by CommandLineToArgvW and the Microsoft C runtime,
+// which is how most Windows programs split their command line. It is available to templates as "winquote".
+// It does not protect against cmd.exe metacharacters.
+func WindowsQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\v\"") {
+		return s
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	backslashes := 0
+	for _, c := range s {
+		switch c {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			// Backslashes preceding a quote must be escaped, and so must the quote itself.
+			quoted.WriteString(strings.Repeat(`\`, backslashes*2+1))
+		default:
+			quoted.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		quoted.WriteRune(c)
+	}
+	// Backslashes preceding the closing quote must be escaped too.
+	quoted.WriteString(strings.Repeat(`\`, backslashes*2))
+	quoted.WriteByte('"')
+	return quoted.String()
+}
@@ -0,0 +1,50 @@
+package genericexec
+
+import (
+	"net/url"
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("No POSIX shell available")
+	}
+
+	for _, value := range []string{"", "plain", "with spaces", "it's \"quoted\"", "$(echo injected) `echo injected` $HOME; echo injected &", "'''"} {
+		rendered, err := RenderArgTemplates([]string{"printf %s {{request \"value\" | shquote}}"}, url.Values{"value": []string{value}})
+		if err != nil {
+			t.Fatalf("Unexpected error rendering template: %v", err)
+		}
+		out, err := exec.Command(shell, "-c", rendered[0]).Output()
+		if err != nil {
+			t.Fatalf("Shell failed to run %s: %v", rendered[0], err)
+		}
+		if string(out) != value {
+			t.Errorf("Expected the shell to see \"%s\" literally, got \"%s\"", value, out)
+		}
+	}
+}
+
+func TestWindowsQuote(t *testing.T) {
+	cases := map[string]string{
+		"":                   `""`,
+		"plain":              `plain`,
+		`C:\path\to\file`:    `C:\path\to\file`,
+		"with spaces":        `"with spaces"`,
+		`say "hi"`:           `"say \"hi\""`,
+		`trailing slash\ x\`: `"trailing slash\ x\\"`,
+		`slash before \"`:    `"slash before \\\""`,
+	}
+	for value, expect := range cases {
+		if quoted := WindowsQuote(value); quoted != expect {
+			t.Errorf("Expected WindowsQuote(%s) to be %s, got %s", value, expect, quoted)
+		}
+	}
+
+	rendered, err := RenderArgTemplates([]string{"{{request \"value\" | winquote}}"}, url.Values{"value": []string{"a b"}})
+	if err != nil || rendered[0] != `"a b"` {
+		t.Errorf("Expected winquote to be available to templates, got %v, %v", rendered, err)
+	}
+}
@@ -0,0 +1,83 @@
+package genericexec
+
+import (
+	"sync/atomic"
+)
+
+// ManagerStats is a snapshot of a manager's activity, for polling. Every result the manager delivers is counted
+// once in TotalRuns and in exactly one of Succeeded and Failed, including results for tasks that could not be
+// started. TimedOut counts the failures with FailureKind FailureKindTimeout or FailureKindEnqueueTimeout. Results
+// are counted as they were before any ResultTransformer.
+type ManagerStats struct {
+	TotalRuns uint64
+	Succeeded uint64
+	Failed    uint64
+	TimedOut  uint64
+
+	// Processes is how many task runs have a process running right now. See GenericExecManager.MaxProcesses.
+	Processes int64
+
+	// DroppedCallbacks counts observer callbacks that were not called because the queue was full.
+	// See GenericExecManager.ObserverQueueSize.
+	DroppedCallbacks uint64
+
+	// Commands has an entry for each Command with runs waiting in its queue or executing.
+	Commands map[string]CommandStats
+}
+
+// CommandStats counts the runs of one Command that are waiting to start and that are executing.
+type CommandStats struct {
+	Queued   int
+	InFlight int
+}
+
+type runStats struct {
+	total     atomic.Uint64
+	succeeded atomic.Uint64
+	failed    atomic.Uint64
+	timedOut  atomic.Uint64
+
+	droppedCallbacks atomic.Uint64
+	processes        atomic.Int64
+}
+
+func (ctx *GenericExecManager) countResult(result GenericExecResult) {
+	ctx.stats.total.Add(1)
+	if result.Succeeded {
+		ctx.stats.succeeded.Add(1)
+	} else {
+		ctx.stats.failed.Add(1)
+	}
+	if result.FailureKind == FailureKindTimeout || result.FailureKind == FailureKindEnqueueTimeout {
+		ctx.stats.timedOut.Add(1)
+	}
+}
+
+// Stats returns a snapshot of the manager's counters and current runs.
+func (ctx *GenericExecManager) Stats() ManagerStats {
+	stats := ManagerStats{
+		TotalRuns: ctx.stats.total.Load(),
+		Succeeded: ctx.stats.succeeded.Load(),
+		Failed:    ctx.stats.failed.Load(),
+		TimedOut:  ctx.stats.timedOut.Load(),
+
+		Processes:        ctx.stats.processes.Load(),
+		DroppedCallbacks: ctx.stats.droppedCallbacks.Load(),
+		Commands:         make(map[string]CommandStats),
+	}
+
+	ctx.activeMutex.Lock()
+	defer ctx.activeMutex.Unlock()
+	for command, runs := range ctx.activeRuns {
+		var commandStats CommandStats
+		for run := range runs {
+			if run.started {
+				commandStats.InFlight++
+			} else {
+				commandStats.Queued++
+			}
+		}
+		stats.Commands[command] = commandStats
+	}
+	return stats
+}
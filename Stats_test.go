@@ -0,0 +1,45 @@
+package genericexec
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_Stats(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"succeed": {Name: "succeed", Command: "echo", Reentrant: true},
+		"fail":    {Name: "fail", Command: "fail", Reentrant: true},
+		"slow":    {Name: "slow", Command: "sleep", Args: []string{"500ms"}},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	<-sut.RunTask("succeed", url.Values{})
+	<-sut.RunTask("succeed", url.Values{})
+	<-sut.RunTask("fail", url.Values{})
+	timeoutContext, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	<-sut.RunTaskContext(timeoutContext, "slow", url.Values{})
+
+	stats := sut.Stats()
+	if stats.TotalRuns != 4 || stats.Succeeded != 2 || stats.Failed != 2 || stats.TimedOut != 1 {
+		t.Errorf("Expected 4 runs, 2 succeeded, 2 failed and 1 timed out, got %+v", stats)
+	}
+	if len(stats.Commands) != 0 {
+		t.Errorf("Expected no commands to be active, got %v", stats.Commands)
+	}
+
+	first := sut.RunTask("slow", url.Values{})
+	second := sut.RunTask("slow", url.Values{})
+	time.Sleep(100 * time.Millisecond)
+	stats = sut.Stats()
+	if stats.Commands["sleep"] != (CommandStats{Queued: 1, InFlight: 1}) {
+		t.Errorf("Expected one run in flight and one queued, got %+v", stats.Commands["sleep"])
+	}
+	<-first
+	<-second
+	if stats = sut.Stats(); stats.TotalRuns != 6 || stats.Succeeded != 4 {
+		t.Errorf("Expected 6 runs with 4 succeeded, got %+v", stats)
+	}
+}
@@ -0,0 +1,84 @@
+package genericexec
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// RunTaskWithStdin is like RunTaskContext, with stdin copied to the task's process on its standard input as the
+// process reads it. See RunOptions.Stdin.
+func (ctx *GenericExecManager) RunTaskWithStdin(runContext context.Context, taskName string, argValues TemplateGetter, stdin io.Reader) <-chan GenericExecResult {
+	return ctx.RunTaskWithOptions(runContext, taskName, argValues, RunOptions{Stdin: stdin})
+}
+
+// stdinStream is a run's RunOptions.Stdin as given to its process. It tells errors reading the reader apart from
+// errors writing to the process, which exec.Cmd reports alike.
+type stdinStream struct {
+	reader io.Reader
+
+	mutex   sync.Mutex
+	readErr error
+	// finished is set once finish has begun closing the reader, whose Read may then fail for that reason alone.
+	finished bool
+
+	// pipeReader and pipeWriter are the pipe the process reads the stream from, once attached.
+	pipeReader *os.File
+	pipeWriter *os.File
+}
+
+// attach gives cmd the read end of a pipe to read the stream from, and copies the stream into it on a goroutine of
+// its own. Given the stream itself, exec.Cmd would copy it, and wait for the copy to finish even after the process
+// exited, so that a reader that never ends, such as a network stream, would keep the run from ever completing. This
+// copy is abandoned at finish instead.
+func (stream *stdinStream) attach(cmd *exec.Cmd) error {
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	stream.pipeReader, stream.pipeWriter = pipeReader, pipeWriter
+	cmd.Stdin = pipeReader
+	go func() {
+		// Errors writing to the process, which needn't read all of its input, aren't the stream's.
+		io.Copy(pipeWriter, stream)
+		pipeWriter.Close()
+	}()
+	return nil
+}
+
+func (stream *stdinStream) Read(p []byte) (int, error) {
+	n, err := stream.reader.Read(p)
+	if err != nil && err != io.EOF {
+		stream.mutex.Lock()
+		if !stream.finished {
+			stream.readErr = err
+		}
+		stream.mutex.Unlock()
+	}
+	return n, err
+}
+
+// finish closes the reader, and the pipe if the stream was attached, and returns the error reading it, if there was
+// one.
+func (stream *stdinStream) finish() error {
+	stream.mutex.Lock()
+	stream.finished = true
+	stream.mutex.Unlock()
+	if stream.pipeWriter != nil {
+		stream.pipeReader.Close()
+		stream.pipeWriter.Close()
+	}
+	closeReader(stream.reader)
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.readErr
+}
+
+// closeReader closes reader if it is an io.Closer.
+func closeReader(reader io.Reader) {
+	if closer, isCloser := reader.(io.Closer); isCloser {
+		closer.Close()
+	}
+}
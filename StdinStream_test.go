@@ -0,0 +1,116 @@
+package genericexec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// generatedReader supplies size bytes without holding them, failing with err instead of io.EOF at the end if set,
+// and records whether it was closed.
+type generatedReader struct {
+	size   int
+	err    error
+	closed bool
+}
+
+func (reader *generatedReader) Read(p []byte) (int, error) {
+	if reader.size == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+		return 0, io.EOF
+	}
+	if len(p) > reader.size {
+		p = p[:reader.size]
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	reader.size -= len(p)
+	return len(p), nil
+}
+
+func (reader *generatedReader) Close() error {
+	reader.closed = true
+	return nil
+}
+
+func TestGenericExecManager_RunTaskWithStdin(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"count": {
+			Name:      "count",
+			Command:   "countstdin",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	stdin := &generatedReader{size: 8 << 20}
+	result := <-sut.RunTaskWithStdin(context.Background(), "count", url.Values{}, stdin)
+	if !result.Succeeded || result.StdOut != "8388608" {
+		t.Errorf("Expected the process to read all 8388608 bytes of stdin, got %+v", result)
+	}
+	if !stdin.closed {
+		t.Errorf("Expected stdin to be closed once the run was over")
+	}
+
+	broken := errors.New("disk on fire")
+	stdin = &generatedReader{size: 1 << 20, err: broken}
+	result = <-sut.RunTaskWithStdin(context.Background(), "count", url.Values{}, stdin)
+	if result.Succeeded || !errors.Is(result.StdinError, broken) {
+		t.Errorf("Expected a run whose stdin couldn't be read to fail with the read error, got %+v", result)
+	}
+	if !stdin.closed {
+		t.Errorf("Expected stdin to be closed once the run was failed")
+	}
+
+	sut.Shutdown()
+	stdin = &generatedReader{size: 1}
+	<-sut.RunTaskWithStdin(context.Background(), "count", url.Values{}, stdin)
+	if !stdin.closed {
+		t.Errorf("Expected stdin to be closed when the task could not be started")
+	}
+}
+
+// blockingReader never supplies anything, blocking each Read until it is closed, like a network stream left open.
+// Like a net.Conn, it then fails with net.ErrClosed.
+type blockingReader struct {
+	closed chan struct{}
+}
+
+func (reader *blockingReader) Read(p []byte) (int, error) {
+	<-reader.closed
+	return 0, net.ErrClosed
+}
+
+func (reader *blockingReader) Close() error {
+	close(reader.closed)
+	return nil
+}
+
+func TestGenericExecManager_RunTaskWithStdin_Blocking(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"ignore": {
+			Name:      "ignore",
+			Command:   "exit",
+			Args:      []string{"0", "done"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	stdin := &blockingReader{closed: make(chan struct{})}
+	select {
+	case result := <-sut.RunTaskWithStdin(context.Background(), "ignore", url.Values{}, stdin):
+		if !result.Succeeded || result.StdOut != "done" || result.StdinError != nil {
+			t.Errorf("Expected the process to exit without reading stdin, got %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the run to complete once the process exited, though stdin never ended")
+	}
+}
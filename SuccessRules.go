@@ -0,0 +1,72 @@
+package genericexec
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// decideSuccess applies the task's success rules to a completed run. The reason is set when the outcome is not
+// down to the exit code alone.
+//
+// The rules, in order of precedence:
+//  1. If the run has a FailureKind, such as because its PreCommand failed or it timed out, it failed.
+//  2. If FailureStderrPattern matches StdErr, the run failed.
+//  3. If SuccessStderrPattern matches StdErr, the run succeeded.
+//  4. The run succeeded if it exited 0.
+//
+// A task's SuccessFunc, if it has one, overrides all but the first rule; see applySuccessFunc.
+func decideSuccess(execConfig *GenericExecConfig, result *GenericExecResult) (succeeded bool, reason string) {
+	exitedZero := result.ExitCode == 0
+	switch result.FailureKind {
+	case FailureKindNone:
+	case FailureKindPreCommand:
+		return false, "its PreCommand failed"
+	case FailureKindOutputLimit:
+		return false, fmt.Sprintf("it was stopped for writing more than %d bytes", execConfig.FailOnOutputBytes)
+	case FailureKindCPULimit:
+		return false, fmt.Sprintf("it was stopped for using more than %g times the CPU time it ran for", execConfig.MaxCPURatio)
+	case FailureKindTimeout:
+		return false, "it timed out"
+	case FailureKindCancelled:
+		return false, "its run was cancelled"
+	case FailureKindNotFound, FailureKindPermission, FailureKindStart:
+		return false, "it could not be started"
+	default:
+		return false, string(result.FailureKind)
+	}
+	if result.StdinError != nil {
+		return false, fmt.Sprintf("its stdin could not be read: %v", result.StdinError)
+	}
+	if stderrMatches(execConfig.FailureStderrPattern, result.StdErr) {
+		return false, reasonIfChanged(exitedZero, false, "StdErr matched FailureStderrPattern")
+	}
+	if stderrMatches(execConfig.SuccessStderrPattern, result.StdErr) {
+		return true, reasonIfChanged(exitedZero, true, "StdErr matched SuccessStderrPattern")
+	}
+	return exitedZero, ""
+}
+
+func stderrMatches(pattern string, stderr string) bool {
+	if pattern == "" {
+		return false
+	}
+	// Invalid patterns are reported by ValidateConfigs, and never match.
+	matched, err := regexp.MatchString(pattern, stderr)
+	return err == nil && matched
+}
+
+func reasonIfChanged(exitedZero bool, succeeded bool, reason string) string {
+	if exitedZero == succeeded {
+		return ""
+	}
+	return reason
+}
+
+// applySuccessFunc lets the task's SuccessFunc decide the outcome of a completed run.
+func applySuccessFunc(execConfig *GenericExecConfig, result GenericExecResult) (succeeded bool, reason string) {
+	succeeded = execConfig.SuccessFunc(result)
+	if succeeded {
+		return true, reasonIfChanged(result.ExitCode == 0, true, "SuccessFunc accepted it")
+	}
+	return false, reasonIfChanged(result.ExitCode == 0, false, "SuccessFunc rejected it")
+}
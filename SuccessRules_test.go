@@ -0,0 +1,166 @@
+package genericexec
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGenericExecManager_StderrPatterns(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"zero-exit-with-error": {
+			Name:                 "zero-exit-with-error",
+			Command:              "stderr",
+			Args:                 []string{"0", "{{request \"stderr\"}}"},
+			SuccessMessage:       "succeeded",
+			ErrorMessage:         "failed",
+			FailureStderrPattern: "^ERROR",
+			Reentrant:            true,
+		},
+		"benign-nonzero-exit": {
+			Name:                 "benign-nonzero-exit",
+			Command:              "stderr",
+			Args:                 []string{"1", "{{request \"stderr\"}}"},
+			SuccessMessage:       "succeeded",
+			ErrorMessage:         "failed",
+			SuccessStderrPattern: "already up to date",
+			FailureStderrPattern: "^ERROR",
+			Reentrant:            true,
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+
+	cases := []struct {
+		taskName  string
+		stderr    string
+		exitCode  int
+		succeeded bool
+		log       string
+	}{
+		{"zero-exit-with-error", "ERROR: could not connect", 0, false, "exited 0, but counts as failure because StdErr matched FailureStderrPattern!"},
+		{"zero-exit-with-error", "warning: slow network", 0, true, "exited 0."},
+		{"benign-nonzero-exit", "already up to date", 1, true, "exited 1, which counts as success because StdErr matched SuccessStderrPattern."},
+		{"benign-nonzero-exit", "ERROR: already up to date", 1, false, "exited 1!"},
+		{"benign-nonzero-exit", "disk full", 1, false, "exited 1!"},
+	}
+	for _, testCase := range cases {
+		testLogBuf.Reset()
+		result := <-sut.RunTask(testCase.taskName, url.Values{"stderr": []string{testCase.stderr}})
+		if result.ExitCode != testCase.exitCode || result.Succeeded != testCase.succeeded {
+			t.Errorf("Expected task %s with StdErr \"%s\" to exit %d with Succeeded %v, got %+v", testCase.taskName, testCase.stderr, testCase.exitCode, testCase.succeeded, result)
+		}
+		expectMessage := "failed"
+		if testCase.succeeded {
+			expectMessage = "succeeded"
+		}
+		if result.Message != expectMessage {
+			t.Errorf("Expected message \"%s\", got \"%s\"", expectMessage, result.Message)
+		}
+		if !strings.Contains(testLogBuf.String(), testCase.log) {
+			t.Errorf("Expected the log to contain \"%s\", got \"%s\"", testCase.log, testLogBuf.String())
+		}
+	}
+}
+
+func TestValidateConfigs_StderrPatterns(t *testing.T) {
+	err := ValidateConfigs(map[string]GenericExecConfig{
+		"test": {Name: "test", Command: "test", FailureStderrPattern: "(unclosed"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "task \"test\"") {
+		t.Errorf("Expected an invalid pattern to fail validation, got %v", err)
+	}
+}
+
+func TestGenericExecManager_SuccessFunc(t *testing.T) {
+	type report struct {
+		Healthy bool
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"check": {
+			Name:           "check",
+			Command:        "exit",
+			Args:           []string{"{{request \"code\"}}", "{{request \"report\"}}"},
+			SuccessMessage: "healthy",
+			ErrorMessage:   "unhealthy",
+			Reentrant:      true,
+			Parser: func(stdout, stderr string) (interface{}, error) {
+				var parsed report
+				err := json.Unmarshal([]byte(stdout), &parsed)
+				return parsed, err
+			},
+			SuccessFunc: func(result GenericExecResult) bool {
+				parsed, isReport := result.Parsed.(report)
+				return result.ParseError == nil && isReport && parsed.Healthy
+			},
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+
+	cases := []struct {
+		code      string
+		report    string
+		succeeded bool
+		log       string
+	}{
+		{"0", `{"Healthy": true}`, true, "exited 0."},
+		{"0", `{"Healthy": false}`, false, "exited 0, but counts as failure because SuccessFunc rejected it!"},
+		// Checks that find problems exit 1, but still report.
+		{"1", `{"Healthy": true}`, true, "exited 1, which counts as success because SuccessFunc accepted it."},
+		{"1", `not json`, false, "exited 1!"},
+	}
+	for _, testCase := range cases {
+		testLogBuf.Reset()
+		result := <-sut.RunTask("check", url.Values{"code": []string{testCase.code}, "report": []string{testCase.report}})
+		if result.Succeeded != testCase.succeeded {
+			t.Errorf("Expected Succeeded %v for exit %s with report %s, got %+v", testCase.succeeded, testCase.code, testCase.report, result)
+		}
+		expectMessage := "unhealthy"
+		if testCase.succeeded {
+			expectMessage = "healthy"
+		}
+		if result.Message != expectMessage {
+			t.Errorf("Expected message \"%s\", got \"%s\"", expectMessage, result.Message)
+		}
+		if !strings.Contains(testLogBuf.String(), testCase.log) {
+			t.Errorf("Expected the log to contain \"%s\", got \"%s\"", testCase.log, testLogBuf.String())
+		}
+	}
+	if stats := sut.Stats(); stats.Succeeded != 2 || stats.Failed != 2 {
+		t.Errorf("Expected Stats to count runs as SuccessFunc decided, got %+v", stats)
+	}
+}
+
+func TestGenericExecManager_MessagesByExitCode(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"backup": {
+			Name:           "backup",
+			Command:        "exit",
+			Args:           []string{"{{request \"code\"}}", "{{request \"output\"}}"},
+			SuccessMessage: "Backup done",
+			ErrorMessage:   "Backup failed: {{StdOut}}",
+			MessagesByExitCode: map[int]string{
+				1: "Backup target is offline; power it on and retry",
+				2: "Backup target is full ({{StdOut}}); delete old backups",
+			},
+			Reentrant: true,
+		},
+	}
+	sut, _, notifications := sutFactory(taskConfigs, nil)
+
+	cases := map[string]string{
+		"0": "Backup done",
+		"1": "Backup target is offline; power it on and retry",
+		"2": "Backup target is full (99%); delete old backups",
+		"3": "Backup failed: 99%",
+	}
+	for code, expect := range cases {
+		result := <-sut.RunTask("backup", url.Values{"code": []string{code}, "output": []string{"99%"}})
+		if result.Message != expect {
+			t.Errorf("Expected message \"%s\" for exit %s, got \"%s\"", expect, code, result.Message)
+		}
+	}
+	if len(**notifications) != len(cases) {
+		t.Errorf("Expected a notification per run, got %v", **notifications)
+	}
+}
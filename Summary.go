@@ -0,0 +1,40 @@
+package genericexec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary returns a one-line description of the result for people, such as "backup succeeded in 1.2s" or
+// "backup failed (exit 2) in 0.3s", followed by the result's Labels, if any, as in "[env=prod]". It never includes
+// the task's arguments or output, so it is as safe to show as the task's name and labels.
+func (result GenericExecResult) Summary() string {
+	var summary string
+	switch {
+	case result.Succeeded:
+		summary = fmt.Sprintf("%s succeeded in %s", result.Name, summaryDuration(result.ExecTime))
+	case result.FailureKind == FailureKindNone:
+		summary = fmt.Sprintf("%s failed (exit %d) in %s", result.Name, result.ExitCode, summaryDuration(result.ExecTime))
+	case result.FailureKind == FailureKindTimeout || result.FailureKind == FailureKindCancelled:
+		summary = fmt.Sprintf("%s %s after %s", result.Name, result.FailureKind, summaryDuration(result.ExecTime))
+	default:
+		summary = fmt.Sprintf("%s failed (%s)", result.Name, result.FailureKind)
+	}
+
+	if len(result.Labels) > 0 {
+		labels := make([]string, 0, len(result.Labels))
+		for key, value := range result.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		summary += " [" + strings.Join(labels, ", ") + "]"
+	}
+	return summary
+}
+
+// summaryDuration formats duration in seconds with one decimal place, which is as precise as people need.
+func summaryDuration(duration time.Duration) string {
+	return fmt.Sprintf("%.1fs", duration.Seconds())
+}
@@ -0,0 +1,57 @@
+package genericexec
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGenericExecResult_Summary(t *testing.T) {
+	testCases := []struct {
+		result GenericExecResult
+		expect string
+	}{
+		{GenericExecResult{Name: "foo", Succeeded: true, ExecTime: 1234 * time.Millisecond}, "foo succeeded in 1.2s"},
+		{GenericExecResult{Name: "foo", ExitCode: 2, ExecTime: 300 * time.Millisecond}, "foo failed (exit 2) in 0.3s"},
+		{GenericExecResult{Name: "foo", ExitCode: -1, FailureKind: FailureKindTimeout, ExecTime: 5 * time.Second}, "foo timed out after 5.0s"},
+		{GenericExecResult{Name: "foo", ExitCode: 1, FailureKind: FailureKindEnqueueTimeout}, "foo failed (rejected: queue full)"},
+		{GenericExecResult{Name: "foo", Succeeded: true, Labels: map[string]string{"team": "ops", "env": "prod"}}, "foo succeeded in 0.0s [env=prod, team=ops]"},
+	}
+	for _, testCase := range testCases {
+		if summary := testCase.result.Summary(); summary != testCase.expect {
+			t.Errorf("Expected summary \"%s\", got \"%s\"", testCase.expect, summary)
+		}
+	}
+}
+
+func TestGenericExecManager_Summary(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"login": {
+			Name:       "login",
+			Command:    "exit",
+			Args:       []string{"{{request \"code\"}}", "{{request \"password\"}}"},
+			Reentrant:  true,
+			SecretKeys: []string{"password"},
+			Labels:     map[string]string{"env": "test"},
+		},
+		"hang": {
+			Name:      "hang",
+			Command:   "hang",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("login", url.Values{"code": []string{"3"}, "password": []string{"s3cret"}})
+	if summary := result.Summary(); summary != "login failed (exit 3) in "+summaryDuration(result.ExecTime)+" [env=test]" {
+		t.Errorf("Expected a failure summary without the task's output, got \"%s\"", summary)
+	}
+
+	runContext, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	result = <-sut.RunTaskContext(runContext, "hang", url.Values{})
+	if summary := result.Summary(); summary != "hang timed out after "+summaryDuration(result.ExecTime) {
+		t.Errorf("Expected a timeout summary, got \"%s\"", summary)
+	}
+}
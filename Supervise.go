@@ -0,0 +1,107 @@
+package genericexec
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartBackoffMax = time.Minute
+)
+
+// SuperviseTask runs the named task, like RunTask, and then keeps starting it again as its Restart policy says,
+// for tasks meant to run continuously. Each run's result is sent to results, and the next run doesn't start until
+// the previous result has been received, so results must be read. The Restart policy and backoff are read again
+// after each run, so they follow ReplaceConfigs.
+//
+// stop ends supervision: it cancels the current run, if any, waits for it to exit, and closes results. Results not
+// yet received when stop is called may be dropped. Supervision also ends, closing results, once the policy doesn't
+// call for another run, the manager is shut down, or ReplaceConfigs has removed the task.
+func (ctx *GenericExecManager) SuperviseTask(taskName string, argValues TemplateGetter) (results <-chan GenericExecResult, stop func()) {
+	ctx.configMutex.RLock()
+	_, found := ctx.taskConfig(taskName)
+	ctx.configMutex.RUnlock()
+	if !found {
+		panic(fmt.Sprintf("No task configuration for task \"%s\"", taskName))
+	}
+
+	superviseContext, cancel := context.WithCancel(context.Background())
+	resultChan := make(chan GenericExecResult)
+	supervisorDone := make(chan struct{})
+	go func() {
+		defer close(supervisorDone)
+		defer close(resultChan)
+		var backoff time.Duration
+		for {
+			runResult, _ := ctx.submitRun(superviseContext, taskName, argValues, RunOptions{ifConfigured: true})
+			if runResult == nil {
+				ctx.logf(LogLevelError, "Stopped supervising task %s because the task is no longer configured.", taskName)
+				return
+			}
+			result := <-runResult
+			select {
+			case resultChan <- result:
+			case <-superviseContext.Done():
+				return
+			}
+			ctx.configMutex.RLock()
+			execConfig, found := ctx.taskConfig(taskName)
+			ctx.configMutex.RUnlock()
+			if !found {
+				ctx.logf(LogLevelError, "Stopped supervising task %s because the task is no longer configured.", taskName)
+				return
+			}
+			if superviseContext.Err() != nil || result.FailureKind == FailureKindShuttingDown || !shouldRestart(execConfig.Restart, result) {
+				return
+			}
+
+			initialBackoff, backoffMax := restartBackoff(execConfig)
+			if backoff == 0 || result.ExecTime >= backoffMax {
+				backoff = initialBackoff
+			}
+			ctx.logf(LogLevelWarn, "Restarting task %s in %v.", taskName, backoff)
+			restartTimer := time.NewTimer(backoff)
+			select {
+			case <-restartTimer.C:
+			case <-superviseContext.Done():
+				restartTimer.Stop()
+				return
+			}
+			backoff *= 2
+			if backoff > backoffMax {
+				backoff = backoffMax
+			}
+		}
+	}()
+
+	return resultChan, func() {
+		cancel()
+		<-supervisorDone
+	}
+}
+
+// restartBackoff returns execConfig's RestartBackoff and RestartBackoffMax, or their defaults.
+func restartBackoff(execConfig GenericExecConfig) (backoff time.Duration, backoffMax time.Duration) {
+	backoff, backoffMax = execConfig.RestartBackoff, execConfig.RestartBackoffMax
+	if backoff <= 0 {
+		backoff = defaultRestartBackoff
+	}
+	if backoffMax <= 0 {
+		backoffMax = defaultRestartBackoffMax
+	}
+	return backoff, backoffMax
+}
+
+// shouldRestart reports whether a supervised task should be started again after a run with result.
+func shouldRestart(restart string, result GenericExecResult) bool {
+	switch restart {
+	case "always":
+		return true
+	case "on-failure":
+		return !result.Succeeded
+	default:
+		return false
+	}
+}
@@ -0,0 +1,159 @@
+package genericexec
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_SuperviseTask(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"crashes": {
+			Name:           "crashes",
+			Command:        "exit",
+			Args:           []string{"1", "crashed"},
+			Reentrant:      true,
+			Restart:        "on-failure",
+			RestartBackoff: 10 * time.Millisecond,
+		},
+		"finishes": {
+			Name:      "finishes",
+			Command:   "exit",
+			Args:      []string{"0", "done"},
+			Reentrant: true,
+			Restart:   "on-failure",
+		},
+		"daemon": {
+			Name:      "daemon",
+			Command:   "reentrant-sleep",
+			Args:      []string{"5s"},
+			Reentrant: true,
+			Restart:   "always",
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Fatalf("Expected Restart policies to be valid, got %v", err)
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	results, stop := sut.SuperviseTask("crashes", url.Values{})
+	startedAt := time.Now()
+	for i := 0; i < 4; i++ {
+		if result := <-results; result.ExitCode != 1 || result.StdOut != "crashed" {
+			t.Errorf("Expected run %d to be the crashing command, got %+v", i, result)
+		}
+	}
+	// Restarts wait 10, 20 and then 40ms.
+	if elapsed := time.Since(startedAt); elapsed < 70*time.Millisecond {
+		t.Errorf("Expected restarts to back off, but 4 runs took only %v", elapsed)
+	}
+	stop()
+	for range results {
+	}
+	if sut.IsCommandBusy("exit") {
+		t.Error("Expected nothing to be running once stop returns")
+	}
+
+	results, stop = sut.SuperviseTask("finishes", url.Values{})
+	defer stop()
+	if result := <-results; !result.Succeeded {
+		t.Errorf("Expected the task to succeed, got %+v", result)
+	}
+	if _, more := <-results; more {
+		t.Error("Expected a task that succeeded not to be restarted on-failure")
+	}
+
+	results, stop = sut.SuperviseTask("daemon", url.Values{})
+	time.Sleep(100 * time.Millisecond)
+	stopStartedAt := time.Now()
+	stop()
+	if elapsed := time.Since(stopStartedAt); elapsed > 2*time.Second {
+		t.Errorf("Expected stop to cancel the running task, took %v", elapsed)
+	}
+	for result := range results {
+		if result.FailureKind != FailureKindCancelled {
+			t.Errorf("Expected only the cancelled run's result, got %+v", result)
+		}
+	}
+
+	taskConfigs["daemon"] = GenericExecConfig{Name: "daemon", Command: "reentrant-sleep", Restart: "sometimes"}
+	if err := ValidateConfigs(taskConfigs); err == nil {
+		t.Error("Expected an unknown Restart policy to be invalid")
+	}
+}
+
+func TestGenericExecManager_SuperviseTask_Removed(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"crashes": {
+			Name:           "crashes",
+			Command:        "exit",
+			Args:           []string{"1", "crashed"},
+			Reentrant:      true,
+			Restart:        "always",
+			RestartBackoff: 50 * time.Millisecond,
+		},
+	}
+	sut, testLogBuf, _ := sutFactory(taskConfigs, nil)
+
+	results, stop := sut.SuperviseTask("crashes", url.Values{})
+	defer stop()
+	<-results
+	if err := sut.ReplaceConfigs(map[string]GenericExecConfig{}); err != nil {
+		t.Fatalf("Unexpected error replacing configs: %v", err)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, isOpen := <-results:
+			if isOpen {
+				continue
+			}
+			if !strings.Contains(testLogBuf.String(), "Stopped supervising task crashes") {
+				t.Errorf("Expected the end of supervision to be logged, got \"%s\"", testLogBuf.String())
+			}
+			return
+		case <-deadline:
+			t.Fatal("Expected supervision to end once the task was removed")
+		}
+	}
+}
+
+func TestGenericExecManager_SuperviseTask_FollowsReplaceConfigs(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"crashes": {
+			Name:      "crashes",
+			Command:   "exit",
+			Args:      []string{"1", "crashed"},
+			Reentrant: true,
+			Restart:   "always",
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	results, stop := sut.SuperviseTask("crashes", url.Values{})
+	defer stop()
+	<-results
+	restartedAt := time.Now()
+	<-results
+	// With no RestartBackoff, the restart still waits the default second rather than spinning.
+	if elapsed := time.Since(restartedAt); elapsed < 500*time.Millisecond {
+		t.Errorf("Expected a default restart backoff, but the task restarted after %v", elapsed)
+	}
+
+	taskConfigs["crashes"] = GenericExecConfig{Name: "crashes", Command: "exit", Args: []string{"1", "crashed"}, Reentrant: true, Restart: "never"}
+	if err := sut.ReplaceConfigs(taskConfigs); err != nil {
+		t.Fatalf("Unexpected error replacing configs: %v", err)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, isOpen := <-results:
+			if !isOpen {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected supervision to end once the Restart policy was replaced with never")
+		}
+	}
+}
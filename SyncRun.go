@@ -0,0 +1,59 @@
+package genericexec
+
+import (
+	"context"
+)
+
+// RunTaskSync is like RunTaskWithOptions, but runs the task on the calling goroutine and returns its result once the
+// run is complete, instead of handing the run off to another goroutine. This suits command line tools, and tests that
+// shouldn't depend on goroutine scheduling. Runs of non-reentrant tasks still take turns with all other runs of their
+// Command, and wait while it is paused, but they wait for their turn on a lock instead of in the queue: they don't
+// count against the queue's capacity or EnqueueTimeout, and may start ahead of runs that were already queued. A run
+// whose runContext is done while it waits fails as cancelled or timed out without being started. The process
+// itself, and callbacks made asynchronous with ObserverQueueSize, still use goroutines of their own.
+func (ctx *GenericExecManager) RunTaskSync(runContext context.Context, taskName string, argValues TemplateGetter, options RunOptions) GenericExecResult {
+	options.inline = true
+	resultChan, run := ctx.submitRun(runContext, taskName, argValues, options)
+	if run != nil {
+		ctx.runInline(run)
+	}
+	return <-resultChan
+}
+
+// runInline runs a run submitted for RunTaskSync on the calling goroutine, once it may start.
+func (ctx *GenericExecManager) runInline(run *taskRun) {
+	execConfig := run.execTaskConfig
+	switch {
+	case execConfig.Reentrant && execConfig.MaxConcurrent > 0:
+		ctx.doRunInTaskSlot(run)
+	case execConfig.Reentrant:
+		ctx.doRunRunRunDaDooRunRun(run)
+	default:
+		ctx.waitWhilePaused(execConfig.Command, run)
+		lock := ctx.commandLock(execConfig.Command)
+		select {
+		case lock <- struct{}{}:
+			defer func() { <-lock }()
+		case <-run.runContext.Done():
+			// The run fails as cancelled or timed out without being started.
+		}
+		ctx.doRunRunRunDaDooRunRun(run)
+	}
+}
+
+// commandLock returns the lock held while a run of a non-reentrant task with the given Command executes. It is a
+// channel with room for one, taken by sending to it and released by receiving, so that waiting for it can be given
+// up on when a run's context is done.
+func (ctx *GenericExecManager) commandLock(command string) chan struct{} {
+	ctx.commandLocksMutex.Lock()
+	defer ctx.commandLocksMutex.Unlock()
+	if ctx.commandLocks == nil {
+		ctx.commandLocks = make(map[string]chan struct{})
+	}
+	lock := ctx.commandLocks[command]
+	if lock == nil {
+		lock = make(chan struct{}, 1)
+		ctx.commandLocks[command] = lock
+	}
+	return lock
+}
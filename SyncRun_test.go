@@ -0,0 +1,142 @@
+package genericexec
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_RunTaskSync(t *testing.T) {
+	var startedInline bool
+	startOnCaller := func(cmd *exec.Cmd) (func() error, error) {
+		// The process is started on the test's own goroutine only if the run wasn't handed off to another.
+		stack := make([]byte, 64*1024)
+		stack = stack[:runtime.Stack(stack, false)]
+		startedInline = strings.Contains(string(stack), "TestGenericExecManager_RunTaskSync")
+		return startCmd(cmd)
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"queued": {
+			Name:         "queued",
+			Command:      "exit",
+			Args:         []string{"0", "queued"},
+			StartProcess: startOnCaller,
+		},
+		"reentrant": {
+			Name:         "reentrant",
+			Command:      "exit",
+			Args:         []string{"3", "reentrant"},
+			Reentrant:    true,
+			StartProcess: startOnCaller,
+		},
+		"limited": {
+			Name:          "limited",
+			Command:       "exit",
+			Args:          []string{"0", "limited"},
+			Reentrant:     true,
+			MaxConcurrent: 1,
+			StartProcess:  startOnCaller,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	var onResultCalled bool
+	sut.OnResult = func(result GenericExecResult) {
+		onResultCalled = true
+	}
+
+	for taskName, expect := range map[string]string{"queued": "queued", "reentrant": "reentrant", "limited": "limited"} {
+		startedInline, onResultCalled = false, false
+		result := sut.RunTaskSync(context.Background(), taskName, url.Values{}, RunOptions{})
+		if result.StdOut != expect || result.Name != taskName {
+			t.Errorf("Expected the result of task %s to be returned, got %+v", taskName, result)
+		}
+		if !startedInline {
+			t.Errorf("Expected task %s to be started on the calling goroutine", taskName)
+		}
+		if !onResultCalled {
+			t.Errorf("Expected OnResult to have been called for task %s before RunTaskSync returned", taskName)
+		}
+	}
+	if sut.IsCommandBusy("exit") {
+		t.Error("Expected nothing to be running once RunTaskSync returned")
+	}
+}
+
+func TestGenericExecManager_RunTaskSync_Serialized(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:    "slow",
+			Command: "timestamps",
+			Args:    []string{"300ms"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	// Whichever of the two runs gets the command first, they must not overlap.
+	asyncChan := sut.RunTask("slow", url.Values{})
+	syncResult := sut.RunTaskSync(context.Background(), "slow", url.Values{}, RunOptions{})
+	asyncResult := <-asyncChan
+
+	var intervals [2][2]int64
+	for i, result := range []GenericExecResult{syncResult, asyncResult} {
+		if _, err := fmt.Sscanf(result.StdOut, "%d %d", &intervals[i][0], &intervals[i][1]); err != nil {
+			t.Fatalf("Expected timestamps from run %d, got %+v", i, result)
+		}
+	}
+	if intervals[0][0] < intervals[1][1] && intervals[1][0] < intervals[0][1] {
+		t.Errorf("Expected a synchronous run of a non-reentrant task not to overlap a queued one, got %v", intervals)
+	}
+
+	sut.Shutdown()
+	if result := sut.RunTaskSync(context.Background(), "slow", url.Values{}, RunOptions{}); result.FailureKind != FailureKindShuttingDown {
+		t.Errorf("Expected RunTaskSync after Shutdown to fail without running, got %+v", result)
+	}
+}
+
+func TestGenericExecManager_RunTaskSync_CancelledWhileWaiting(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"slow": {
+			Name:    "slow",
+			Command: "sleep",
+			Args:    []string{"2s"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	// A synchronous run waiting for the command gives up when its context is done.
+	queuedChan := sut.RunTask("slow", url.Values{})
+	time.Sleep(100 * time.Millisecond)
+	waitContext, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	startedAt := time.Now()
+	result := sut.RunTaskSync(waitContext, "slow", url.Values{}, RunOptions{})
+	if result.FailureKind != FailureKindTimeout || time.Since(startedAt) > time.Second {
+		t.Errorf("Expected RunTaskSync to time out while waiting for the command, got %+v after %v", result, time.Since(startedAt))
+	}
+	<-queuedChan
+
+	// A queued run waiting behind a synchronous one is handed its result when it is cancelled.
+	syncDone := make(chan GenericExecResult)
+	go func() {
+		syncDone <- sut.RunTaskSync(context.Background(), "slow", url.Values{}, RunOptions{})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	queuedContext, cancelQueued := context.WithCancel(context.Background())
+	queuedChan = sut.RunTaskContext(queuedContext, "slow", url.Values{})
+	time.Sleep(100 * time.Millisecond)
+	cancelQueued()
+	select {
+	case result := <-queuedChan:
+		if result.FailureKind != FailureKindCancelled {
+			t.Errorf("Expected the queued run to be cancelled, got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the queued run to get its result while the synchronous run still held the command")
+	}
+	<-syncDone
+}
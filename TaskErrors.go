@@ -0,0 +1,57 @@
+package genericexec
+
+import (
+	"context"
+	"fmt"
+)
+
+// TaskError is the error RunTaskErr returns for a task that did not succeed. Result is the task's full result.
+type TaskError struct {
+	Result GenericExecResult
+	// cause is the context error matching a FailureKind of cancelled or timed out.
+	cause error
+	// sensitive keeps the task's output out of the error message, since error messages tend to end up in logs.
+	sensitive bool
+}
+
+func (err *TaskError) Error() string {
+	message := fmt.Sprintf("task \"%s\" failed", err.Result.Name)
+	if err.Result.FailureKind != FailureKindNone {
+		message += fmt.Sprintf(" (%s)", err.Result.FailureKind)
+	}
+	message += fmt.Sprintf(": exit code %d", err.Result.ExitCode)
+	if err.Result.StdErr != "" && !err.sensitive {
+		message += ": " + err.Result.StdErr
+	}
+	return message
+}
+
+// Unwrap returns context.Canceled for a task that was cancelled, including by CancelCommand, or
+// context.DeadlineExceeded for one that timed out, so that errors.Is can tell those apart from tasks that failed on
+// their own.
+func (err *TaskError) Unwrap() error {
+	return err.cause
+}
+
+// RunTaskErr runs the named task like RunTaskContext and waits for it, returning nil if it succeeded or a *TaskError
+// if it did not. This suits running tasks in golang.org/x/sync/errgroup: with errgroup.WithContext, the first task
+// to fail cancels the context the others were run with, stopping them.
+func (ctx *GenericExecManager) RunTaskErr(runContext context.Context, taskName string, argValues TemplateGetter) error {
+	result := <-ctx.RunTaskContext(runContext, taskName, argValues)
+	if result.Succeeded {
+		return nil
+	}
+	return ctx.taskError(result)
+}
+
+// taskError returns the *TaskError for result, which did not succeed.
+func (ctx *GenericExecManager) taskError(result GenericExecResult) *TaskError {
+	taskErr := &TaskError{Result: result, sensitive: result.sensitive}
+	switch result.FailureKind {
+	case FailureKindCancelled:
+		taskErr.cause = context.Canceled
+	case FailureKindTimeout:
+		taskErr.cause = context.DeadlineExceeded
+	}
+	return taskErr
+}
@@ -0,0 +1,95 @@
+package genericexec
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func TestGenericExecManager_RunTaskErr(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"succeeds": {
+			Name:      "succeeds",
+			Command:   "reentrant-sleep",
+			Args:      []string{"10ms"},
+			Reentrant: true,
+		},
+		"fails": {
+			Name:      "fails",
+			Command:   "stderr",
+			Args:      []string{"3", "no good"},
+			Reentrant: true,
+		},
+		"slow": {
+			Name:      "slow",
+			Command:   "sleep",
+			Args:      []string{"5s"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	var group errgroup.Group
+	var succeededErr error
+	group.Go(func() error {
+		succeededErr = sut.RunTaskErr(context.Background(), "succeeds", url.Values{})
+		return succeededErr
+	})
+	group.Go(func() error {
+		return sut.RunTaskErr(context.Background(), "fails", url.Values{})
+	})
+	err := group.Wait()
+
+	if succeededErr != nil {
+		t.Errorf("Expected no error from the succeeding task, got %v", succeededErr)
+	}
+	var taskErr *TaskError
+	if !errors.As(err, &taskErr) {
+		t.Fatalf("Expected the group to fail with a *TaskError, got %v", err)
+	}
+	if taskErr.Result.Name != "fails" || taskErr.Result.ExitCode != 3 {
+		t.Errorf("Expected the error to come from the failing task's result, got %+v", taskErr.Result)
+	}
+	if !strings.Contains(err.Error(), "exit code 3") || !strings.Contains(err.Error(), "no good") {
+		t.Errorf("Expected the error message to include the exit code and stderr, got \"%v\"", err)
+	}
+
+	// With a group context, the failure stops the rest of the group.
+	cancellingGroup, groupContext := errgroup.WithContext(context.Background())
+	var slowErr error
+	cancellingGroup.Go(func() error {
+		slowErr = sut.RunTaskErr(groupContext, "slow", url.Values{})
+		return slowErr
+	})
+	cancellingGroup.Go(func() error {
+		return sut.RunTaskErr(groupContext, "fails", url.Values{})
+	})
+	if err := cancellingGroup.Wait(); !errors.As(err, &taskErr) || taskErr.Result.Name != "fails" {
+		t.Errorf("Expected the group to fail with the failing task's error, got %v", err)
+	}
+	if !errors.Is(slowErr, context.Canceled) {
+		t.Errorf("Expected the failure to cancel the slow task, got %v", slowErr)
+	}
+}
+
+func TestGenericExecManager_RunTaskErr_Sensitive(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"secret": {
+			Name:      "Secret Task",
+			Command:   "stderr",
+			Args:      []string{"2", "hunter2"},
+			Reentrant: true,
+			Sensitive: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	err := sut.RunTaskErr(context.Background(), "secret", url.Values{})
+	if err == nil || strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), "exit code 2") {
+		t.Errorf("Expected the error for a sensitive task without its output, got \"%v\"", err)
+	}
+}
@@ -0,0 +1,30 @@
+package genericexec
+
+// taskSlotsFor returns the semaphore limiting concurrent runs of the task configured under taskName to max.
+func (ctx *GenericExecManager) taskSlotsFor(taskName string, max int) chan struct{} {
+	ctx.taskSlotsMutex.Lock()
+	defer ctx.taskSlotsMutex.Unlock()
+	if ctx.taskSlots == nil {
+		ctx.taskSlots = make(map[string]chan struct{})
+	}
+	// A task whose MaxConcurrent was changed by ReplaceConfigs gets a new semaphore. Runs holding a slot in the old
+	// one release it there.
+	slots := ctx.taskSlots[taskName]
+	if cap(slots) != max {
+		slots = make(chan struct{}, max)
+		ctx.taskSlots[taskName] = slots
+	}
+	return slots
+}
+
+// doRunInTaskSlot runs a reentrant task with MaxConcurrent once one of its slots is free, or right away if the run
+// is cancelled while it waits, so that it fails as cancelled.
+func (ctx *GenericExecManager) doRunInTaskSlot(run *taskRun) {
+	slots := ctx.taskSlotsFor(run.taskName, run.execTaskConfig.MaxConcurrent)
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-run.runContext.Done():
+	}
+	ctx.doRunRunRunDaDooRunRun(run)
+}
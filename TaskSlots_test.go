@@ -0,0 +1,100 @@
+package genericexec
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_MaxConcurrent(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"foo": {
+			Name:          "foo",
+			Command:       "timestamps",
+			Args:          []string{"300ms"},
+			Reentrant:     true,
+			MaxConcurrent: 2,
+		},
+		"sibling": {
+			Name:      "sibling",
+			Command:   "timestamps",
+			Args:      []string{"300ms"},
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	type interval struct {
+		start, end time.Time
+	}
+	var mutex sync.Mutex
+	intervals := map[string][]interval{}
+	var wg sync.WaitGroup
+	for _, taskName := range []string{"foo", "foo", "foo", "foo", "foo", "foo", "sibling", "sibling", "sibling", "sibling"} {
+		resultChan := sut.RunTask(taskName, url.Values{})
+		wg.Add(1)
+		go func(taskName string) {
+			defer wg.Done()
+			result := <-resultChan
+			var start, end int64
+			fmt.Sscanf(result.StdOut, "%d %d", &start, &end)
+			mutex.Lock()
+			defer mutex.Unlock()
+			intervals[taskName] = append(intervals[taskName], interval{start: time.Unix(0, start), end: time.Unix(0, end)})
+		}(taskName)
+	}
+	wg.Wait()
+
+	mostOverlapping := func(intervals []interval) int {
+		most := 0
+		for _, at := range intervals {
+			overlapping := 0
+			for _, other := range intervals {
+				if !other.start.After(at.start) && other.end.After(at.start) {
+					overlapping++
+				}
+			}
+			if overlapping > most {
+				most = overlapping
+			}
+		}
+		return most
+	}
+	if most := mostOverlapping(intervals["foo"]); most != 2 {
+		t.Errorf("Expected at most 2 runs of foo at once, got %d", most)
+	}
+	if most := mostOverlapping(intervals["sibling"]); most <= 2 {
+		t.Errorf("Expected runs of a sibling task with the same command not to be limited, but only %d ran at once", most)
+	}
+}
+
+func TestGenericExecManager_MaxConcurrent_SharedName(t *testing.T) {
+	// Neither task's Name, which ValidateConfigs doesn't check, should make it share the other's slots.
+	taskConfigs := map[string]GenericExecConfig{
+		"foo": {
+			Name:          "shared",
+			Command:       "timestamps",
+			Args:          []string{"300ms"},
+			Reentrant:     true,
+			MaxConcurrent: 1,
+		},
+		"bar": {
+			Name:          "shared",
+			Command:       "timestamps",
+			Args:          []string{"300ms"},
+			Reentrant:     true,
+			MaxConcurrent: 1,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	fooChan, barChan := sut.RunTask("foo", url.Values{}), sut.RunTask("bar", url.Values{})
+	var fooStart, fooEnd, barStart, barEnd int64
+	fmt.Sscanf((<-fooChan).StdOut, "%d %d", &fooStart, &fooEnd)
+	fmt.Sscanf((<-barChan).StdOut, "%d %d", &barStart, &barEnd)
+	if fooStart >= barEnd || barStart >= fooEnd {
+		t.Errorf("Expected foo and bar each to run in a slot of its own, but they took turns")
+	}
+}
@@ -0,0 +1,65 @@
+package genericexec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+var errRenderTimedOut = errors.New("template rendering timed out")
+
+// renderTimeout returns the TemplateRenderTimeout of the manager rendering a run's templates with values, if any.
+func renderTimeout(values TemplateGetter) time.Duration {
+	if wrapped, isWrapped := values.(*runGetter); isWrapped && wrapped.manager != nil {
+		return wrapped.manager.TemplateRenderTimeout
+	}
+	return 0
+}
+
+// executeTemplate renders tmpl with data. It ignores execution errors, leaving out whatever failed, but gives up
+// with an error if rendering takes longer than timeout, when timeout is positive.
+func executeTemplate(tmpl *template.Template, data interface{}, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		var outBuf bytes.Buffer
+		tmpl.Execute(&outBuf, data)
+		return outBuf.String(), nil
+	}
+
+	// A template can't be stopped from outside, so one that takes too long is left to finish on its own, rendering
+	// into a writer that fails once the time is up so that it ends sooner if it is writing anything.
+	rendered := make(chan string, 1)
+	outBuf := &deadlineWriter{deadline: time.Now().Add(timeout)}
+	go func() {
+		tmpl.Execute(outBuf, data)
+		rendered <- outBuf.buffer.String()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case output := <-rendered:
+		if outBuf.timedOut {
+			return "", fmt.Errorf("%w after %v", errRenderTimedOut, timeout)
+		}
+		return output, nil
+	case <-timer.C:
+		return "", fmt.Errorf("%w after %v", errRenderTimedOut, timeout)
+	}
+}
+
+// deadlineWriter collects a template's output until its deadline, and fails every write after it. It is only
+// written to by the goroutine executing the template.
+type deadlineWriter struct {
+	buffer   bytes.Buffer
+	deadline time.Time
+	timedOut bool
+}
+
+func (writer *deadlineWriter) Write(p []byte) (int, error) {
+	if time.Now().After(writer.deadline) {
+		writer.timedOut = true
+		return 0, errRenderTimedOut
+	}
+	return writer.buffer.Write(p)
+}
@@ -0,0 +1,64 @@
+package genericexec
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenericExecManager_TemplateRenderTimeout(t *testing.T) {
+	// Rendering this writes every value of x a billion times, so it only ends early because writes start failing.
+	pathological := `{{range requestAll "x"}}{{range requestAll "x"}}{{range requestAll "x"}}{{.}}{{end}}{{end}}{{end}}`
+	taskConfigs := map[string]GenericExecConfig{
+		"slow-args": {
+			Name:      "slow-args",
+			Command:   "echo",
+			Args:      []string{pathological},
+			Reentrant: true,
+		},
+		"slow-message": {
+			Name:           "slow-message",
+			Command:        "echo",
+			Args:           []string{"fine"},
+			SuccessMessage: pathological,
+			Reentrant:      true,
+		},
+		"quick": {
+			Name:           "quick",
+			Command:        "echo",
+			Args:           []string{`{{range requestAll "x"}}{{.}}{{end}}`},
+			SuccessMessage: "Echoed {{StdOut}}",
+			Reentrant:      true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	sut.TemplateRenderTimeout = 50 * time.Millisecond
+	values := url.Values{}
+	for i := 0; i < 1000; i++ {
+		values.Add("x", fmt.Sprint(i%10))
+	}
+
+	startedAt := time.Now()
+	result := <-sut.RunTask("slow-args", ValuesGetter(values))
+	if result.Succeeded || !strings.Contains(result.StdErr, "template rendering timed out after 50ms") {
+		t.Errorf("Expected the argument template to time out, got %+v", result)
+	}
+	result = <-sut.RunTask("slow-message", ValuesGetter(values))
+	if !result.Succeeded || !strings.Contains(result.Message, "template rendering timed out") {
+		t.Errorf("Expected the message template to time out, got %+v", result)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 2*time.Second {
+		t.Errorf("Expected rendering to give up promptly, took %v", elapsed)
+	}
+
+	result = <-sut.RunTask("quick", ValuesGetter{"x": []string{"a", "b"}})
+	if result.StdOut != "ab" || result.Message != "Echoed ab" {
+		t.Errorf("Expected templates that render in time to be unaffected, got %+v", result)
+	}
+
+	if _, err := RenderArgTemplates([]string{"{{request \"x\"}}"}, url.Values{"x": []string{"no budget"}}); err != nil {
+		t.Errorf("Expected no budget outside of a manager, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package genericexec
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+)
+
+// runGetter wraps the TemplateGetter for a task run, so that template functions rendered on the far side of
+// CmdFactory can still reach the manager that is running the task and details of the run.
+type runGetter struct {
+	TemplateGetter
+	manager       *GenericExecManager
+	correlationID string
+	labels        map[string]string
+	prevStdOut    string
+	data          interface{}
+	placeholders  bool
+	// now is the time "now" renders, read once so that every template of a run agrees on it.
+	now time.Time
+	// completedRun is the result of the run an OnSuccessCommand or OnFailureCommand follows.
+	completedRun *GenericExecResult
+	values       *RunValues
+	// fetchedSecrets is shared by all of a run's templates.
+	fetchedSecrets *fetchedSecrets
+	// commandArgs is set only on the copy of the getter that is passed to the CmdFactory with the task's own args,
+	// to those args. RenderArgTemplates stores them in renderedArgs once rendered, so that ArgPatterns can check them
+	// without rendering them again.
+	commandArgs  []string
+	renderedArgs *[]string
+}
+
+// forCommandArgs returns a copy of getter for passing to the CmdFactory with args, the task's own args, that records
+// them in rendered once RenderArgTemplates has rendered them.
+func (getter *runGetter) forCommandArgs(args []string, rendered *[]string) *runGetter {
+	copied := *getter
+	copied.commandArgs, copied.renderedArgs = args, rendered
+	return &copied
+}
+
+// recordRenderedArgs stores rendered, the rendering of args, for ArgPatterns, if values is the getter made with
+// forCommandArgs and args are all of the task's own args.
+func recordRenderedArgs(values TemplateGetter, args []string, rendered []string) {
+	wrapped, isWrapped := values.(*runGetter)
+	if !isWrapped || wrapped.renderedArgs == nil || len(args) != len(wrapped.commandArgs) {
+		return
+	}
+	*wrapped.renderedArgs = rendered
+}
+
+// requestValues returns the TemplateGetter the caller supplied, without any wrapping by the manager.
+func requestValues(values TemplateGetter) TemplateGetter {
+	if wrapped, isWrapped := values.(*runGetter); isWrapped {
+		return wrapped.TemplateGetter
+	}
+	return values
+}
+
+// templateData returns the value of dot for templates rendered with values.
+func templateData(values TemplateGetter) interface{} {
+	if wrapped, isWrapped := values.(*runGetter); isWrapped {
+		return wrapped.data
+	}
+	return nil
+}
+
+func argFuncMap(argValues TemplateGetter) template.FuncMap {
+	var manager *GenericExecManager
+	var correlationID string
+	var labels map[string]string
+	var prevStdOut string
+	var completedRun *GenericExecResult
+	var now time.Time
+	var values *RunValues
+	var fetched *fetchedSecrets
+	if wrapped, isWrapped := argValues.(*runGetter); isWrapped {
+		manager = wrapped.manager
+		correlationID = wrapped.correlationID
+		labels = wrapped.labels
+		prevStdOut = wrapped.prevStdOut
+		completedRun = wrapped.completedRun
+		now = wrapped.now
+		values = wrapped.values
+		fetched = wrapped.fetchedSecrets
+	}
+	argValues = requestValues(argValues)
+
+	funcMap := template.FuncMap{
+		"request":    argValues.Get,
+		"requestAll": requestAllFunc(argValues),
+		"json":       jsonFunc(argValues),
+		"env":        envFunc(manager),
+		"shquote":    ShellQuote,
+		"winquote":   WindowsQuote,
+		"default":    defaultFunc,
+		"correlation_id": func() string {
+			return correlationID
+		},
+		"label": func(key string) string {
+			return labels[key]
+		},
+		"prev_stdout": func() string {
+			return prevStdOut
+		},
+		"now":      nowFunc(now),
+		"value":    valueFunc(values),
+		"setValue": setValueFunc(values),
+		"secret":   secretFunc(manager, fetched),
+	}
+	if completedRun != nil {
+		funcMap["StdOut"] = func() string {
+			return completedRun.StdOut
+		}
+		funcMap["StdErr"] = func() string {
+			return completedRun.StdErr
+		}
+		funcMap["ExitCode"] = func() int {
+			return completedRun.ExitCode
+		}
+	}
+	return funcMap
+}
+
+// argListFuncMap returns the functions for rendering a list of arg templates with argValues: those of argFuncMap,
+// plus "arg", which finds the ones rendered earlier in renderedArgs.
+func argListFuncMap(argValues TemplateGetter, renderedArgs *[]string) template.FuncMap {
+	funcMap := argFuncMap(argValues)
+	funcMap["arg"] = priorArgFunc(renderedArgs)
+	return funcMap
+}
+
+// messageRunInfo is what message templates can find out about the run they are for, besides its output.
+type messageRunInfo struct {
+	duration time.Duration
+	exitCode int
+	pid      int
+}
+
+// messageFuncMap returns the functions for SuccessMessage and ErrorMessage templates: those for Args, plus StdOut,
+// StdErr and Output, and Duration, ExitCode and Pid of the run. Duration is how long the process ran, as in
+// {{Duration}} or {{Duration.Seconds}}, and Pid is 0 if the process never started.
+func messageFuncMap(values TemplateGetter, stdout string, stderr string, messageOutput string, run messageRunInfo) template.FuncMap {
+	funcMap := argFuncMap(values)
+	funcMap["Duration"] = func() time.Duration {
+		return run.duration
+	}
+	funcMap["ExitCode"] = func() int {
+		return run.exitCode
+	}
+	funcMap["Pid"] = func() int {
+		return run.pid
+	}
+	funcMap["StdOut"] = func() string {
+		return stdout
+	}
+	funcMap["StdErr"] = func() string {
+		return stderr
+	}
+	funcMap["Output"] = func() string {
+		return selectOutput(messageOutput, stdout, stderr)
+	}
+	return funcMap
+}
+
+// selectOutput returns the output a task's MessageOutput refers to.
+func selectOutput(messageOutput string, stdout string, stderr string) string {
+	switch messageOutput {
+	case "stderr":
+		return stderr
+	case "combined":
+		if stdout == "" || stderr == "" {
+			return stdout + stderr
+		}
+		return stdout + "\n" + stderr
+	default:
+		return stdout
+	}
+}
+
+// copyLabels returns a copy of a task's Labels for one of its runs, so that nothing done with one result's labels
+// can affect another's.
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+// defaultFunc returns value, or fallback when value is empty, so that templates can write
+// {{request "timeout" | default "30"}}.
+func defaultFunc(fallback string, value string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// priorArgFunc implements "arg" for Args templates: {{arg 0}} is the rendered value of the first arg. Args are
+// rendered in order, so only earlier args can be referenced; any other index renders as the empty string.
+func priorArgFunc(renderedArgs *[]string) func(int) string {
+	return func(index int) string {
+		if index < 0 || index >= len(*renderedArgs) {
+			return ""
+		}
+		return (*renderedArgs)[index]
+	}
+}
+
+// now returns the current time according to the manager's Clock.
+func (ctx *GenericExecManager) now() time.Time {
+	if ctx.Clock != nil {
+		return ctx.Clock()
+	}
+	return time.Now()
+}
+
+// nowFunc implements "now": {{now}} renders the time of the run in RFC 3339 format, and {{now "2006-01-02"}} in the
+// given time.Format layout. Outside of a run, at is zero and the time is read when rendering.
+func nowFunc(at time.Time) func(layout ...string) (string, error) {
+	return func(layout ...string) (string, error) {
+		renderedAt := at
+		if renderedAt.IsZero() {
+			renderedAt = time.Now()
+		}
+		switch len(layout) {
+		case 0:
+			return renderedAt.Format(time.RFC3339), nil
+		case 1:
+			return renderedAt.Format(layout[0]), nil
+		default:
+			return "", fmt.Errorf("now takes at most one layout, got %d", len(layout))
+		}
+	}
+}
+
+// envFunc implements "env", restricted to manager's TemplateEnvAllowlist. Templates rendered outside of a run have
+// no manager, and are unrestricted.
+func envFunc(manager *GenericExecManager) func(string) string {
+	return func(name string) string {
+		if manager != nil && manager.TemplateEnvAllowlist != nil {
+			allowed := false
+			for _, allowedName := range manager.TemplateEnvAllowlist {
+				allowed = allowed || allowedName == name
+			}
+			if !allowed {
+				return ""
+			}
+		}
+		return os.Getenv(name)
+	}
+}
@@ -0,0 +1,257 @@
+package genericexec
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnvTemplateFunction(t *testing.T) {
+	os.Setenv("GENERICEXEC_TEST_SECRET", "s3cret")
+	defer os.Unsetenv("GENERICEXEC_TEST_SECRET")
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "args",
+			Args:           []string{"--path={{env \"PATH\"}}", "--secret={{env \"GENERICEXEC_TEST_SECRET\"}}"},
+			SuccessMessage: "{{env \"GENERICEXEC_TEST_SECRET\"}}",
+			Reentrant:      true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{})
+	if expect := `["--path=` + os.Getenv("PATH") + `" "--secret=s3cret"]`; result.StdOut != expect {
+		t.Errorf("Expected args %s, got %s", expect, result.StdOut)
+	}
+	if result.Message != "s3cret" {
+		t.Errorf("Expected env to be available to messages, got \"%s\"", result.Message)
+	}
+
+	sut.TemplateEnvAllowlist = []string{"PATH"}
+	result = <-sut.RunTask("test", url.Values{})
+	if expect := `["--path=` + os.Getenv("PATH") + `" "--secret="]`; result.StdOut != expect {
+		t.Errorf("Expected args %s with the secret outside the allowlist, got %s", expect, result.StdOut)
+	}
+	if result.Message != "" {
+		t.Errorf("Expected the secret outside the allowlist to render empty in messages, got \"%s\"", result.Message)
+	}
+}
+
+func TestDefaultTemplateFunction(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "args",
+			Args:           []string{"--timeout={{request \"timeout\" | default \"30\"}}", "--retries={{default \"3\" (request \"retries\")}}"},
+			SuccessMessage: "Waited up to {{request \"timeout\" | default \"30\"}}s",
+			Reentrant:      true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{})
+	if expect := `["--timeout=30" "--retries=3"]`; result.StdOut != expect {
+		t.Errorf("Expected defaults for absent keys %s, got %s", expect, result.StdOut)
+	}
+	if result.Message != "Waited up to 30s" {
+		t.Errorf("Expected default to be available to messages, got \"%s\"", result.Message)
+	}
+
+	result = <-sut.RunTask("test", url.Values{"timeout": []string{"5"}, "retries": []string{"0"}})
+	if expect := `["--timeout=5" "--retries=0"]`; result.StdOut != expect {
+		t.Errorf("Expected present keys to be used %s, got %s", expect, result.StdOut)
+	}
+	if result.Message != "Waited up to 5s" {
+		t.Errorf("Expected a present key to be used in messages, got \"%s\"", result.Message)
+	}
+}
+
+func TestOutputTemplateFunction(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"stdout": {
+			Name:           "stdout",
+			Command:        "echo",
+			Args:           []string{"on stdout"},
+			SuccessMessage: "Said: {{Output}}",
+			Reentrant:      true,
+		},
+		"stderr": {
+			Name:           "stderr",
+			Command:        "stderr",
+			Args:           []string{"0", "on stderr"},
+			SuccessMessage: "Said: {{Output}}",
+			MessageOutput:  "stderr",
+			Reentrant:      true,
+		},
+		"combined": {
+			Name:           "combined",
+			Command:        "stderr",
+			Args:           []string{"0", "on stderr"},
+			SuccessMessage: "Said: {{Output}}",
+			MessageOutput:  "combined",
+			Reentrant:      true,
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Errorf("Expected MessageOutput settings to be valid, got %v", err)
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	for taskName, expect := range map[string]string{"stdout": "Said: on stdout", "stderr": "Said: on stderr", "combined": "Said: on stderr"} {
+		if result := <-sut.RunTask(taskName, url.Values{}); result.Message != expect {
+			t.Errorf("Expected task %s to render \"%s\", got \"%s\"", taskName, expect, result.Message)
+		}
+	}
+	if output := selectOutput("combined", "out", "err"); output != "out\nerr" {
+		t.Errorf("Expected combined output to be stdout then stderr, got %q", output)
+	}
+
+	taskConfigs["stderr"] = GenericExecConfig{Name: "stderr", Command: "stderr", MessageOutput: "both"}
+	if err := ValidateConfigs(taskConfigs); err == nil {
+		t.Error("Expected an unknown MessageOutput to be invalid")
+	}
+}
+
+func TestArgTemplateFunction(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "args",
+			Args:      []string{"{{request \"name\" | default \"build\"}}", "{{arg 2}}", "--log=/var/log/{{arg 0}}.log", "{{arg 3}}{{arg -1}}{{arg 9}}"},
+			Reentrant: true,
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Errorf("Expected args using arg to be valid, got %v", err)
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"name": []string{"nightly"}})
+	if expect := `["nightly" "" "--log=/var/log/nightly.log" ""]`; result.StdOut != expect {
+		t.Errorf("Expected args %s, got %s", expect, result.StdOut)
+	}
+
+	// Argv0 and Stdin are rendered like args, so they can use arg too, though there is nothing before them.
+	taskConfigs["rendered-alone"] = GenericExecConfig{
+		Name:      "rendered-alone",
+		Command:   "countstdin",
+		Argv0:     "{{arg 0}}countstdin",
+		Stdin:     "{{arg 0}}abc",
+		Reentrant: true,
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Errorf("Expected Argv0 and Stdin using arg to be valid, got %v", err)
+	}
+	sut, _, _ = sutFactory(taskConfigs, nil)
+	if result := <-sut.RunTask("rendered-alone", url.Values{}); !result.Succeeded || result.StdInSent != "abc" {
+		t.Errorf("Expected Argv0 and Stdin using arg to render, got %+v", result)
+	}
+}
+
+func TestRunInfoMessageFunctions(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:         "test",
+			Command:      "exit",
+			Args:         []string{"{{request \"code\"}}"},
+			ErrorMessage: "{{if gt Duration.Nanoseconds 0}}ran{{end}} with code {{ExitCode}} as pid {{Pid}}",
+			Reentrant:    true,
+		},
+	}
+	if err := ValidateConfigs(taskConfigs); err != nil {
+		t.Fatalf("Expected the run info functions to be valid in messages, got %v", err)
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{"code": []string{"3"}})
+	var pid int
+	if _, err := fmt.Sscanf(result.Message, "ran with code 3 as pid %d", &pid); err != nil || pid <= 0 {
+		t.Errorf("Expected the message to have the duration, exit code and pid, got \"%s\"", result.Message)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "args",
+			Args:           []string{"{{label \"environment\"}}"},
+			SuccessMessage: "[{{label \"severity\"}}] deployed for {{label \"team\"}}{{label \"missing\"}}",
+			Reentrant:      true,
+			Labels:         map[string]string{"team": "payments", "severity": "low", "environment": "staging"},
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", url.Values{})
+	if result.Labels["team"] != "payments" || len(result.Labels) != 3 {
+		t.Errorf("Expected the task's labels on the result, got %v", result.Labels)
+	}
+	if result.Message != "[low] deployed for payments" {
+		t.Errorf("Expected labels to render in the message, got \"%s\"", result.Message)
+	}
+	if result.StdOut != `["staging"]` {
+		t.Errorf("Expected labels to render in args, got %s", result.StdOut)
+	}
+
+	result.Labels["team"] = "changed"
+	result = <-sut.RunTask("test", url.Values{})
+	if result.Labels["team"] != "payments" {
+		t.Errorf("Expected changing one result's labels not to affect later runs, got %v", result.Labels)
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	type deployment struct {
+		Region string
+		Tags   []string
+	}
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "args",
+			Args:           []string{"--region={{.Region}}", "--version={{request \"version\"}}", "{{range .Tags}}{{.}},{{end}}"},
+			SuccessMessage: "Deployed {{request \"version\"}} to {{.Region}}",
+			Reentrant:      true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	data := deployment{Region: "eu-west-1", Tags: []string{"canary", "blue"}}
+	result := <-sut.RunTaskWithOptions(context.Background(), "test", url.Values{"version": []string{"v3"}}, RunOptions{TemplateData: data})
+	if expect := `["--region=eu-west-1" "--version=v3" "canary,blue,"]`; result.StdOut != expect {
+		t.Errorf("Expected args %s, got %s", expect, result.StdOut)
+	}
+	if result.Message != "Deployed v3 to eu-west-1" {
+		t.Errorf("Expected TemplateData in the message, got \"%s\"", result.Message)
+	}
+}
+
+func TestNowTemplateFunction(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"backup": {
+			Name:           "backup",
+			Command:        "args",
+			Args:           []string{"--out=backup-{{now \"2006-01-02\"}}.tar", "--at={{now}}"},
+			SuccessMessage: "backed up at {{now \"15:04\"}}",
+			Reentrant:      true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	fixed := time.Date(2024, time.February, 29, 23, 59, 58, 0, time.UTC)
+	sut.Clock = func() time.Time {
+		return fixed
+	}
+
+	result := <-sut.RunTask("backup", url.Values{})
+	if expect := `["--out=backup-2024-02-29.tar" "--at=2024-02-29T23:59:58Z"]`; result.StdOut != expect {
+		t.Errorf("Expected args %s, got %s", expect, result.StdOut)
+	}
+	if result.Message != "backed up at 23:59" {
+		t.Errorf("Expected now to be available to messages, got \"%s\"", result.Message)
+	}
+}
@@ -0,0 +1,163 @@
+package genericexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MultiValueTemplateGetter is implemented by TemplateGetters that can hold more than one value per key.
+// Templates can read all of a key's values with "requestAll", for example
+// {{range requestAll "host"}}{{.}} {{end}}.
+type MultiValueTemplateGetter interface {
+	TemplateGetter
+	GetAll(string) []string
+}
+
+// MapGetter is a TemplateGetter backed by a map. Missing keys get the empty string.
+type MapGetter map[string]string
+
+func (values MapGetter) Get(key string) string {
+	return values[key]
+}
+
+func (values MapGetter) GetAll(key string) []string {
+	if value, found := values[key]; found {
+		return []string{value}
+	}
+	return nil
+}
+
+// ValuesGetter is a TemplateGetter backed by url.Values, such as parsed query parameters or form data.
+// Get returns the first value for a key; GetAll returns all of them. Missing keys get the empty string or nil.
+type ValuesGetter url.Values
+
+func (values ValuesGetter) Get(key string) string {
+	return url.Values(values).Get(key)
+}
+
+func (values ValuesGetter) GetAll(key string) []string {
+	return values[key]
+}
+
+// defaultsGetter backs a request's TemplateGetter with a task's Defaults, for keys the request has no value for.
+// It passes through the request getter's other capabilities.
+type defaultsGetter struct {
+	TemplateGetter
+	defaults map[string]string
+}
+
+func (values *defaultsGetter) Get(key string) string {
+	if value := values.TemplateGetter.Get(key); value != "" {
+		return value
+	}
+	return values.defaults[key]
+}
+
+func (values *defaultsGetter) GetAll(key string) []string {
+	if all := requestAllFunc(values.TemplateGetter)(key); len(all) > 0 {
+		return all
+	}
+	if value, found := values.defaults[key]; found {
+		return []string{value}
+	}
+	return nil
+}
+
+func (values *defaultsGetter) GetJSON(path string) interface{} {
+	if jsonValues, isJSON := values.TemplateGetter.(JSONTemplateGetter); isJSON {
+		return jsonValues.GetJSON(path)
+	}
+	return nil
+}
+
+func requestAllFunc(values TemplateGetter) func(string) []string {
+	return func(key string) []string {
+		if multiValues, isMulti := values.(MultiValueTemplateGetter); isMulti {
+			return multiValues.GetAll(key)
+		}
+		if value := values.Get(key); value != "" {
+			return []string{value}
+		}
+		return nil
+	}
+}
+
+// JSONTemplateGetter is implemented by TemplateGetters backed by a structured document. Templates can read nested
+// values with "json", for example {{json "server.ports.0"}}.
+type JSONTemplateGetter interface {
+	TemplateGetter
+	GetJSON(path string) interface{}
+}
+
+// JSONGetter is a TemplateGetter backed by a parsed JSON document.
+//
+// Paths are dot-separated object keys, with numeric path elements indexing into arrays, so "a.b.0.c" is the "c"
+// key of the first element of the array at "a.b". A path that does not exist, including an out-of-range array
+// index, has no value. Get and the "json" template function render strings as-is, other scalars as JSON, objects
+// and arrays as compact JSON text, and missing values as the empty string.
+type JSONGetter struct {
+	document interface{}
+}
+
+func NewJSONGetter(document []byte) (*JSONGetter, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	getter := JSONGetter{}
+	if err := decoder.Decode(&getter.document); err != nil {
+		return nil, err
+	}
+	return &getter, nil
+}
+
+// GetJSON returns the decoded value at path, or nil if there is none. Objects are map[string]interface{}, arrays are
+// []interface{}, and numbers are json.Number.
+func (getter *JSONGetter) GetJSON(path string) interface{} {
+	value := getter.document
+	if path == "" {
+		return value
+	}
+	for _, element := range strings.Split(path, ".") {
+		switch container := value.(type) {
+		case map[string]interface{}:
+			value = container[element]
+		case []interface{}:
+			index, err := strconv.Atoi(element)
+			if err != nil || index < 0 || index >= len(container) {
+				return nil
+			}
+			value = container[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+func (getter *JSONGetter) Get(path string) string {
+	return jsonValueString(getter.GetJSON(path))
+}
+
+func jsonValueString(value interface{}) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typedValue
+	case json.Number:
+		return typedValue.String()
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func jsonFunc(values TemplateGetter) func(string) string {
+	return func(path string) string {
+		if jsonValues, isJSON := values.(JSONTemplateGetter); isJSON {
+			return jsonValueString(jsonValues.GetJSON(path))
+		}
+		return ""
+	}
+}
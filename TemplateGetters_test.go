@@ -0,0 +1,133 @@
+package genericexec
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestMapGetter(t *testing.T) {
+	getter := MapGetter{"present": "value"}
+	if getter.Get("present") != "value" {
+		t.Errorf("Expected \"value\", got \"%s\"", getter.Get("present"))
+	}
+	if getter.Get("missing") != "" {
+		t.Errorf("Expected an empty string for a missing key, got \"%s\"", getter.Get("missing"))
+	}
+	if all := getter.GetAll("present"); !reflect.DeepEqual(all, []string{"value"}) {
+		t.Errorf("Expected [value], got %v", all)
+	}
+	if all := getter.GetAll("missing"); all != nil {
+		t.Errorf("Expected nil for a missing key, got %v", all)
+	}
+}
+
+func TestValuesGetter(t *testing.T) {
+	getter := ValuesGetter(url.Values{"host": []string{"a", "b", "c"}})
+	if getter.Get("host") != "a" {
+		t.Errorf("Expected the first value \"a\", got \"%s\"", getter.Get("host"))
+	}
+	if getter.Get("missing") != "" {
+		t.Errorf("Expected an empty string for a missing key, got \"%s\"", getter.Get("missing"))
+	}
+	if all := getter.GetAll("host"); !reflect.DeepEqual(all, []string{"a", "b", "c"}) {
+		t.Errorf("Expected [a b c], got %v", all)
+	}
+	if all := getter.GetAll("missing"); all != nil {
+		t.Errorf("Expected nil for a missing key, got %v", all)
+	}
+}
+
+func TestRequestAllTemplateFunction(t *testing.T) {
+	template := `{{range requestAll "host"}}<{{.}}>{{end}}`
+	cases := []struct {
+		getter TemplateGetter
+		expect string
+	}{
+		{ValuesGetter(url.Values{"host": []string{"a", "b"}}), "<a><b>"},
+		{MapGetter{"host": "a"}, "<a>"},
+		{url.Values{"host": []string{"a", "b"}}, "<a>"},
+		{MapGetter{}, ""},
+	}
+	for _, testCase := range cases {
+		rendered, err := RenderArgTemplates([]string{template}, testCase.getter)
+		if err != nil {
+			t.Fatalf("Unexpected error rendering template: %v", err)
+		}
+		if rendered[0] != testCase.expect {
+			t.Errorf("Expected \"%s\" from %T, got \"%s\"", testCase.expect, testCase.getter, rendered[0])
+		}
+	}
+}
+
+func TestJSONGetter(t *testing.T) {
+	getter, err := NewJSONGetter([]byte(`{"server": {"name": "web", "ports": [80, 443], "tls": true, "tags": {"a": "b"}}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing JSON: %v", err)
+	}
+
+	cases := map[string]string{
+		"server.name":      "web",
+		"server.ports.1":   "443",
+		"server.ports":     "[80,443]",
+		"server.tls":       "true",
+		"server.tags":      `{"a":"b"}`,
+		"server.missing":   "",
+		"server.ports.2":   "",
+		"server.ports.x":   "",
+		"server.name.deep": "",
+	}
+	for path, expect := range cases {
+		if value := getter.Get(path); value != expect {
+			t.Errorf("Expected \"%s\" at path %s, got \"%s\"", expect, path, value)
+		}
+	}
+	if getter.GetJSON("server.missing") != nil {
+		t.Error("Expected GetJSON to return nil for a missing path")
+	}
+
+	rendered, err := RenderArgTemplates([]string{`--name={{json "server.name"}}`, `--port={{json "server.ports.0"}}`}, getter)
+	if err != nil {
+		t.Fatalf("Unexpected error rendering template: %v", err)
+	}
+	if !reflect.DeepEqual(rendered, []string{"--name=web", "--port=80"}) {
+		t.Errorf("Expected nested values in the rendered args, got %v", rendered)
+	}
+
+	// Getters that aren't backed by JSON have no nested values.
+	rendered, _ = RenderArgTemplates([]string{`[{{json "server.name"}}]`}, MapGetter{"server.name": "web"})
+	if rendered[0] != "[]" {
+		t.Errorf("Expected json to render empty for a non-JSON getter, got %s", rendered[0])
+	}
+
+	if _, err := NewJSONGetter([]byte(`{"unterminated": `)); err == nil {
+		t.Error("Expected an error parsing invalid JSON")
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"test": {
+			Name:           "test",
+			Command:        "args",
+			Args:           []string{"--region={{request \"region\"}}", "--zone={{request \"zone\"}}", "{{range requestAll \"tag\"}}{{.}}{{end}}"},
+			Defaults:       map[string]string{"region": "us-east-1", "tag": "default-tag"},
+			SuccessMessage: "Deployed to {{request \"region\"}}",
+			Reentrant:      true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+
+	result := <-sut.RunTask("test", ValuesGetter{"zone": []string{"b"}})
+	if expect := `["--region=us-east-1" "--zone=b" "default-tag"]`; result.StdOut != expect {
+		t.Errorf("Expected absent keys to come from Defaults, %s, got %s", expect, result.StdOut)
+	}
+	if result.Message != "Deployed to us-east-1" {
+		t.Errorf("Expected Defaults in messages too, got \"%s\"", result.Message)
+	}
+
+	result = <-sut.RunTask("test", ValuesGetter{"region": []string{"eu-west-1"}, "tag": []string{"a", "b"}})
+	if expect := `["--region=eu-west-1" "--zone=" "ab"]`; result.StdOut != expect {
+		t.Errorf("Expected request values to take precedence over Defaults, %s, got %s", expect, result.StdOut)
+	}
+}
@@ -0,0 +1,34 @@
+package genericexec
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DecodeError is the error RunTaskInto returns when a task succeeded but its output could not be decoded.
+type DecodeError struct {
+	TaskName string
+	Err      error
+}
+
+func (err *DecodeError) Error() string {
+	return fmt.Sprintf("task \"%s\" succeeded, but its output could not be decoded: %v", err.TaskName, err.Err)
+}
+
+func (err *DecodeError) Unwrap() error {
+	return err.Err
+}
+
+// RunTaskInto runs the named task with manager, waits for it, and if it succeeds, decodes the JSON it wrote to
+// stdout into out. It returns the task's result along with a *TaskError if the task did not succeed, in which case
+// out is left alone, or a *DecodeError if its output was not JSON that fits out.
+func RunTaskInto[T any](manager *GenericExecManager, taskName string, argValues TemplateGetter, out *T) (GenericExecResult, error) {
+	result := <-manager.RunTask(taskName, argValues)
+	if !result.Succeeded {
+		return result, manager.taskError(result)
+	}
+	if err := json.Unmarshal(result.StdOutBytes, out); err != nil {
+		return result, &DecodeError{TaskName: taskName, Err: err}
+	}
+	return result, nil
+}
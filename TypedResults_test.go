@@ -0,0 +1,53 @@
+package genericexec
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestRunTaskInto(t *testing.T) {
+	taskConfigs := map[string]GenericExecConfig{
+		"json": {
+			Name:      "json",
+			Command:   "echo",
+			Args:      []string{`{"name": "{{request "name"}}", "replicas": 3, "tags": ["a", "b"]}`},
+			Reentrant: true,
+		},
+		"text": {
+			Name:      "text",
+			Command:   "echo",
+			Args:      []string{"not json"},
+			Reentrant: true,
+		},
+		"fails": {
+			Name:      "fails",
+			Command:   "fail",
+			Reentrant: true,
+		},
+	}
+	sut, _, _ := sutFactory(taskConfigs, nil)
+	type deployment struct {
+		Name     string
+		Replicas int
+		Tags     []string
+	}
+
+	var decoded deployment
+	result, err := RunTaskInto(sut, "json", url.Values{"name": []string{"web"}}, &decoded)
+	if err != nil || !result.Succeeded {
+		t.Fatalf("Expected the output to decode, got %v for %+v", err, result)
+	}
+	if decoded.Name != "web" || decoded.Replicas != 3 || len(decoded.Tags) != 2 || decoded.Tags[1] != "b" {
+		t.Errorf("Expected the output decoded into the struct, got %+v", decoded)
+	}
+
+	var decodeErr *DecodeError
+	if _, err := RunTaskInto(sut, "text", url.Values{}, &decoded); !errors.As(err, &decodeErr) || decodeErr.TaskName != "text" {
+		t.Errorf("Expected a *DecodeError for output that isn't JSON, got %v", err)
+	}
+	var taskErr *TaskError
+	if result, err := RunTaskInto(sut, "fails", url.Values{}, &decoded); !errors.As(err, &taskErr) || errors.As(err, &decodeErr) || result.ExitCode != 2 {
+		t.Errorf("Expected a *TaskError and the result for a failed task, got %v for %+v", err, result)
+	}
+}
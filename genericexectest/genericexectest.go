@@ -0,0 +1,33 @@
+// Package genericexectest provides a stand-in for a genericexec manager, so that code running tasks through
+// genericexec.GenericExecManagerInterface can be tested without running any commands.
+package genericexectest
+
+import (
+	"fmt"
+
+	"github.com/mbaynton/go-genericexec"
+)
+
+type testManager struct {
+	results map[string]genericexec.GenericExecResult
+}
+
+// NewTestManager returns a GenericExecManagerInterface whose RunTask immediately delivers the result given for the
+// task's name, with Name filled in if it is empty. Like the real manager, it panics for a task it has no result for.
+func NewTestManager(results map[string]genericexec.GenericExecResult) genericexec.GenericExecManagerInterface {
+	return &testManager{results: results}
+}
+
+func (manager *testManager) RunTask(taskName string, getter genericexec.TemplateGetter) <-chan genericexec.GenericExecResult {
+	result, found := manager.results[taskName]
+	if !found {
+		panic(fmt.Sprintf("No task configuration for task \"%s\"", taskName))
+	}
+	if result.Name == "" {
+		result.Name = taskName
+	}
+	resultChan := make(chan genericexec.GenericExecResult, 1)
+	resultChan <- result
+	close(resultChan)
+	return resultChan
+}
@@ -0,0 +1,32 @@
+package genericexectest
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/mbaynton/go-genericexec"
+)
+
+// deploy stands in for application code that runs a task and acts on the result.
+func deploy(manager genericexec.GenericExecManagerInterface, version string) string {
+	result := <-manager.RunTask("deploy", url.Values{"version": []string{version}})
+	if result.ExitCode != 0 {
+		return "deploy failed: " + result.StdErr
+	}
+	return "deployed " + result.StdOut
+}
+
+func ExampleNewTestManager() {
+	manager := NewTestManager(map[string]genericexec.GenericExecResult{
+		"deploy": {ExitCode: 0, StdOut: "v1.2.3"},
+	})
+	fmt.Println(deploy(manager, "v1.2.3"))
+
+	manager = NewTestManager(map[string]genericexec.GenericExecResult{
+		"deploy": {ExitCode: 1, StdErr: "host unreachable"},
+	})
+	fmt.Println(deploy(manager, "v1.2.3"))
+	// Output:
+	// deployed v1.2.3
+	// deploy failed: host unreachable
+}
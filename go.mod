@@ -0,0 +1,10 @@
+module github.com/mbaynton/go-genericexec
+
+go 1.21
+
+require (
+	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d
+	github.com/creack/pty v1.1.24
+	golang.org/x/sync v0.11.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,54 @@
+// Package httpstream runs genericexec tasks on behalf of HTTP requests, streaming their output to the client as it
+// is produced. It is separate so that using genericexec does not require net/http.
+package httpstream
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mbaynton/go-genericexec"
+)
+
+// StreamTaskHTTP runs the named task and writes its output to w as soon as it is produced, flushing after every
+// write when w supports it. Once the task completes, a final line reports its exit code. The task is cancelled if
+// the request's context is done, for example because the client disconnected.
+//
+// The response is sent with status 200 before the task's outcome is known, so clients must read the final line to
+// learn whether it succeeded. The returned error is non-nil only when writing to w failed; the task still runs to
+// completion after that.
+func StreamTaskHTTP(w http.ResponseWriter, r *http.Request, manager *genericexec.GenericExecManager, taskName string, argValues genericexec.TemplateGetter) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	flusher := &flushingWriter{writer: w, controller: http.NewResponseController(w), atLineStart: true}
+	result := <-manager.RunTaskWithOptions(r.Context(), taskName, argValues, genericexec.RunOptions{OutputWriter: flusher})
+	if result.StreamError != nil {
+		return result.StreamError
+	}
+	if !flusher.atLineStart {
+		fmt.Fprintln(flusher)
+	}
+	_, err := fmt.Fprintf(flusher, "Task %s exited %d.\n", taskName, result.ExitCode)
+	return err
+}
+
+// flushingWriter flushes after every write.
+type flushingWriter struct {
+	writer      io.Writer
+	controller  *http.ResponseController
+	atLineStart bool
+}
+
+func (writer *flushingWriter) Write(p []byte) (int, error) {
+	n, err := writer.writer.Write(p)
+	if n > 0 {
+		writer.atLineStart = p[n-1] == '\n'
+	}
+	if err == nil {
+		// Not every ResponseWriter can flush; the output then arrives whenever the server sends it.
+		writer.controller.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,64 @@
+package httpstream
+
+import (
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/mbaynton/go-genericexec"
+)
+
+func TestStreamTaskHTTP(t *testing.T) {
+	taskConfigs := map[string]genericexec.GenericExecConfig{
+		"test": {
+			Name:      "test",
+			Command:   "test",
+			Args:      []string{"{{request \"value1\"}}"},
+			Reentrant: true,
+		},
+	}
+	manager := genericexec.NewGenericExecManager(taskConfigs, log.New(&strings.Builder{}, "", 0), func(string) {})
+	manager.CmdFactory = func(name string, argValues genericexec.TemplateGetter, arg ...string) (*exec.Cmd, error) {
+		renderedArgs, err := genericexec.RenderArgTemplates(arg, argValues)
+		if err != nil {
+			return nil, err
+		}
+		cs := append([]string{"-test.run=TestHelperExecHandler", "--", name}, renderedArgs...)
+		cmd := exec.Command(os.Args[0], cs...)
+		cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		return cmd, nil
+	}
+
+	for _, output := range []string{"first\nsecond", "first\nsecond\n"} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/tail", nil)
+		err := StreamTaskHTTP(recorder, request, manager, "test", url.Values{"value1": []string{output}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expect := "first\nsecond\nTask test exited 0.\n"
+		if body := recorder.Body.String(); body != expect {
+			t.Errorf("Expected body \"%s\", got \"%s\"", expect, body)
+		}
+		if !recorder.Flushed {
+			t.Error("Expected the response to be flushed")
+		}
+	}
+}
+
+// Mock process exec body
+func TestHelperExecHandler(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	// Echo the received arguments on StdOut and exit 0
+	fmt.Print(strings.Join(os.Args[4:], " "))
+	os.Exit(0)
+}
@@ -0,0 +1,57 @@
+// Package ptyexec runs genericexec tasks attached to a pseudo-terminal, for commands that behave differently when
+// they aren't writing to a terminal, for example by buffering their output or leaving out color. It is a separate
+// package so that only programs that use it depend on github.com/creack/pty.
+//
+// To run a task on a pseudo-terminal, set its StartProcess to ptyexec.Start:
+//
+//	config.StartProcess = ptyexec.Start
+//
+// Pseudo-terminals are not supported on Windows.
+package ptyexec
+
+import (
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// Start is a genericexec.ProcessStarter that starts cmd with its stdout and stderr attached to a new pseudo-terminal,
+// and its stdin too unless the task has Stdin. A terminal has only one output, so the task's stdout and stderr are
+// both captured as its StdOut, in the order they were written, and its StdErr is empty. The terminal also changes
+// the output: line endings are written as "\r\n".
+func Start(cmd *exec.Cmd) (wait func() error, err error) {
+	stdout := cmd.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	cmd.Stdout, cmd.Stderr = nil, nil
+	terminal, err := pty.Start(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		// This ends with an error, EIO on Linux, once nothing has the terminal open anymore or it is closed.
+		io.Copy(stdout, terminal)
+	}()
+	return func() error {
+		err := cmd.Wait()
+		// As with exec.Cmd, a process the command left running with the terminal open can hold things up for
+		// WaitDelay at most.
+		if cmd.WaitDelay > 0 {
+			select {
+			case <-copied:
+			case <-time.After(cmd.WaitDelay):
+			}
+		} else {
+			<-copied
+		}
+		terminal.Close()
+		<-copied
+		return err
+	}, nil
+}
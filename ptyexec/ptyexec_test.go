@@ -0,0 +1,78 @@
+//go:build !windows
+
+package ptyexec
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/mbaynton/go-genericexec"
+)
+
+func TestStart(t *testing.T) {
+	taskConfigs := map[string]genericexec.GenericExecConfig{
+		"pipes": {
+			Name:      "pipes",
+			Command:   "isatty",
+			Reentrant: true,
+		},
+		"terminal": {
+			Name:         "terminal",
+			Command:      "isatty",
+			Reentrant:    true,
+			StartProcess: Start,
+		},
+		"fails": {
+			Name:         "fails",
+			Command:      "fail",
+			Reentrant:    true,
+			StartProcess: Start,
+		},
+	}
+	manager := genericexec.NewGenericExecManager(taskConfigs, log.New(&strings.Builder{}, "", 0), func(string) {})
+	manager.CmdFactory = func(name string, argValues genericexec.TemplateGetter, arg ...string) (*exec.Cmd, error) {
+		cs := append([]string{"-test.run=TestHelperExecHandler", "--", name}, arg...)
+		cmd := exec.Command(os.Args[0], cs...)
+		cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		return cmd, nil
+	}
+
+	if result := <-manager.RunTask("pipes", url.Values{}); result.StdOut != "stdout is not a terminal" {
+		t.Errorf("Expected a task run the usual way not to have a terminal, got \"%s\"", result.StdOut)
+	}
+	result := <-manager.RunTask("terminal", url.Values{})
+	if expect := "stdout is a terminal\r\nstderr is a terminal"; result.StdOut != expect || result.StdErr != "" {
+		t.Errorf("Expected StdOut \"%s\" and no StdErr, got %+v", expect, result)
+	}
+	if result := <-manager.RunTask("fails", url.Values{}); result.ExitCode != 3 || result.StdOut != "failed" {
+		t.Errorf("Expected the exit code and output of a failing task on a terminal, got %+v", result)
+	}
+}
+
+// Mock process exec body
+func TestHelperExecHandler(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	switch os.Args[3] {
+	case "isatty":
+		for _, stream := range []*os.File{os.Stdout, os.Stderr} {
+			name := strings.TrimPrefix(stream.Name(), "/dev/")
+			if info, err := stream.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				fmt.Fprintf(stream, "%s is a terminal\n", name)
+			} else if stream == os.Stdout {
+				fmt.Fprintf(stream, "%s is not a terminal\n", name)
+			}
+		}
+		os.Exit(0)
+	case "fail":
+		fmt.Fprint(os.Stderr, "failed")
+		os.Exit(3)
+	}
+}
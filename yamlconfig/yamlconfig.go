@@ -0,0 +1,35 @@
+// Package yamlconfig loads genericexec task configurations from YAML. It is separate so that using genericexec does
+// not require a YAML library.
+package yamlconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mbaynton/go-genericexec"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigsYAML decodes a YAML mapping of task configurations keyed by task name and validates them. It accepts
+// the same fields, with the same defaults, as genericexec.LoadConfigsJSON:
+//
+//	backup:
+//	  command: /usr/local/bin/backup
+//	  args: ["--target", "{{request \"target\"}}"]
+//	  reentrant: false
+//	  waitDelay: 5s
+func LoadConfigsYAML(r io.Reader) (map[string]genericexec.GenericExecConfig, error) {
+	var document interface{}
+	if err := yaml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("could not decode task configurations: %w", err)
+	}
+
+	// Going through JSON shares LoadConfigsJSON's field matching and validation.
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode task configurations: %w", err)
+	}
+	return genericexec.LoadConfigsJSON(bytes.NewReader(encoded))
+}
@@ -0,0 +1,66 @@
+package yamlconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbaynton/go-genericexec"
+)
+
+func TestLoadConfigsYAML(t *testing.T) {
+	document := `
+backup:
+  command: /usr/local/bin/backup
+  args: ["--target", "{{request \"target\"}}"]
+  successMessage: Backed up {{request "target"}}
+  env:
+    BACKUP_QUIET: "1"
+restart:
+  name: restart-unit
+  command: systemctl
+  args:
+    - restart
+    - '{{request "unit"}}'
+  reentrant: true
+  waitDelay: 5s
+`
+	expect := map[string]genericexec.GenericExecConfig{
+		"backup": {
+			Name:           "backup",
+			Command:        "/usr/local/bin/backup",
+			Args:           []string{"--target", "{{request \"target\"}}"},
+			SuccessMessage: "Backed up {{request \"target\"}}",
+			Env:            map[string]string{"BACKUP_QUIET": "1"},
+		},
+		"restart": {
+			Name:      "restart-unit",
+			Command:   "systemctl",
+			Args:      []string{"restart", "{{request \"unit\"}}"},
+			Reentrant: true,
+			WaitDelay: 5 * time.Second,
+		},
+	}
+
+	loaded, err := LoadConfigsYAML(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("Unexpected error loading configs: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, expect) {
+		t.Errorf("Expected %+v, got %+v", expect, loaded)
+	}
+}
+
+func TestLoadConfigsYAML_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"broken:\n  args: [x]\n":          `task "broken": no command configured`,
+		"broken:\n  command: [unclosed\n": `could not decode`,
+	}
+	for document, expect := range cases {
+		_, err := LoadConfigsYAML(strings.NewReader(document))
+		if err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected an error containing %s, got %v", expect, err)
+		}
+	}
+}